@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"strconv"
+	"time"
+)
+
+// audit record a mutation made by logged in user on the audit log, (before) and (after) are marshalled to JSON
+// and omitted if nil
+//
+// Called once mutation succeeded: the mutation can't be undone anymore, but a mutation missing from the audit
+// log must not go unnoticed, so handlers fail with auditFailed on error.
+// The entry is written even if the client went away meanwhile
+func audit(s *db.Service, context echo.Context, action string, targetType string, targetId string, before interface{}, after interface{}) error {
+	user := context.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+
+	entry := db.AuditEntry{
+		Actor:      claims["username"].(string),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Ip:         context.RealIP(),
+	}
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return errors.New(fmt.Sprintf("error encoding audit entry %s on %s %s: %v\n", action, targetType, targetId, err))
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return errors.New(fmt.Sprintf("error encoding audit entry %s on %s %s: %v\n", action, targetType, targetId, err))
+		}
+	}
+
+	entry.New(db.Service{Db: s.Db})
+	if err = entry.Append(); err != nil {
+		return errors.New(fmt.Sprintf("error recording audit entry %s on %s %s: %v\n", action, targetType, targetId, err))
+	}
+	return nil
+}
+
+// auditFailed reply with 500 when (err) prevented recording a mutation on the audit log
+func auditFailed(context echo.Context, err error) error {
+	fmt.Printf("Error recording audit entry: %v\n", err)
+	return context.String(http.StatusInternalServerError, fmt.Sprintf("Change applied but not recorded on the audit log: %v\n", err))
+}
+
+// GetAuditLog return audit entries, newest first
+//
+// Filtered by actor, action, target_type, target_id, from and to (dates as 20060102, both included)
+// query params if passed. limit query param cap returned entries, 100 by default
+func GetAuditLog(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		filter := db.AuditFilter{
+			Actor:      context.QueryParam("actor"),
+			Action:     context.QueryParam("action"),
+			TargetType: context.QueryParam("target_type"),
+			TargetId:   context.QueryParam("target_id"),
+		}
+
+		var err error
+		if from := context.QueryParam("from"); from != "" {
+			if filter.From, err = time.Parse("20060102", from); err != nil {
+				return context.String(http.StatusBadRequest, "Malformed from param passed")
+			}
+		}
+		if to := context.QueryParam("to"); to != "" {
+			if filter.To, err = time.Parse("20060102", to); err != nil {
+				return context.String(http.StatusBadRequest, "Malformed to param passed")
+			}
+			filter.To = filter.To.AddDate(0, 0, 1)
+		}
+		if limit := context.QueryParam("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+				return context.String(http.StatusBadRequest, "Malformed limit param passed")
+			}
+		}
+
+		var (
+			entry   db.AuditEntry
+			entries []db.AuditEntry
+		)
+		entry.New(*s)
+		if err = entry.GetAll(filter, &entries); err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving audit log: %v\n", err))
+		}
+		if entries == nil {
+			entries = []db.AuditEntry{}
+		}
+		return context.JSON(http.StatusOK, entries)
+	}
+}
+
+// VerifyAuditLog check the audit log hash chain, answering conflict with the first entry breaking it
+func VerifyAuditLog(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		entry := db.AuditEntry{}
+		entry.New(*s)
+
+		checked, err := entry.Verify()
+		var chain *db.ChainError
+		if errors.As(err, &chain) {
+			fmt.Printf("Audit log tampered: %v\n", err)
+			return context.JSON(http.StatusConflict, struct {
+				Valid bool           `json:"valid"`
+				Error *db.ChainError `json:"error"`
+			}{false, chain})
+		}
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error verifying audit log: %v\n", err))
+		}
+
+		return context.JSON(http.StatusOK, struct {
+			Valid   bool `json:"valid"`
+			Entries int  `json:"entries"`
+		}{true, checked})
+	}
+}
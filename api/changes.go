@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo"
@@ -9,6 +10,9 @@ import (
 	"os"
 	"shift-manager/db"
 	"shift-manager/gsuite"
+	"shift-manager/notify"
+	"shift-manager/roster"
+	"shift-manager/rules"
 	"time"
 )
 
@@ -21,6 +25,9 @@ type change struct {
 
 // PutChange actually modify gsheet shift table switching passed operators
 //
+// Operator names are spreadsheet labels, resolved through operator aliases so any alias of the
+// operator is found on gsheet
+//
 // Request body:
 // {
 //		first_date: Requester date
@@ -28,8 +35,9 @@ type change struct {
 //		second_date: Requested date
 //		second_name: Requested operator name
 // }
-func PutChange() echo.HandlerFunc {
+func PutChange(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var err error
 		sheetService := gsuite.Service{}
 		err = sheetService.New(os.Getenv("SHIFT_ID"))
@@ -37,6 +45,7 @@ func PutChange() echo.HandlerFunc {
 			fmt.Printf("Error creating gSheet service: %v\n", err)
 			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gSheet service: %v\n", err))
 		}
+		sheetService = sheetService.WithContext(context.Request().Context())
 
 		var c change
 
@@ -54,22 +63,49 @@ func PutChange() echo.HandlerFunc {
 			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating shift change service: %v\n", err))
 		}
 
+		// Resolve labels to operators, so every alias is searched
+		directory, err := roster.LoadDirectory(*s)
+		if err != nil {
+			fmt.Printf("Error retrieving operator aliases: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+		}
+		first, err := operatorByLabel(directory, c.FirstName)
+		if err != nil {
+			return labelError(context, err)
+		}
+		second, err := operatorByLabel(directory, c.SecondName)
+		if err != nil {
+			return labelError(context, err)
+		}
+
 		sc.FirstDate = c.FirstDate
-		sc.FirstName = c.FirstName
+		sc.FirstName = first.Label
+		sc.FirstAliases = first.Aliases
 		sc.SecondDate = c.SecondDate
-		sc.SecondName = c.SecondName
+		sc.SecondName = second.Label
+		sc.SecondAliases = second.Aliases
 
 		// Call service to actually modify gsheet
 		err = sc.SwitchShifts()
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
 		if err != nil {
 			fmt.Printf("Error switching shifts: %v,\n", err)
 			return context.String(http.StatusBadRequest, fmt.Sprintf("Error switching shifts: %v,\n", err))
 		}
+		// Record who holds each date before and after the switch
+		switched := change{FirstDate: c.FirstDate, FirstName: c.SecondName, SecondDate: c.SecondDate, SecondName: c.FirstName}
+		target := fmt.Sprintf("%s %s, %s %s", c.FirstName, c.FirstDate.Format("2006-01-02"), c.SecondName, c.SecondDate.Format("2006-01-02"))
+		if err := audit(s, context, "roster.switch", "roster", target, c, switched); err != nil {
+			return auditFailed(context, err)
+		}
 		return context.String(http.StatusOK, "Shift correctly modified")
 	}
 }
 
-// RequestChange create a new shift change request to DB. Will be posted to gsheet after is been managed
+// RequestChange create a new shift change request to DB. Counterpart (with) operator is asked to accept it,
+// then it will be posted to gsheet after is been managed
 //
 // Request body:
 // {
@@ -79,6 +115,7 @@ func PutChange() echo.HandlerFunc {
 // }
 func RequestChange(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
 			err         error
 			requester   db.User        // Requester user data (username and ID)
@@ -103,11 +140,46 @@ func RequestChange(s *db.Service) echo.HandlerFunc {
 			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
 		}
 		shiftChange.ApplicantName = requester.Id
+
+		// Check swap eligibility, violations don't block the request as the manager may override them
+		directory, err := roster.LoadDirectory(*s)
+		if err != nil {
+			fmt.Printf("Error retrieving operator aliases: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+		}
+		applicant, err := directory.Operator(shiftChange.ApplicantName)
+		if err != nil {
+			return labelError(context, err)
+		}
+		with, err := directory.Operator(shiftChange.WithName)
+		if err != nil {
+			return labelError(context, err)
+		}
+		broken, err := evaluateChange(s, shiftChange, applicant, with)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error checking shift request: %v\n", err))
+		}
+
 		err = shiftChange.NewRequest()
 		if err != nil {
 			return context.String(http.StatusBadRequest, fmt.Sprintf("error creating shift request: %v\n", err))
 		}
+		notifyAsync(s, func(n notify.Notifier) error {
+			return n.NotifyChange(notify.EventChangeCreated, shiftChange, shiftChange.WithName)
+		})
+		if err := audit(s, context, "change.create", "shift_change", shiftChange.Id, nil, shiftChange); err != nil {
+			return auditFailed(context, err)
+		}
 
+		if len(broken) > 0 {
+			return context.JSON(http.StatusOK, violations{
+				Message:    "Shift change request submitted, it breaks eligibility rules the manager will have to override",
+				Violations: broken,
+			})
+		}
 		return context.String(http.StatusOK, "Shift change request correctly submitted")
 	}
 }
@@ -118,17 +190,23 @@ func RequestChange(s *db.Service) echo.HandlerFunc {
 //
 // Unused field will be discarded
 //
+// Accepted requests are checked against swap eligibility rules again, if any is broken the request is
+// answered with the violations and can be accepted only passing a justification, recorded with them.
+//
 // Request body:
 // {
 //		id: change request id
 //		status: one of "rejected" or "accepted"
+//		justification: reason to override broken eligibility rules, optional
 // }
 // TODO: implement func
 func ManageChangeRequest(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		type param struct {
-			Id     string `json:"id"`
-			Status string `json:"status"`
+			Id            string `json:"id"`
+			Status        string `json:"status"`
+			Justification string `json:"justification"`
 		}
 
 		type manager struct {
@@ -139,8 +217,6 @@ func ManageChangeRequest(s *db.Service) echo.HandlerFunc {
 		var (
 			err            error
 			p              param
-			sheetService   gsuite.Service
-			sc             gsuite.ShiftsToSwitch
 			statusToChange db.ShiftChange
 			m              manager
 		)
@@ -158,7 +234,7 @@ func ManageChangeRequest(s *db.Service) echo.HandlerFunc {
 		user := context.Get("user").(*jwt.Token)
 		claims := user.Claims.(jwt.MapClaims)
 		username := claims["username"].(string)
-		row := s.Db.QueryRow(sqlGetManagerNameFromUsername, username)
+		row := s.Db.QueryRowContext(s.Context(), sqlGetManagerNameFromUsername, username)
 		switch err = row.Scan(&m.id, &m.name); err {
 		case sql.ErrNoRows:
 			fmt.Printf("No manager name found: %v\n", err)
@@ -182,65 +258,103 @@ func ManageChangeRequest(s *db.Service) echo.HandlerFunc {
 			fmt.Printf("Error retrieving selected change request: %v\n", err)
 			return context.String(http.StatusNotFound, fmt.Sprintf("Error retrieving selected change request: %v\n", err))
 		}
-		// Set shift change managerId and status from request data
+		before := statusToChange
+		// Set shift change managerId from logged in user
 		statusToChange.Manager = m.id
-		statusToChange.Status = p.Status
+
+		// An accepted request whose roster swap failed may be accepted again to retry the swap
+		retry := statusToChange.Status == db.ChangeAccepted && p.Status == db.ChangeAccepted
 
 		// -------------
-		// Prepare gsheet service and modify spreadsheet, then, if succesful update DB
+		// Resolve operators before accepting, apply the swap to the roster, then, if succesful mark it applied
 		// -------------
 
-		// create new gsheet service
-		err = sheetService.New(os.Getenv("SHIFT_ID"))
-		if err != nil {
-			fmt.Printf("Error creating gSheet service: %v\n", err)
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gSheet service: %v\n", err))
-		}
-		err = sc.New(sheetService)
-		if err != nil {
-			fmt.Printf("Error creating gSheet service: %v\n", err)
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gSheet service: %v\n", err))
-		}
-
-		sqlSheetChange := `
-			select c.applicant_date,
-				   a.surname as applicant_name,
-				   c.with_date,
-				   w.surname as with_name
-			from shift_change c
-					 inner join operators a on c.applicant_name = a."user"
-					 inner join operators w on c.with_name = w."user"
-			where c.id = $1
-			`
+		var (
+			applicant, with roster.Operator
+			broken          []rules.Violation
+		)
+		if p.Status == db.ChangeAccepted {
+			// Resolve operators spreadsheet labels through aliases
+			directory, err := roster.LoadDirectory(*s)
+			if err != nil {
+				fmt.Printf("Error retrieving operator aliases: %v\n", err)
+				return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+			}
+			applicant, err = directory.Operator(statusToChange.ApplicantName)
+			if err != nil {
+				return labelError(context, err)
+			}
+			with, err = directory.Operator(statusToChange.WithName)
+			if err != nil {
+				return labelError(context, err)
+			}
 
-		// populate sc with with applicant and with data from passed change request id
-		row = s.Db.QueryRow(sqlSheetChange, p.Id)
-		switch err = row.Scan(&sc.FirstDate, &sc.FirstName, &sc.SecondDate, &sc.SecondName); err {
-		case sql.ErrNoRows:
-			fmt.Printf("No requester found: %v\n", err)
-			return context.String(http.StatusNotFound, fmt.Sprintf("No requester found: %v\n", err))
-		case nil:
-		default:
-			fmt.Printf("Error retrieving requester")
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving requester name: %v\n", err))
+			// Roster may have changed since request creation, check eligibility again
+			broken, err = evaluateChange(s, statusToChange, applicant, with)
+			if gsuite.IsQuotaError(err) {
+				return sheetsUnavailable(context, err, "")
+			}
+			if err != nil {
+				fmt.Printf("Error checking change request: %v\n", err)
+				return context.String(http.StatusBadRequest, fmt.Sprintf("Error checking change request: %v\n", err))
+			}
+			if err = rules.Justify(broken, p.Justification); err != nil {
+				return context.JSON(http.StatusUnprocessableEntity, violations{Message: err.Error(), Violations: broken})
+			}
 		}
 
-		// call service to actually modify gsheet only if status is 'accepted'
-		if statusToChange.Status == "accepted" {
-			err = sc.SwitchShifts()
+		// call db service to update status, only requests accepted by the counterpart reach the manager
+		if !retry {
+			statusToChange.Status = p.Status
+			err = statusToChange.ChangeStatus()
+			var transition *db.TransitionError
+			if errors.As(err, &transition) {
+				fmt.Printf("Illegal change request status: %v\n", err)
+				return context.String(http.StatusConflict, fmt.Sprintf("Illegal change request status: %v\n", err))
+			}
 			if err != nil {
-				fmt.Printf("Error switching shifts: %v\n", err)
-				return context.String(http.StatusBadRequest, fmt.Sprintf("Error switching shifts: %v\n", err))
+				fmt.Printf("Error updating change request: %v\n", err)
+				return context.String(http.StatusInternalServerError, fmt.Sprintf("Error updating change request: %v\n", err))
 			}
+			if err := audit(s, context, "change."+p.Status, "shift_change", statusToChange.Id, before, statusToChange); err != nil {
+				return auditFailed(context, err)
+			}
+			before = statusToChange
+		}
+		if p.Status != db.ChangeAccepted {
+			notifyAsync(s, func(n notify.Notifier) error {
+				return n.NotifyChange(notify.EventChangeRejected, statusToChange, statusToChange.ApplicantName, statusToChange.WithName)
+			})
+			return context.String(http.StatusOK, "change request managed")
 		}
 
-		// call db service to update status
-		err = statusToChange.ChangeStatus()
+		// switch shifts, mark request applied and record overridden violations as a single saga,
+		// reverting the roster if DB update fails
+		override := ruleOverride(m.id, p.Justification, broken)
+		err = roster.ApplyChange(s, statusToChange, applicant, statusToChange.ApplicantDate, with, statusToChange.WithDate, override)
+		if errors.Is(err, db.ErrSagaInFlight) {
+			return context.String(http.StatusConflict, "Change request is already being applied\n")
+		}
+		var conflict *gsuite.ConflictError
+		if errors.As(err, &conflict) {
+			fmt.Printf("Roster changed while switching shifts: %v\n", err)
+			return context.String(http.StatusConflict, fmt.Sprintf("Roster changed while switching shifts, request left accepted, retry: %v\n", err))
+		}
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "request left accepted")
+		}
 		if err != nil {
-			fmt.Printf("Error updating change request: %v\n", err)
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error updating change request: %v\n", err))
+			fmt.Printf("Error switching shifts: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error switching shifts, request left accepted: %v\n", err))
+		}
+		applied := statusToChange
+		applied.Status = db.ChangeApplied
+		notifyAsync(s, func(n notify.Notifier) error {
+			return n.NotifyChange(notify.EventChangeApproved, applied, applied.ApplicantName, applied.WithName)
+		})
+		if err := audit(s, context, "change.applied", "shift_change", applied.Id, before, applied); err != nil {
+			return auditFailed(context, err)
 		}
-
 		return context.String(http.StatusOK, "change request managed")
 	}
 }
@@ -248,6 +362,7 @@ func ManageChangeRequest(s *db.Service) echo.HandlerFunc {
 // GetAllChanges return all changes
 func GetAllChanges(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
 			err          error
 			shiftChange  db.ShiftChange
@@ -266,6 +381,7 @@ func GetAllChanges(s *db.Service) echo.HandlerFunc {
 
 func GetAllChangesForUser(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
 			err          error
 			requester    db.User
@@ -295,3 +411,183 @@ func GetAllChangesForUser(s *db.Service) echo.HandlerFunc {
 		return context.JSON(http.StatusOK, shiftChanges)
 	}
 }
+
+// GetIncomingChanges return change requests logged in operator is asked to accept as counterpart
+func GetIncomingChanges(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			err          error
+			counterpart  db.User
+			shiftChange  db.ShiftChange
+			shiftChanges []db.ShiftChange
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		// Create service and get logged in user's DB ID
+		counterpart.New(*s)
+		err = counterpart.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		shiftChange.New(*s)
+		shiftChange.WithName = counterpart.Id
+		err = shiftChange.GetAllIncoming(&shiftChanges)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's incoming shift changes: %v\n", err))
+		}
+
+		return context.JSON(http.StatusOK, shiftChanges)
+	}
+}
+
+// RespondToChange record logged in operator answer to a change request it's counterpart of.
+// Accepted requests move to the manager queue, declined ones are rejected
+//
+// Request body:
+// {
+//		id: change request id
+//		status: one of "accepted" or "declined"
+// }
+func RespondToChange(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			err         error
+			counterpart db.User
+			shiftChange db.ShiftChange
+			p           struct {
+				Id     string `json:"id"`
+				Status string `json:"status"`
+			}
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		counterpart.New(*s)
+		err = counterpart.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		if err = context.Bind(&p); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
+		}
+		if p.Status != "accepted" && p.Status != "declined" {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("status must be one of accepted or declined, got %q\n", p.Status))
+		}
+
+		shiftChange.New(*s)
+		shiftChange.Id = p.Id
+		shiftChange.WithName = counterpart.Id
+		err = shiftChange.CounterpartResponse(p.Status == "accepted")
+		var transition *db.TransitionError
+		if errors.As(err, &transition) {
+			return context.String(http.StatusConflict, fmt.Sprintf("error answering change request: %v\n", err))
+		}
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error answering change request: %v\n", err))
+		}
+
+		if err := audit(s, context, "change."+shiftChange.Status, "shift_change", shiftChange.Id, map[string]string{"status": db.ChangePending}, shiftChange); err != nil {
+			return auditFailed(context, err)
+		}
+
+		// Let the applicant know the counterpart declined, accepted requests are up to the manager now
+		if shiftChange.Status == db.ChangeRejected {
+			declined := db.ShiftChange{}
+			declined.New(*s)
+			if err = declined.GetById(shiftChange.Id); err != nil {
+				fmt.Printf("Error retrieving declined change request: %v\n", err)
+			} else {
+				notifyAsync(s, func(n notify.Notifier) error {
+					return n.NotifyChange(notify.EventChangeRejected, declined, declined.ApplicantName)
+				})
+			}
+		}
+		return context.String(http.StatusOK, "Change request answered")
+	}
+}
+
+// CancelChange withdraw a change request made by logged in operator, allowed until it's applied
+//
+// Request body:
+// {
+//		id: change request id
+// }
+func CancelChange(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var p struct {
+			Id string `json:"id"`
+		}
+
+		applicant, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+		if err = context.Bind(&p); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
+		}
+
+		before := db.ShiftChange{}
+		before.New(*s)
+		if err = before.GetById(p.Id); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error retrieving change request: %v\n", err))
+		}
+
+		shiftChange := db.ShiftChange{Id: p.Id, ApplicantName: applicant.Id}
+		shiftChange.New(*s)
+		err = shiftChange.Cancel()
+		var transition *db.TransitionError
+		if errors.As(err, &transition) {
+			return context.String(http.StatusConflict, fmt.Sprintf("error cancelling change request: %v\n", err))
+		}
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error cancelling change request: %v\n", err))
+		}
+
+		if err := audit(s, context, "change.cancelled", "shift_change", shiftChange.Id, before, shiftChange); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.String(http.StatusOK, "Change request cancelled")
+	}
+}
+
+// operatorByLabel resolve operator written as (label) on spreadsheet
+func operatorByLabel(directory roster.Directory, label string) (roster.Operator, error) {
+	id, err := directory.Resolve(label)
+	if err != nil {
+		return roster.Operator{}, err
+	}
+	return directory.Operator(id)
+}
+
+// labelError answer with the error of an operator label resolution, ambiguous labels are reported as conflict
+func labelError(context echo.Context, err error) error {
+	fmt.Printf("Error resolving operator label: %v\n", err)
+	var ambiguous *roster.AmbiguousLabelError
+	if errors.As(err, &ambiguous) {
+		return context.String(http.StatusConflict, fmt.Sprintf("Operator spreadsheet label is ambiguous, ask an admin to fix aliases: %v\n", err))
+	}
+	return context.String(http.StatusBadRequest, fmt.Sprintf("Error resolving operator: %v\n", err))
+}
+
+// sheetsUnavailable answer a Google Sheets quota or temporary failure (err) as service unavailable
+//
+// (state) tell the client what was left behind, like "request left accepted", if anything
+func sheetsUnavailable(context echo.Context, err error, state string) error {
+	fmt.Printf("Google Sheets unavailable: %v\n", err)
+	if state != "" {
+		return context.String(http.StatusServiceUnavailable, fmt.Sprintf("Google Sheets temporarily unavailable, %s, retry later", state))
+	}
+	return context.String(http.StatusServiceUnavailable, "Google Sheets temporarily unavailable, retry later")
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/roster"
+	"shift-manager/rules"
+)
+
+// RequestCover create a new cover request, handing logged in operator shift to a free colleague.
+// Will be applied to roster after a manager accept it.
+// Broken swap eligibility rules don't block the request, they're answered along with it for the manager to override
+//
+// Request body:
+// {
+//		applicant_date: date of the shift to hand over
+//		covering: covering operator user UUID
+// }
+func RequestCover(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			err       error
+			requester db.User
+			cover     db.CoverRequest
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		requester.New(*s)
+		err = requester.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		cover.New(*s)
+		if err = context.Bind(&cover); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
+		}
+		cover.Applicant = requester.Id
+		if cover.Covering == "" || cover.Covering == cover.Applicant {
+			return context.String(http.StatusBadRequest, "a covering operator other than applicant is required\n")
+		}
+
+		// Applicant must be on shift and covering operator free on that day
+		applicant, covering, err := coverOperators(s, cover)
+		if err != nil {
+			return labelError(context, err)
+		}
+		source, err := roster.NewSource(s)
+		if err != nil {
+			fmt.Printf("Error creating roster source: %v\n", err)
+			return context.String(http.StatusInternalServerError, "Error creating roster source")
+		}
+		_, err = source.Assignment(applicant, cover.ApplicantDate)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("applicant is not on shift on requested date: %v\n", err))
+		}
+		_, err = source.Assignment(covering, cover.ApplicantDate)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err == nil {
+			return context.String(http.StatusBadRequest, "covering operator is already on shift on requested date\n")
+		}
+		if !errors.Is(err, roster.ErrNotAssigned) {
+			fmt.Printf("Error retrieving covering operator assignment: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving covering operator assignment: %v\n", err))
+		}
+		broken, err := evaluate(s, []rules.Handover{{From: applicant, To: covering, Date: cover.ApplicantDate}})
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error checking cover request: %v\n", err))
+		}
+
+		err = cover.NewRequest()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error creating cover request: %v\n", err))
+		}
+		if err := audit(s, context, "cover.create", "cover_request", cover.Id, nil, cover); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, struct {
+			db.CoverRequest
+			Violations []rules.Violation `json:"violations,omitempty"`
+		}{cover, broken})
+	}
+}
+
+// GetAllCovers return every cover request
+func GetAllCovers(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		return coverRequests(s, context, "")
+	}
+}
+
+// GetAllCoversForUser return cover requests logged in operator is applicant or covering operator of
+func GetAllCoversForUser(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var requester db.User
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		requester.New(*s)
+		err := requester.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		return coverRequests(s, context, requester.Id)
+	}
+}
+
+// coverRequests answer with cover requests of (operator), or every request if empty
+func coverRequests(s *db.Service, context echo.Context, operator string) error {
+	var (
+		cover  db.CoverRequest
+		covers []db.CoverRequest
+	)
+
+	cover.New(*s)
+	err := cover.GetAll(operator, &covers)
+	if err != nil {
+		return context.String(http.StatusInternalServerError, fmt.Sprintf("error retrieving cover requests: %v\n", err))
+	}
+	if covers == nil {
+		covers = []db.CoverRequest{}
+	}
+	return context.JSON(http.StatusOK, covers)
+}
+
+// ManageCover set a pending cover request as accepted or rejected, accepted requests are applied to roster
+//
+// Will read actual manager from JWT and set timestamp automatically.
+//
+// Accepted requests are checked against swap eligibility rules like change requests, see ManageChangeRequest.
+//
+// Request body:
+// {
+//		id: cover request id
+//		status: one of "rejected" or "accepted"
+//		justification: reason to override broken eligibility rules, optional
+// }
+func ManageCover(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		type param struct {
+			Id            string `json:"id"`
+			Status        string `json:"status"`
+			Justification string `json:"justification"`
+		}
+
+		var (
+			err     error
+			p       param
+			manager db.User
+			cover   db.CoverRequest
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		manager.New(*s)
+		err = manager.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("No manager found: %v\n", err))
+		}
+
+		if err = context.Bind(&p); err != nil {
+			fmt.Printf("Error binding request body: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		cover.New(*s)
+		err = cover.GetById(p.Id)
+		if err != nil {
+			fmt.Printf("Error retrieving selected cover request: %v\n", err)
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error retrieving selected cover request: %v\n", err))
+		}
+		if cover.Status != db.RequestPending {
+			return context.String(http.StatusConflict, fmt.Sprintf("Cover request already %s\n", cover.Status))
+		}
+		before := cover
+		cover.Manager = manager.Id
+		cover.Status = p.Status
+
+		if cover.Status == db.RequestAccepted {
+			applicant, covering, err := coverOperators(s, cover)
+			if err != nil {
+				return labelError(context, err)
+			}
+
+			// Roster may have changed since request creation, check eligibility again
+			broken, err := evaluate(s, []rules.Handover{{From: applicant, To: covering, Date: cover.ApplicantDate}})
+			if gsuite.IsQuotaError(err) {
+				return sheetsUnavailable(context, err, "")
+			}
+			if err != nil {
+				fmt.Printf("Error checking cover request: %v\n", err)
+				return context.String(http.StatusBadRequest, fmt.Sprintf("Error checking cover request: %v\n", err))
+			}
+			if err = rules.Justify(broken, p.Justification); err != nil {
+				return context.JSON(http.StatusUnprocessableEntity, violations{Message: err.Error(), Violations: broken})
+			}
+			err = roster.ApplyCover(s, cover, applicant, covering, ruleOverride(manager.Id, p.Justification, broken))
+			if errors.Is(err, db.ErrSagaInFlight) {
+				return context.String(http.StatusConflict, "Cover request is already being applied\n")
+			}
+			var conflict *gsuite.ConflictError
+			if errors.As(err, &conflict) {
+				fmt.Printf("Roster changed while covering shift: %v\n", err)
+				return context.String(http.StatusConflict, fmt.Sprintf("Roster changed while covering shift, retry: %v\n", err))
+			}
+			if gsuite.IsQuotaError(err) {
+				return sheetsUnavailable(context, err, "")
+			}
+			if err != nil {
+				fmt.Printf("Error covering shift: %v\n", err)
+				return context.String(http.StatusBadRequest, fmt.Sprintf("Error covering shift: %v\n", err))
+			}
+			if err := audit(s, context, "cover.accepted", "cover_request", cover.Id, before, cover); err != nil {
+				return auditFailed(context, err)
+			}
+			return context.String(http.StatusOK, "cover request managed")
+		}
+
+		err = cover.ChangeStatus()
+		if err != nil {
+			fmt.Printf("Error updating cover request: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error updating cover request: %v\n", err))
+		}
+		if err := audit(s, context, "cover."+cover.Status, "cover_request", cover.Id, before, cover); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "cover request managed")
+	}
+}
+
+// coverOperators resolve (cover) applicant and covering operator spreadsheet labels through aliases
+func coverOperators(s *db.Service, cover db.CoverRequest) (roster.Operator, roster.Operator, error) {
+	directory, err := roster.LoadDirectory(*s)
+	if err != nil {
+		return roster.Operator{}, roster.Operator{}, err
+	}
+	applicant, err := directory.Operator(cover.Applicant)
+	if err != nil {
+		return roster.Operator{}, roster.Operator{}, err
+	}
+	covering, err := directory.Operator(cover.Covering)
+	if err != nil {
+		return roster.Operator{}, roster.Operator{}, err
+	}
+	return applicant, covering, nil
+}
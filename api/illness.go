@@ -6,7 +6,10 @@ import (
 	"github.com/labstack/echo"
 	"net/http"
 	"os"
+	"shift-manager/db"
 	"shift-manager/gsuite"
+	"shift-manager/notify"
+	"shift-manager/outbox"
 	"time"
 )
 
@@ -19,6 +22,7 @@ type illness struct {
 }
 
 // PostIllness post new illness request reading from request body.
+// Request is stored in the outbox and appended to gsheet by the outbox worker.
 // Timestamp will be added at post.
 // Name will be populated from logged in user
 //
@@ -29,20 +33,14 @@ type illness struct {
 //		"to":	"2019-12-30T00:00:00+01:00"	// To date
 //		"protocol_number": "12345A"			// Illness certification protocol number
 // }
-func PostIllness() echo.HandlerFunc {
+func PostIllness(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
-			sheetService gsuite.Service
-			err          error
-			i            illness
+			err error
+			i   illness
 		)
 
-		// Create gsheet service reading from env variable
-		err = sheetService.New(os.Getenv("SHEET_ID"))
-		if err != nil {
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gsheet service, :%v\n", err))
-		}
-
 		// Add post timestamp
 		i.Timestamp = time.Now()
 
@@ -61,13 +59,15 @@ func PostIllness() echo.HandlerFunc {
 		var d [][]interface{}
 		d = append(d, i.marshalGSheet())
 
-		// Call gsheet api to append data
-		_, err = sheetService.Append("Malattie!A4", d)
+		// Store data in outbox, worker will append it to gsheet
+		err = outbox.Enqueue(*s, os.Getenv("SHEET_ID"), gsuite.ActiveLayout().Request(gsuite.RequestIllness), d)
 		if err != nil {
-			return context.String(http.StatusBadRequest, fmt.Sprintf("Error posting data do Google sheet: %v\n", err))
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error recording illness request: %v\n", err))
 		}
 
-		return context.String(http.StatusCreated, "Succesfully posted permission request to Google sheets")
+		notifySubmission(s, context, notify.EventIllnessRecorded, i)
+
+		return context.String(http.StatusAccepted, "Illness request recorded, it will be posted to Google sheets shortly")
 	}
 }
 
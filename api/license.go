@@ -6,7 +6,10 @@ import (
 	"github.com/labstack/echo"
 	"net/http"
 	"os"
+	"shift-manager/db"
 	"shift-manager/gsuite"
+	"shift-manager/notify"
+	"shift-manager/outbox"
 	"time"
 )
 
@@ -21,6 +24,7 @@ type license struct {
 }
 
 // PostLicense post new license request reading from request body.
+// Request is stored in the outbox and appended to gsheet by the outbox worker.
 // Timestamp will be added at post.
 // Name will be populated from logged in user
 //
@@ -32,20 +36,14 @@ type license struct {
 //		"motivation": "I have to"			// Motivation to ask for a change
 //		"from_coordinator": true			// If change is asked from coordinator
 // }
-func PostLicense() echo.HandlerFunc {
+func PostLicense(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
-			sheetService gsuite.Service
-			err          error
-			l            license
+			err error
+			l   license
 		)
 
-		// Create gsheet service reading from env variable
-		err = sheetService.New(os.Getenv("SHEET_ID"))
-		if err != nil {
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gsheet service, :%v\n", err))
-		}
-
 		// Add post timestamp
 		l.Timestamp = time.Now()
 
@@ -64,13 +62,15 @@ func PostLicense() echo.HandlerFunc {
 		var d [][]interface{}
 		d = append(d, l.marshalGSheet())
 
-		// Call gsheet api to append data
-		_, err = sheetService.Append("Ferie!A4", d)
+		// Store data in outbox, worker will append it to gsheet
+		err = outbox.Enqueue(*s, os.Getenv("SHEET_ID"), gsuite.ActiveLayout().Request(gsuite.RequestLicense), d)
 		if err != nil {
-			return context.String(http.StatusBadRequest, fmt.Sprintf("Error posting data do Google sheet: %v\n", err))
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error recording license request: %v\n", err))
 		}
 
-		return context.String(http.StatusCreated, "Succesfully posted license request to Google sheets")
+		notifySubmission(s, context, notify.EventLicenseRecorded, l)
+
+		return context.String(http.StatusAccepted, "License request recorded, it will be posted to Google sheets shortly")
 	}
 }
 
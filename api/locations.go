@@ -9,6 +9,7 @@ import (
 
 func GetLocation(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		l := db.Location{}
 		l.New(*s)
 		name := context.Param("name")
@@ -23,6 +24,7 @@ func GetLocation(s *db.Service) echo.HandlerFunc {
 
 func GetAllLocations(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		l := db.Location{}
 		l.New(*s)
 
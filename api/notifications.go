@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/notify"
+	"sync"
+)
+
+const (
+	notificationWorkers   = 2   // Emails sent at once
+	notificationQueueSize = 100 // Emails waiting for a worker, more are dropped
+)
+
+var (
+	notifications     *notify.Queue
+	notificationsOnce sync.Once
+)
+
+// notifyAsync queue (send) for background delivery, so answers don't wait for the SMTP server.
+// Failures are only logged, like notifications dropped because the queue is full
+func notifyAsync(s *db.Service, send func(n notify.Notifier) error) {
+	notificationsOnce.Do(func() {
+		// Not bound to the request context, which is cancelled once answered
+		notifications = notify.NewQueue(notify.New(db.Service{Db: s.Db}), notificationWorkers, notificationQueueSize)
+	})
+	if !notifications.Submit(send) {
+		fmt.Printf("Notification queue full, notification dropped\n")
+	}
+}
+
+// notifySubmission notify logged in operator their submission (data) was recorded
+func notifySubmission(s *db.Service, context echo.Context, event string, data interface{}) {
+	user, err := userFromClaims(s, context)
+	if err != nil {
+		fmt.Printf("Error retrieving %s notification recipient: %v\n", event, err)
+		return
+	}
+	notifyAsync(s, func(n notify.Notifier) error {
+		return n.Notify(event, user.Id, data)
+	})
+}
+
+// notificationSetting is a notification event and whether logged in operator receive it
+type notificationSetting struct {
+	Event   string `json:"event"`
+	Enabled bool   `json:"enabled"`
+}
+
+// GetNotificationSettings return every notification event and whether logged in operator receive it
+func GetNotificationSettings(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		user, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		var (
+			optOut   db.NotificationOptOut
+			optedOut []string
+		)
+		optOut.New(*s)
+		if err = optOut.GetAll(user.Id, &optedOut); err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving notification settings: %v\n", err))
+		}
+
+		disabled := map[string]bool{}
+		for _, event := range optedOut {
+			disabled[event] = true
+		}
+		settings := []notificationSetting{}
+		for _, event := range notify.Events {
+			settings = append(settings, notificationSetting{Event: event, Enabled: !disabled[event]})
+		}
+		return context.JSON(http.StatusOK, settings)
+	}
+}
+
+// PutNotificationSetting opt logged in operator in or out of a notification event
+//
+// Request body:
+// {
+//		event: notification event, see GET /users/notifications
+//		enabled: false to stop receiving it
+// }
+func PutNotificationSetting(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var setting notificationSetting
+		if err := context.Bind(&setting); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+		if !notify.IsEvent(setting.Event) {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Unknown notification event %q\n", setting.Event))
+		}
+
+		user, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		optOut := db.NotificationOptOut{Operator: user.Id, Event: setting.Event}
+		optOut.New(*s)
+		if err = optOut.Set(setting.Enabled); err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error updating notification settings: %v\n", err))
+		}
+		if err := audit(s, context, "notification.update", "notification_setting", user.Id, nil, setting); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.JSON(http.StatusOK, setting)
+	}
+}
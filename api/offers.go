@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/roster"
+	"shift-manager/rules"
+	"strings"
+	"time"
+)
+
+// GetOpenOffers return open shift offers with their bids
+//
+// Optional query params: date (YYYYMMDD), location and role
+func GetOpenOffers(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			offer  db.ShiftOffer
+			offers []db.ShiftOffer
+			filter db.OfferFilter
+		)
+
+		if date := context.QueryParam("date"); date != "" {
+			d, err := time.Parse("20060102", date)
+			if err != nil {
+				fmt.Printf("Malformed date param passed: %v\n", err)
+				return context.String(http.StatusBadRequest, "Malformed date param passed")
+			}
+			filter.Date = d
+		}
+		filter.Location = context.QueryParam("location")
+		filter.Role = context.QueryParam("role")
+
+		offer.New(*s)
+		err := offer.GetOpen(filter, &offers)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("error retrieving shift offers: %v\n", err))
+		}
+		if offers == nil {
+			offers = []db.ShiftOffer{}
+		}
+		return context.JSON(http.StatusOK, offers)
+	}
+}
+
+// PostOffer post logged in operator shift on passed date to the board, assignment details are read from roster
+//
+// Request body:
+// {
+//		date: date of the shift to drop
+// }
+func PostOffer(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var body struct {
+			Date time.Time `json:"date"`
+		}
+		if err := context.Bind(&body); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
+		}
+
+		operator, err := operatorFromClaims(s, context)
+		if err != nil {
+			return labelError(context, err)
+		}
+		source, err := roster.NewSource(s)
+		if err != nil {
+			fmt.Printf("Error creating roster source: %v\n", err)
+			return context.String(http.StatusInternalServerError, "Error creating roster source")
+		}
+		assignment, err := source.Assignment(operator, body.Date)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("operator is not on shift on requested date: %v\n", err))
+		}
+
+		offer := db.ShiftOffer{
+			Applicant: operator.Id,
+			Date:      body.Date,
+			Location:  assignment.Location,
+			Shift:     assignment.Shift,
+			Vehicle:   assignment.Vehicle,
+			Role:      assignment.Role,
+			Bids:      []db.ShiftOfferBid{},
+		}
+		offer.New(*s)
+		if err = offer.Create(); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error posting shift offer: %v\n", err))
+		}
+		if err := audit(s, context, "offer.create", "shift_offer", offer.Id, nil, offer); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.JSON(http.StatusCreated, offer)
+	}
+}
+
+// WithdrawOffer close logged in operator open offer passed as :id param
+func WithdrawOffer(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		requester, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		offer := db.ShiftOffer{Id: context.Param("id")}
+		offer.New(*s)
+		if err = offer.Withdraw(requester.Id); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error withdrawing shift offer: %v\n", err))
+		}
+		if err := audit(s, context, "offer.withdraw", "shift_offer", offer.Id, nil, map[string]string{"status": offer.Status}); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.String(http.StatusOK, "Shift offer withdrawn")
+	}
+}
+
+// PostBid volunteer logged in operator for open offer passed as :id param, operator must be free on offer date
+// and qualified for offered role and vehicle
+func PostBid(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		operator, err := operatorFromClaims(s, context)
+		if err != nil {
+			return labelError(context, err)
+		}
+
+		offer := db.ShiftOffer{}
+		offer.New(*s)
+		if err = offer.GetById(context.Param("id")); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error retrieving shift offer: %v\n", err))
+		}
+
+		source, err := roster.NewSource(s)
+		if err != nil {
+			fmt.Printf("Error creating roster source: %v\n", err)
+			return context.String(http.StatusInternalServerError, "Error creating roster source")
+		}
+		_, err = source.Assignment(operator, offer.Date)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err == nil {
+			return context.String(http.StatusBadRequest, "operator is already on shift on offer date\n")
+		}
+		if !errors.Is(err, roster.ErrNotAssigned) {
+			fmt.Printf("Error retrieving bidder assignment: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving bidder assignment: %v\n", err))
+		}
+
+		// Bidder must hold every certificate offered role and vehicle require
+		qualification := db.Qualification{}
+		qualification.New(*s)
+		missing, err := qualification.Missing(operator.Id, offer.Role, offer.Vehicle, offer.Date)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error verifying bidder qualifications: %v\n", err))
+		}
+		if len(missing) > 0 {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("operator is not qualified for offered shift, missing %s\n", strings.Join(missing, ", ")))
+		}
+
+		if err = offer.Bid(operator.Id); err != nil {
+			return context.String(http.StatusConflict, fmt.Sprintf("error bidding on shift offer: %v\n", err))
+		}
+		if err := audit(s, context, "offer.bid", "shift_offer", offer.Id, nil, map[string]string{"bidder": operator.Id}); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.String(http.StatusCreated, "Bid placed")
+	}
+}
+
+// WithdrawBid remove logged in operator bid from open offer passed as :id param
+func WithdrawBid(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		requester, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		offer := db.ShiftOffer{Id: context.Param("id")}
+		offer.New(*s)
+		if err = offer.WithdrawBid(requester.Id); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("error withdrawing bid: %v\n", err))
+		}
+		if err := audit(s, context, "offer.bid_withdraw", "shift_offer", offer.Id, map[string]string{"bidder": requester.Id}, nil); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.String(http.StatusOK, "Bid withdrawn")
+	}
+}
+
+// AwardOffer give open offer passed as :id param to one of its bidders, updating the roster
+//
+// Winning bidder post-award roster is checked against swap eligibility rules like change requests,
+// see ManageChangeRequest.
+//
+// Request body:
+// {
+//		bidder: winning bidder user UUID
+//		justification: reason to override broken eligibility rules, optional
+// }
+func AwardOffer(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var body struct {
+			Bidder        string `json:"bidder"`
+			Justification string `json:"justification"`
+		}
+		if err := context.Bind(&body); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		manager, err := userFromClaims(s, context)
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("No manager found: %v\n", err))
+		}
+
+		offer := db.ShiftOffer{}
+		offer.New(*s)
+		if err = offer.GetById(context.Param("id")); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error retrieving shift offer: %v\n", err))
+		}
+		if offer.Status != db.OfferOpen {
+			return context.String(http.StatusConflict, fmt.Sprintf("Shift offer already %s\n", offer.Status))
+		}
+		before := offer
+		bid := false
+		for _, b := range offer.Bids {
+			bid = bid || b.Bidder == body.Bidder
+		}
+		if !bid {
+			return context.String(http.StatusBadRequest, "Shift offer can only be awarded to a bidder\n")
+		}
+
+		directory, err := roster.LoadDirectory(*s)
+		if err != nil {
+			fmt.Printf("Error retrieving operator aliases: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+		}
+		applicant, err := directory.Operator(offer.Applicant)
+		if err != nil {
+			return labelError(context, err)
+		}
+		winner, err := directory.Operator(body.Bidder)
+		if err != nil {
+			return labelError(context, err)
+		}
+
+		// Roster may have changed since the bid, check eligibility
+		broken, err := evaluate(s, []rules.Handover{{From: applicant, To: winner, Date: offer.Date}})
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			fmt.Printf("Error checking shift offer award: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error checking shift offer award: %v\n", err))
+		}
+		if err = rules.Justify(broken, body.Justification); err != nil {
+			return context.JSON(http.StatusUnprocessableEntity, violations{Message: err.Error(), Violations: broken})
+		}
+		err = roster.AwardOffer(s, offer, applicant, winner, manager.Id, ruleOverride(manager.Id, body.Justification, broken))
+		if errors.Is(err, db.ErrSagaInFlight) {
+			return context.String(http.StatusConflict, "Shift offer is already being awarded\n")
+		}
+		var conflict *gsuite.ConflictError
+		if errors.As(err, &conflict) {
+			fmt.Printf("Roster changed while awarding shift: %v\n", err)
+			return context.String(http.StatusConflict, fmt.Sprintf("Roster changed while awarding shift, retry: %v\n", err))
+		}
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			fmt.Printf("Error awarding shift offer: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error awarding shift offer: %v\n", err))
+		}
+		awarded := offer
+		awarded.Status = db.OfferAwarded
+		awarded.AwardedTo = winner.Id
+		if err := audit(s, context, "offer.awarded", "shift_offer", offer.Id, before, awarded); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.String(http.StatusOK, "Shift offer awarded")
+	}
+}
+
+// userFromClaims retrieve logged in user reading username from JWT
+func userFromClaims(s *db.Service, context echo.Context) (db.User, error) {
+	user := context.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	username := claims["username"].(string)
+
+	u := db.User{}
+	u.New(*s)
+	err := u.GetUser(username)
+	return u, err
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/roster"
+)
+
+// GetOperatorAliases return every operator alias, filtered by operator query param (user UUID) if passed
+func GetOperatorAliases(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			alias   db.OperatorAlias
+			aliases []db.OperatorAlias
+		)
+
+		alias.New(*s)
+		err := alias.GetAll(&aliases)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+		}
+
+		operator := context.QueryParam("operator")
+		if operator == "" {
+			return context.JSON(http.StatusOK, aliases)
+		}
+		filtered := []db.OperatorAlias{}
+		for _, a := range aliases {
+			if a.Operator == operator {
+				filtered = append(filtered, a)
+			}
+		}
+		return context.JSON(http.StatusOK, filtered)
+	}
+}
+
+// GetAmbiguousAliases return every spreadsheet label shared by more than one operator
+func GetAmbiguousAliases(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		directory, err := roster.LoadDirectory(*s)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator aliases: %v\n", err))
+		}
+
+		ambiguous := directory.Ambiguous()
+		if ambiguous == nil {
+			ambiguous = []roster.AmbiguousLabelError{}
+		}
+		return context.JSON(http.StatusOK, ambiguous)
+	}
+}
+
+// PostOperatorAlias link a new spreadsheet label to an operator
+//
+// Request body:
+// {
+//		operator: operator user UUID
+//		label: name as written on spreadsheet, stored uppercase
+// }
+func PostOperatorAlias(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		alias := db.OperatorAlias{}
+
+		// Bind request body to alias struct
+		if err := context.Bind(&alias); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		alias.New(*s)
+		err := alias.Create()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error creating operator alias: %v\n", err))
+		}
+		if err := audit(s, context, "alias.create", "operator_alias", alias.Id, nil, alias); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, alias)
+	}
+}
+
+// DeleteOperatorAlias remove alias passed as :id param
+func DeleteOperatorAlias(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		alias := db.OperatorAlias{}
+		alias.New(*s)
+
+		err := alias.Delete(context.Param("id"))
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error deleting operator alias: %v\n", err))
+		}
+		if err := audit(s, context, "alias.delete", "operator_alias", context.Param("id"), nil, nil); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Operator alias deleted")
+	}
+}
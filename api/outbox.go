@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+)
+
+// GetOutboxEntries return outbox entries filtered by status query param, dead lettered ones if not passed
+func GetOutboxEntries(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			entry   db.OutboxEntry
+			entries []db.OutboxEntry
+		)
+
+		status := context.QueryParam("status")
+		if status == "" {
+			status = db.OutboxDead
+		}
+
+		entry.New(*s)
+		err := entry.GetAllByStatus(status, &entries)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving outbox entries: %v\n", err))
+		}
+
+		return context.JSON(http.StatusOK, entries)
+	}
+}
+
+// ReplayOutboxEntry put dead lettered entry passed as :id param back in the delivery queue
+func ReplayOutboxEntry(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		entry := db.OutboxEntry{Id: context.Param("id")}
+		entry.New(*s)
+
+		err := entry.Replay()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error replaying outbox entry: %v\n", err))
+		}
+		if err := audit(s, context, "outbox.replay", "outbox_entry", entry.Id, map[string]string{"status": db.OutboxDead}, map[string]string{"status": db.OutboxPending}); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Outbox entry queued for delivery")
+	}
+}
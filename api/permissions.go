@@ -6,7 +6,10 @@ import (
 	"github.com/labstack/echo"
 	"net/http"
 	"os"
+	"shift-manager/db"
 	"shift-manager/gsuite"
+	"shift-manager/notify"
+	"shift-manager/outbox"
 	"time"
 )
 
@@ -20,6 +23,7 @@ type permission struct {
 }
 
 // PostPermission post new permission request reading from request body.
+// Request is stored in the outbox and appended to gsheet by the outbox worker.
 // Timestamp will be added at post.
 // Name will be populated from logged in user
 //
@@ -31,20 +35,14 @@ type permission struct {
 //		"to":	"2019-12-30T00:00:00+01:00"	// To Time
 //		"motivation": "I have to"			// Motivation to ask for a permission
 // }
-func PostPermission() echo.HandlerFunc {
+func PostPermission(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
-			sheetService gsuite.Service
-			err          error
-			p            permission
+			err error
+			p   permission
 		)
 
-		// Create gsheet service reading from env variable
-		err = sheetService.New(os.Getenv("SHEET_ID"))
-		if err != nil {
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gsheet service, :%v\n", err))
-		}
-
 		// Add post timestamp
 		p.Timestamp = time.Now()
 
@@ -63,13 +61,15 @@ func PostPermission() echo.HandlerFunc {
 		var d [][]interface{}
 		d = append(d, p.marshalGSheet())
 
-		// Call gsheet api to append data
-		_, err = sheetService.Append("PermessiOrari!A4", d)
+		// Store data in outbox, worker will append it to gsheet
+		err = outbox.Enqueue(*s, os.Getenv("SHEET_ID"), gsuite.ActiveLayout().Request(gsuite.RequestPermissions), d)
 		if err != nil {
-			return context.String(http.StatusBadRequest, fmt.Sprintf("Error posting data do Google sheet: %v\n", err))
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error recording permission request: %v\n", err))
 		}
 
-		return context.String(http.StatusCreated, "Succesfully posted permission request to Google sheets")
+		notifySubmission(s, context, notify.EventPermissionRecorded, p)
+
+		return context.String(http.StatusAccepted, "Permission request recorded, it will be posted to Google sheets shortly")
 	}
 }
 
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"strconv"
+	"time"
+)
+
+// GetCertificateTypes return every certificate type
+func GetCertificateTypes(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			certificateType  db.CertificateType
+			certificateTypes []db.CertificateType
+		)
+
+		certificateType.New(*s)
+		err := certificateType.GetAll(&certificateTypes)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving certificate types: %v\n", err))
+		}
+		if certificateTypes == nil {
+			certificateTypes = []db.CertificateType{}
+		}
+		return context.JSON(http.StatusOK, certificateTypes)
+	}
+}
+
+// PostCertificateType add a new certificate type
+//
+// Request body:
+// {
+//		name: certificate name, unique
+//		description: optional free text
+// }
+func PostCertificateType(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		certificateType := db.CertificateType{}
+
+		// Bind request body to certificate type struct
+		if err := context.Bind(&certificateType); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		certificateType.New(*s)
+		err := certificateType.Create()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error creating certificate type: %v\n", err))
+		}
+		if err := audit(s, context, "certificate_type.create", "certificate_type", certificateType.Id, nil, certificateType); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, certificateType)
+	}
+}
+
+// DeleteCertificateType remove certificate type passed as :id param, with every certificate and requirement of that type
+func DeleteCertificateType(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		certificateType := db.CertificateType{}
+		certificateType.New(*s)
+
+		err := certificateType.Delete(context.Param("id"))
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error deleting certificate type: %v\n", err))
+		}
+		if err := audit(s, context, "certificate_type.delete", "certificate_type", context.Param("id"), nil, nil); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Certificate type deleted")
+	}
+}
+
+// GetOperatorCertificates return every operator certificate, filtered by operator query param (user UUID) if passed
+func GetOperatorCertificates(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			certificate  db.OperatorCertificate
+			certificates []db.OperatorCertificate
+		)
+
+		certificate.New(*s)
+		err := certificate.GetAll(context.QueryParam("operator"), &certificates)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving operator certificates: %v\n", err))
+		}
+		if certificates == nil {
+			certificates = []db.OperatorCertificate{}
+		}
+		return context.JSON(http.StatusOK, certificates)
+	}
+}
+
+// PostOperatorCertificate record a certificate held by an operator
+//
+// Request body:
+// {
+//		operator: operator user UUID
+//		certificate_type: certificate type UUID
+//		issued_on: issue date, RFC3339
+//		expires_on: expiry date, RFC3339, omit if certificate never expires
+// }
+func PostOperatorCertificate(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		certificate := db.OperatorCertificate{}
+
+		// Bind request body to certificate struct
+		if err := context.Bind(&certificate); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		certificate.New(*s)
+		err := certificate.Create()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error recording operator certificate: %v\n", err))
+		}
+		if err := audit(s, context, "certificate.create", "operator_certificate", certificate.Id, nil, certificate); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, certificate)
+	}
+}
+
+// DeleteOperatorCertificate remove operator certificate passed as :id param
+func DeleteOperatorCertificate(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		certificate := db.OperatorCertificate{}
+		certificate.New(*s)
+
+		err := certificate.Delete(context.Param("id"))
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error deleting operator certificate: %v\n", err))
+		}
+		if err := audit(s, context, "certificate.delete", "operator_certificate", context.Param("id"), nil, nil); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Operator certificate deleted")
+	}
+}
+
+// GetExpiringCertificates return certificates expiring within days query param (default 30), already expired ones included
+//
+// Certificates already renewed are left out
+func GetExpiringCertificates(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		days := 30
+		if param := context.QueryParam("days"); param != "" {
+			var err error
+			days, err = strconv.Atoi(param)
+			if err != nil || days < 0 {
+				return context.String(http.StatusBadRequest, "Malformed days param passed")
+			}
+		}
+
+		var (
+			certificate  db.OperatorCertificate
+			certificates []db.OperatorCertificate
+		)
+		certificate.New(*s)
+		err := certificate.GetExpiring(time.Now().AddDate(0, 0, days), &certificates)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving expiring certificates: %v\n", err))
+		}
+		if certificates == nil {
+			certificates = []db.OperatorCertificate{}
+		}
+		return context.JSON(http.StatusOK, certificates)
+	}
+}
+
+// GetRequirements return certificates required by roles and vehicles
+func GetRequirements(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			requirement  db.Requirement
+			requirements []db.Requirement
+		)
+
+		requirement.New(*s)
+		err := requirement.GetAll(&requirements)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving requirements: %v\n", err))
+		}
+		if requirements == nil {
+			requirements = []db.Requirement{}
+		}
+		return context.JSON(http.StatusOK, requirements)
+	}
+}
+
+// PostRequirement require a certificate to be assigned to a role or a vehicle
+//
+// Request body:
+// {
+//		kind: role or vehicle
+//		target: operator role or vehicle UUID
+//		certificate_type: certificate type UUID
+// }
+func PostRequirement(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		requirement := db.Requirement{}
+
+		// Bind request body to requirement struct
+		if err := context.Bind(&requirement); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		requirement.New(*s)
+		err := requirement.Create()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error creating requirement: %v\n", err))
+		}
+		if err := audit(s, context, "requirement.create", "requirement", requirement.Kind+"/"+requirement.Target, nil, requirement); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, requirement)
+	}
+}
+
+// DeleteRequirement remove requirement passed as :kind (role or vehicle), :target and :type params
+func DeleteRequirement(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		requirement := db.Requirement{
+			Kind:            context.Param("kind"),
+			Target:          context.Param("target"),
+			CertificateType: context.Param("type"),
+		}
+		requirement.New(*s)
+
+		err := requirement.Delete()
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error deleting requirement: %v\n", err))
+		}
+		if err := audit(s, context, "requirement.delete", "requirement", requirement.Kind+"/"+requirement.Target, requirement, nil); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Requirement deleted")
+	}
+}
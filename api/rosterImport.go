@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"os"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/roster"
+	"strconv"
+)
+
+// ImportRoster backfill weekly roster tabs into the DB roster and return the import report
+//
+// Import can be repeated, already imported assignments are updated.
+// Missing week interval default to the whole year
+//
+// Request body:
+// {
+//		year: ISO year the roster spreadsheet refer to
+//		from_week: first week tab to import
+//		to_week: last week tab to import
+// }
+func ImportRoster(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			err          error
+			sheetService gsuite.Service
+			importer     roster.Importer
+		)
+
+		p := struct {
+			Year     int `json:"year"`
+			FromWeek int `json:"from_week"`
+			ToWeek   int `json:"to_week"`
+		}{}
+
+		// Bind request body to param struct
+		if err = context.Bind(&p); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+		if p.FromWeek == 0 {
+			p.FromWeek = 1
+		}
+		if p.ToWeek == 0 {
+			p.ToWeek = roster.WeeksInYear(p.Year)
+		}
+
+		// create new gsheet service
+		err = sheetService.New(os.Getenv("SHIFT_ID"))
+		if err != nil {
+			fmt.Printf("Error creating gSheet service: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gSheet service: %v\n", err))
+		}
+
+		err = importer.New(*s, sheetService.WithContext(s.Context()))
+		if err != nil {
+			fmt.Printf("Error preparing roster import: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error preparing roster import: %v\n", err))
+		}
+
+		report, err := importer.Import(p.Year, p.FromWeek, p.ToWeek)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error importing roster: %v\n", err))
+		}
+
+		fmt.Printf("Roster import %d: %d assignments imported, %d issues\n", report.Year, report.Imported, len(report.Issues))
+		if err := audit(s, context, "roster.import", "roster", strconv.Itoa(p.Year), nil, map[string]int{
+			"from_week": p.FromWeek,
+			"to_week":   p.ToWeek,
+			"imported":  report.Imported,
+			"issues":    len(report.Issues),
+		}); err != nil {
+			return auditFailed(context, err)
+		}
+		return context.JSON(http.StatusOK, report)
+	}
+}
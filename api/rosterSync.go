@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"net/http"
+	"os"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/roster"
+	"time"
+)
+
+// SyncRoster reconcile DB roster and spreadsheet for passed interval, return sync report with found conflicts
+//
+// Request body:
+// {
+//		from: first day to sync
+//		to: last day to sync
+// }
+func SyncRoster(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		p := struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		}{}
+
+		// Bind request body to param struct
+		if err := context.Bind(&p); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		syncer, err := newSyncer(s)
+		if err != nil {
+			fmt.Printf("Error preparing roster sync: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error preparing roster sync: %v\n", err))
+		}
+
+		report, err := syncer.Sync(p.From, p.To)
+		if err != nil {
+			fmt.Printf("Error syncing roster: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error syncing roster: %v\n", err))
+		}
+		if err := audit(s, context, "roster.sync", "roster", p.From.Format("20060102")+"-"+p.To.Format("20060102"), nil, report); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusOK, report)
+	}
+}
+
+// GetSyncConflicts return all open sync conflicts
+func GetSyncConflicts(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			conflict  db.SyncConflict
+			conflicts []db.SyncConflict
+		)
+
+		conflict.New(*s)
+		err := conflict.GetAllOpen(&conflicts)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving sync conflicts: %v\n", err))
+		}
+
+		return context.JSON(http.StatusOK, conflicts)
+	}
+}
+
+// ResolveSyncConflict settle conflict passed as :id param keeping spreadsheet or DB value
+//
+// Will read actual manager from JWT
+//
+// Request body:
+// {
+//		keep: one of "sheet" or "db"
+// }
+func ResolveSyncConflict(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var manager db.User
+
+		p := struct {
+			Keep string `json:"keep"`
+		}{}
+
+		// Bind request body to param struct
+		if err := context.Bind(&p); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		// Read user from JWT and retrieve manager ID
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		manager.New(*s)
+		if err := manager.GetUser(claims["username"].(string)); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error retrieving manager: %v\n", err))
+		}
+
+		syncer, err := newSyncer(s)
+		if err != nil {
+			fmt.Printf("Error preparing roster sync: %v\n", err)
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error preparing roster sync: %v\n", err))
+		}
+
+		err = syncer.Resolve(context.Param("id"), p.Keep, manager.Id)
+		if err != nil {
+			fmt.Printf("Error resolving sync conflict: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error resolving sync conflict: %v\n", err))
+		}
+		if err := audit(s, context, "sync_conflict.resolve", "sync_conflict", context.Param("id"), nil, map[string]string{"keep": p.Keep}); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "Sync conflict resolved")
+	}
+}
+
+// newSyncer create a roster syncer on SHIFT_ID spreadsheet
+func newSyncer(s *db.Service) (roster.Syncer, error) {
+	var (
+		sheetService gsuite.Service
+		syncer       roster.Syncer
+	)
+
+	err := sheetService.New(os.Getenv("SHIFT_ID"))
+	if err != nil {
+		return syncer, err
+	}
+	err = syncer.New(*s, sheetService.WithContext(s.Context()))
+	return syncer, err
+}
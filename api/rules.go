@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/roster"
+	"shift-manager/rules"
+)
+
+// violations is the body answered when a request break swap eligibility rules
+type violations struct {
+	Message    string            `json:"message"`
+	Violations []rules.Violation `json:"violations"`
+}
+
+// evaluateChange check the post-swap roster of (applicant) and (with) operators of change request (change)
+// against the configured swap eligibility rules
+func evaluateChange(s *db.Service, change db.ShiftChange, applicant roster.Operator, with roster.Operator) ([]rules.Violation, error) {
+	return evaluate(s, []rules.Handover{
+		{From: applicant, To: with, Date: change.ApplicantDate},
+		{From: with, To: applicant, Date: change.WithDate},
+	})
+}
+
+// evaluate check the roster of every operator involved in (handovers), as it would be after them, against
+// the configured swap eligibility rules
+func evaluate(s *db.Service, handovers []rules.Handover) ([]rules.Violation, error) {
+	source, err := roster.NewSource(s)
+	if err != nil {
+		return nil, err
+	}
+	return swapEngine(s).Evaluate(source, handovers)
+}
+
+// swapEngine return the configured swap eligibility rules, plus operator qualifications checked on DB.
+// Driving licences are operator certificates too
+func swapEngine(s *db.Service) rules.Engine {
+	qualification := db.Qualification{}
+	qualification.New(*s)
+
+	engine := rules.NewEngine(rules.ActiveConfig(), qualification)
+	engine.Rules = append(engine.Rules, rules.QualificationRule{Qualifications: qualification})
+	return engine
+}
+
+// ruleOverride return (manager) override of (broken) rules, nil if no rule is broken.
+// It's recorded by the roster saga when the request is applied, see roster.ApplyChange
+func ruleOverride(manager string, justification string, broken []rules.Violation) *db.RuleOverride {
+	if len(broken) == 0 {
+		return nil
+	}
+	override := &db.RuleOverride{Manager: manager, Justification: justification}
+	override.Violations, _ = json.Marshal(broken)
+	return override
+}
+
+// GetRuleOverrides return eligibility rules overridden accepting the request passed as :id param
+//
+// :kind param select the request kind, one of "change", "chain", "cover" or "offer". Change requests if missing
+func GetRuleOverrides(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			override  db.RuleOverride
+			overrides []db.RuleOverride
+		)
+
+		kind := context.Param("kind")
+		if kind == "" {
+			kind = db.OverrideChange
+		}
+		override.New(*s)
+		err := override.GetAllByTarget(kind, context.Param("id"), &overrides)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error retrieving rule overrides: %v\n", err))
+		}
+		if overrides == nil {
+			overrides = []db.RuleOverride{}
+		}
+		return context.JSON(http.StatusOK, overrides)
+	}
+}
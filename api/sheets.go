@@ -7,7 +7,10 @@ import (
 	"github.com/labstack/echo"
 	"net/http"
 	"os"
+	"shift-manager/db"
 	"shift-manager/gsuite"
+	"shift-manager/outbox"
+	"shift-manager/roster"
 	"strings"
 	"time"
 )
@@ -31,14 +34,10 @@ type shift struct {
 	ShiftEnd          time.Time `json:"shift_end"`
 }
 
-func PostShift() echo.HandlerFunc {
+// PostShift record a new timecard, it will be appended to Cartellini sheet by the outbox worker
+func PostShift(service *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
-		sheetService := gsuite.Service{}
-		err := sheetService.New(os.Getenv("SHEET_ID"))
-		if err != nil {
-			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gSheet service: %v\n", err))
-		}
-
+		service := service.WithContext(context.Request().Context())
 		var s shift
 		// Add post timestamp
 		s.Timestamp = time.Now()
@@ -54,7 +53,15 @@ func PostShift() echo.HandlerFunc {
 		operatorName := claims["opname"].(string)
 		s.Name = operatorName
 
-		err = s.setDefaults(strings.Split(operatorName, " ")[0])
+		// Gsheet service, roster reads are cancelled with the request
+		srv := gsuite.Service{}
+		err := srv.New(os.Getenv("SHIFT_ID"))
+		if err == nil {
+			var operator roster.Operator
+			if operator, err = operatorFromClaims(service, context); err == nil {
+				err = s.setDefaults(srv.WithContext(context.Request().Context()), operator.Labels())
+			}
+		}
 		if err != nil {
 			fmt.Printf("Cannot retrieve assigned shift data, falling back to declared: %v\n", err)
 		}
@@ -62,27 +69,26 @@ func PostShift() echo.HandlerFunc {
 		// d is data casted and ready to be appended to google sheet
 		var d [][]interface{}
 		d = append(d, s.marshalGSheet())
-		_, err = sheetService.Append("Cartellini!A4", d)
+		err = outbox.Enqueue(*service, os.Getenv("SHEET_ID"), gsuite.ActiveLayout().Request(gsuite.RequestShifts), d)
 		if err != nil {
-			return context.String(http.StatusBadRequest, fmt.Sprintf("Error posting data to Google sheet: %v\n", err))
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error recording timecard: %v\n", err))
 		}
-		return context.String(http.StatusCreated, "Succesfully posted data to Google sheet")
+		return context.String(http.StatusAccepted, "Timecard recorded, it will be posted to Google sheet shortly")
 	}
 }
 
-// setDefaults retrieve default shift from gsheet and set default value
-func (s *shift) setDefaults(name string) error {
+// setDefaults retrieve default shift of operator written as any of (labels) from roster spreadsheet (srv) and set default value
+func (s *shift) setDefaults(srv gsuite.Service, labels []string) error {
 	if s.ManualCompilation {
 		return nil
 	}
 
 	// Retrieve day coordinates
 	dayCoord := gsuite.DayCoord{}
-	dayCoord.New()
-
-	// Gsheet service
-	srv := gsuite.Service{}
-	srv.New(os.Getenv("SHIFT_ID"))
+	if err := dayCoord.Load(srv); err != nil {
+		fmt.Printf("Cannot retrieve day coordinates %v\n", err)
+		return errors.New("cannot retrieve day coordinates")
+	}
 
 	// Retrive today shift
 	todayShift, err := srv.ReadDay(dayCoord, s.Date)
@@ -92,7 +98,7 @@ func (s *shift) setDefaults(name string) error {
 	}
 
 	// Retrieve today role
-	todayRoles, err := srv.GetOperatorRoles(todayShift, name)
+	todayRoles, err := srv.ForDay(dayCoord, s.Date).GetOperatorRoles(todayShift, labels...)
 	if err != nil {
 		fmt.Printf("Cannot retrieve requested shoft roles, operator not found: %v\n", err)
 		return errors.New("cannot retrieve requested shoft roles, operator not found")
@@ -1,19 +1,20 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo"
 	"net/http"
-	"os"
 	"shift-manager/db"
 	"shift-manager/gsuite"
-	"strings"
+	"shift-manager/roster"
 	"time"
 )
 
 func GetAllFormData(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var response = struct {
 			Locations []db.Location     `json:"locations,omitempty"`
 			Shifts    []db.Shift        `json:"shifts,omitempty"`
@@ -72,79 +73,19 @@ func GetAllFormData(s *db.Service) echo.HandlerFunc {
 	}
 }
 
-func GetLoggedInOperatorShift() echo.HandlerFunc {
+func GetLoggedInOperatorShift(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
-		// Read operator name fom JWT
-		user := context.Get("user").(*jwt.Token)
-		claims := user.Claims.(jwt.MapClaims)
-		opFullName := claims["opname"]
-
-		// Extract operator surname from claim
-		name := strings.Split(opFullName.(string), " ")[0]
-
-		// Response struct to populate and return
-		var response = struct {
-			Locations db.Location     `json:"location"`
-			Shifts    db.Shift        `json:"shift"`
-			Vehicles  db.Vehicle      `json:"vehicle"`
-			Roles     db.OperatorRole `json:"role"`
-		}{}
-
+		s := s.WithContext(context.Request().Context())
 		// Roles retrieval
 		today := time.Now()
 
-		// Retrieve day coordinates
-		dayCoord := gsuite.DayCoord{}
-		dayCoord.New()
-
-		// Gsheet service
-		srv := gsuite.Service{}
-		srv.New(os.Getenv("SHIFT_ID"))
-
-		// Retrieve today shift
-		todayShift, err := srv.ReadDay(dayCoord, today)
-		if err != nil {
-			fmt.Printf("Cannot retrieve requested shift, no shift found: %v\n", err)
-			return context.String(http.StatusNotFound, "Cannot retrieve requested shift, no shift found")
-		}
-
-		// Retrieve today roles
-		todayRoles, err := srv.GetOperatorRoles(todayShift, name)
-		if err != nil {
-			fmt.Printf("cannot retrieve requested roles, operator not found: %v\n", err)
-			return context.String(http.StatusNotFound, "Cannot retrieve requested roles, operator not found")
-		}
-
-		// Populate roles struct
-		splitRoles := strings.Split(todayRoles, "|")
-		response.Locations.Name = splitRoles[0]
-		response.Shifts.Name = splitRoles[1]
-		response.Vehicles.Name = splitRoles[2]
-		response.Roles.Name = splitRoles[3]
-
-		// Return today shift
-		return context.JSON(http.StatusOK, response)
+		return loggedInOperatorShift(s, context, today)
 	}
 }
 
-func GetLoggedInOperatorShiftByDate() echo.HandlerFunc {
+func GetLoggedInOperatorShiftByDate(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
-		// Read operator name fom JWT
-		user := context.Get("user").(*jwt.Token)
-		claims := user.Claims.(jwt.MapClaims)
-		opFullName := claims["opname"]
-
-		// Extract operator surname from claim
-		name := strings.Split(opFullName.(string), " ")[0]
-
-		// Response struct to populate and return
-		var response = struct {
-			Locations db.Location     `json:"location"`
-			Shifts    db.Shift        `json:"shift"`
-			Vehicles  db.Vehicle      `json:"vehicle"`
-			Roles     db.OperatorRole `json:"role"`
-		}{}
-
+		s := s.WithContext(context.Request().Context())
 		// Roles retrieval
 		date, err := time.Parse("20060102", context.Param("date"))
 		if err != nil {
@@ -152,36 +93,88 @@ func GetLoggedInOperatorShiftByDate() echo.HandlerFunc {
 			return context.String(http.StatusBadRequest, "Malformed date param passed")
 		}
 
-		// Retrieve day coordinates
-		dayCoord := gsuite.DayCoord{}
-		dayCoord.New()
+		return loggedInOperatorShift(s, context, date)
+	}
+}
 
-		// Gsheet service
-		srv := gsuite.Service{}
-		srv.New(os.Getenv("SHIFT_ID"))
+// loggedInOperatorShift retrieve logged in operator's assignment on (date) from the configured roster source
+func loggedInOperatorShift(s *db.Service, context echo.Context, date time.Time) error {
+	// Response struct to populate and return
+	var response = struct {
+		Locations db.Location     `json:"location"`
+		Shifts    db.Shift        `json:"shift"`
+		Vehicles  db.Vehicle      `json:"vehicle"`
+		Roles     db.OperatorRole `json:"role"`
+		// Certificates required by role and vehicle operator doesn't hold on date
+		MissingCertificates []string `json:"missing_certificates"`
+	}{MissingCertificates: []string{}}
+
+	operator, err := operatorFromClaims(s, context)
+	var ambiguous *roster.AmbiguousLabelError
+	if errors.As(err, &ambiguous) {
+		fmt.Printf("Logged in operator label is ambiguous: %v\n", err)
+		return context.String(http.StatusConflict, fmt.Sprintf("Operator spreadsheet label is ambiguous, ask an admin to fix aliases: %v", err))
+	}
+	if err != nil {
+		fmt.Printf("Error retrieving logged in operator: %v\n", err)
+		return context.String(http.StatusBadRequest, "Error retrieving logged in operator")
+	}
 
-		// Retrieve today shift
-		todayShift, err := srv.ReadDay(dayCoord, date)
-		if err != nil {
-			fmt.Printf("Cannot retrieve requested shift, no shift found: %v\n", err)
-			return context.String(http.StatusNotFound, "Cannot retrieve requested shift, no shift found")
-		}
+	source, err := roster.NewSource(s)
+	if err != nil {
+		fmt.Printf("Error creating roster source: %v\n", err)
+		return context.String(http.StatusInternalServerError, "Error creating roster source")
+	}
 
-		// Retrieve today roles
-		todayRoles, err := srv.GetOperatorRoles(todayShift, name)
-		if err != nil {
-			fmt.Printf("cannot retrieve requested roles, operator not found: %v\n", err)
-			return context.String(http.StatusNotFound, "Cannot retrieve requested roles, operator not found")
-		}
+	// Retrieve day roles
+	assignment, err := source.Assignment(operator, date)
+	if gsuite.IsQuotaError(err) {
+		return sheetsUnavailable(context, err, "")
+	}
+	if err != nil {
+		fmt.Printf("Cannot retrieve requested shift: %v\n", err)
+		return context.String(http.StatusNotFound, "Cannot retrieve requested shift, operator not found")
+	}
 
-		// Populate roles struct
-		splitRoles := strings.Split(todayRoles, "|")
-		response.Locations.Name = splitRoles[0]
-		response.Shifts.Name = splitRoles[1]
-		response.Vehicles.Name = splitRoles[2]
-		response.Roles.Name = splitRoles[3]
+	// Populate roles struct
+	response.Locations.Name = assignment.Location
+	response.Shifts.Name = assignment.Shift
+	response.Vehicles.Name = assignment.Vehicle
+	response.Roles.Name = assignment.Role
+
+	// Flag assignments operator is not qualified for, without failing the lookup
+	qualification := db.Qualification{}
+	qualification.New(*s)
+	missing, err := qualification.Missing(operator.Id, assignment.Role, assignment.Vehicle, date)
+	if err != nil {
+		fmt.Printf("Cannot verify operator qualifications: %v\n", err)
+	} else if len(missing) > 0 {
+		fmt.Printf("Operator %s not qualified for %s on %s, missing %v\n", operator.Id, assignment.Role, assignment.Vehicle, missing)
+		response.MissingCertificates = missing
+	}
 
-		// Return today shift
-		return context.JSON(http.StatusOK, response)
+	// Return day shift
+	return context.JSON(http.StatusOK, response)
+}
+
+// operatorFromClaims build logged in roster operator reading username from JWT
+//
+// Spreadsheet labels are resolved through operator aliases
+func operatorFromClaims(s *db.Service, context echo.Context) (roster.Operator, error) {
+	user := context.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	username := claims["username"].(string)
+
+	u := db.User{}
+	u.New(*s)
+	err := u.GetUser(username)
+	if err != nil {
+		return roster.Operator{}, err
+	}
+
+	directory, err := roster.LoadDirectory(*s)
+	if err != nil {
+		return roster.Operator{}, err
 	}
+	return directory.Operator(u.Id)
 }
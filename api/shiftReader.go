@@ -6,25 +6,42 @@ import (
 	"github.com/labstack/echo"
 	"net/http"
 	"os"
+	"shift-manager/db"
 	"shift-manager/gsuite"
 )
 
-func GetPostedShifts() echo.HandlerFunc {
+// GetPostedShifts return last month of shifts posted by logged in operator
+//
+// Posted shifts are matched by operator full name and by its spreadsheet aliases
+func GetPostedShifts(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		service := s.WithContext(context.Request().Context())
 		// Read operator name JWT
 		user := context.Get("user").(*jwt.Token)
 		claims := user.Claims.(jwt.MapClaims)
-		n := claims["opname"].(string)
+		names := []string{claims["opname"].(string)}
+
+		operator, err := operatorFromClaims(service, context)
+		if err != nil {
+			fmt.Printf("Cannot resolve operator aliases, matching by name only: %v\n", err)
+		} else {
+			names = append(names, operator.Labels()...)
+		}
+
 		// Sheet shift service
 		s := gsuite.Service{}
-		err := s.New(os.Getenv("SHEET_ID"))
+		err = s.New(os.Getenv("SHEET_ID"))
 		if err != nil {
 			fmt.Printf("Error creating gsuite service: %v\n", err)
 			return context.String(http.StatusInternalServerError, fmt.Sprintf("Error creating gsuite service: %v\n", err))
 		}
+		s = s.WithContext(context.Request().Context())
 
 		// Get all operator's posted shifts
-		res, err := s.GetOperatorPostedShifts(n)
+		res, err := s.GetOperatorPostedShifts(names...)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
 		if err != nil {
 			fmt.Printf("No past shifts found: %v\n", err)
 			return context.String(http.StatusNotFound, fmt.Sprintf("No past shifts found: %v", err))
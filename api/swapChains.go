@@ -0,0 +1,242 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"net/http"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/roster"
+	"shift-manager/rules"
+)
+
+// RequestSwapChain create a new swap chain request, will be applied to roster after a manager accept it
+//
+// Every leg operator take the shift of the next leg, the last one take the shift of the first.
+// Logged in user must be one of the legs and every leg operator must be on shift on its date.
+// Broken swap eligibility rules don't block the request, they're answered along with it for the manager to override
+//
+// Request body:
+// {
+//		legs: [{operator: operator user UUID, date: date of the shift operator give away}, ...]
+// }
+func RequestSwapChain(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			err       error
+			requester db.User
+			chain     db.SwapChain
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		requester.New(*s)
+		err = requester.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error retrieving user's ID: %v\n", err))
+		}
+
+		chain.New(*s)
+		if err = context.Bind(&chain); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error binding request body: %v\n", err))
+		}
+		chain.Applicant = requester.Id
+		if err = db.ValidateLegs(chain.Applicant, chain.Legs); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error creating swap chain: %v\n", err))
+		}
+
+		// Make sure every leg is actually on the roster before storing the request
+		legs, err := chainLegs(s, chain)
+		if err != nil {
+			return labelError(context, err)
+		}
+		source, err := roster.NewSource(s)
+		if err != nil {
+			fmt.Printf("Error creating roster source: %v\n", err)
+			return context.String(http.StatusInternalServerError, "Error creating roster source")
+		}
+		err = roster.ValidateChain(source, legs)
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error validating swap chain: %v\n", err))
+		}
+		broken, err := evaluate(s, rules.ChainHandovers(legs))
+		if gsuite.IsQuotaError(err) {
+			return sheetsUnavailable(context, err, "")
+		}
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error checking swap chain: %v\n", err))
+		}
+
+		err = chain.NewRequest()
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("error creating swap chain: %v\n", err))
+		}
+		if err := audit(s, context, "chain.create", "swap_chain", chain.Id, nil, chain); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, struct {
+			db.SwapChain
+			Violations []rules.Violation `json:"violations,omitempty"`
+		}{chain, broken})
+	}
+}
+
+// GetAllSwapChains return every swap chain with its legs
+func GetAllSwapChains(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		var (
+			chain  db.SwapChain
+			chains []db.SwapChain
+		)
+
+		chain.New(*s)
+		err := chain.GetAll(&chains)
+		if err != nil {
+			return context.String(http.StatusInternalServerError, fmt.Sprintf("error retrieving swap chains: %v\n", err))
+		}
+		if chains == nil {
+			chains = []db.SwapChain{}
+		}
+
+		return context.JSON(http.StatusOK, chains)
+	}
+}
+
+// ManageSwapChain set a pending swap chain as accepted or rejected, accepted chains are applied to roster as a whole
+//
+// Will read actual manager from JWT and set timestamp automatically.
+//
+// Accepted chains are checked against swap eligibility rules like change requests, see ManageChangeRequest.
+//
+// Request body:
+// {
+//		id: swap chain id
+//		status: one of "rejected" or "accepted"
+//		justification: reason to override broken eligibility rules, optional
+// }
+func ManageSwapChain(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		type param struct {
+			Id            string `json:"id"`
+			Status        string `json:"status"`
+			Justification string `json:"justification"`
+		}
+
+		var (
+			err     error
+			p       param
+			manager db.User
+			chain   db.SwapChain
+		)
+
+		// Read user from JWT and extract claims
+		user := context.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		username := claims["username"].(string)
+
+		manager.New(*s)
+		err = manager.GetUser(username)
+		if err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("No manager found: %v\n", err))
+		}
+
+		if err = context.Bind(&p); err != nil {
+			fmt.Printf("Error binding request body: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+
+		chain.New(*s)
+		err = chain.GetById(p.Id)
+		if err != nil {
+			fmt.Printf("Error retrieving selected swap chain: %v\n", err)
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error retrieving selected swap chain: %v\n", err))
+		}
+		if chain.Status != db.RequestPending {
+			return context.String(http.StatusConflict, fmt.Sprintf("Swap chain already %s\n", chain.Status))
+		}
+		before := chain
+		chain.Manager = manager.Id
+		chain.Status = p.Status
+
+		if chain.Status == db.RequestAccepted {
+			legs, err := chainLegs(s, chain)
+			if err != nil {
+				return labelError(context, err)
+			}
+
+			// Roster may have changed since request creation, check eligibility again
+			broken, err := evaluate(s, rules.ChainHandovers(legs))
+			if gsuite.IsQuotaError(err) {
+				return sheetsUnavailable(context, err, "")
+			}
+			if err != nil {
+				fmt.Printf("Error checking swap chain: %v\n", err)
+				return context.String(http.StatusBadRequest, fmt.Sprintf("Error checking swap chain: %v\n", err))
+			}
+			if err = rules.Justify(broken, p.Justification); err != nil {
+				return context.JSON(http.StatusUnprocessableEntity, violations{Message: err.Error(), Violations: broken})
+			}
+			err = roster.ApplyChain(s, chain, legs, ruleOverride(manager.Id, p.Justification, broken))
+			if errors.Is(err, db.ErrSagaInFlight) {
+				return context.String(http.StatusConflict, "Swap chain is already being applied\n")
+			}
+			var conflict *gsuite.ConflictError
+			if errors.As(err, &conflict) {
+				fmt.Printf("Roster changed while rotating shifts: %v\n", err)
+				return context.String(http.StatusConflict, fmt.Sprintf("Roster changed while rotating shifts, retry: %v\n", err))
+			}
+			if gsuite.IsQuotaError(err) {
+				return sheetsUnavailable(context, err, "")
+			}
+			if err != nil {
+				fmt.Printf("Error rotating shifts: %v\n", err)
+				return context.String(http.StatusBadRequest, fmt.Sprintf("Error rotating shifts: %v\n", err))
+			}
+			if err := audit(s, context, "chain.accepted", "swap_chain", chain.Id, before, chain); err != nil {
+				return auditFailed(context, err)
+			}
+			return context.String(http.StatusOK, "swap chain managed")
+		}
+
+		err = chain.ChangeStatus()
+		if err != nil {
+			fmt.Printf("Error updating swap chain: %v\n", err)
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error updating swap chain: %v\n", err))
+		}
+		if err := audit(s, context, "chain."+chain.Status, "swap_chain", chain.Id, before, chain); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, "swap chain managed")
+	}
+}
+
+// chainLegs resolve (chain) leg operators spreadsheet labels through aliases
+func chainLegs(s *db.Service, chain db.SwapChain) ([]roster.Leg, error) {
+	directory, err := roster.LoadDirectory(*s)
+	if err != nil {
+		return nil, err
+	}
+
+	var legs []roster.Leg
+	for _, leg := range chain.Legs {
+		operator, err := directory.Operator(leg.Operator)
+		if err != nil {
+			return nil, err
+		}
+		legs = append(legs, roster.Leg{Operator: operator, Date: leg.Date})
+	}
+	return legs, nil
+}
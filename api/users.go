@@ -10,6 +10,7 @@ import (
 
 func Login(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		user := struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
@@ -31,6 +32,7 @@ func Login(s *db.Service) echo.HandlerFunc {
 
 func GetAllUserNames(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		var (
 			u     db.User
 			users []db.User
@@ -49,6 +51,7 @@ func GetAllUserNames(s *db.Service) echo.HandlerFunc {
 
 func GetUserDetailsFromClaims(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		// Read user from JWT and extract username claim
 		user := context.Get("user").(*jwt.Token)
 		claims := user.Claims.(jwt.MapClaims)
@@ -69,6 +72,7 @@ func GetUserDetailsFromClaims(s *db.Service) echo.HandlerFunc {
 // TODO: Refactor admin check using middleware (checkIfRole)
 func ResetPwd(s *db.Service) echo.HandlerFunc {
 	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
 		// Read user from JWT and extract claims
 		user := context.Get("user").(*jwt.Token)
 		claims := user.Claims.(jwt.MapClaims)
@@ -93,12 +97,81 @@ func ResetPwd(s *db.Service) echo.HandlerFunc {
 			if err != nil {
 				return context.String(http.StatusBadRequest, fmt.Sprintf("Error resetting password for user %v: %v", r.Username, err))
 			}
+			// Passwords are never recorded, not even hashed
+			if err := audit(s, context, "user.password_reset", "user", r.Username, nil, map[string]bool{"password_changed": true}); err != nil {
+				return auditFailed(context, err)
+			}
 			return context.String(http.StatusOK, fmt.Sprintf("Password reset for user %v", r.Username))
 		}
 		return context.String(http.StatusUnauthorized, "User is not admin, can't reset password")
 	}
 }
 
+// CreateUser add a new user able to login
+//
+// Request body:
+// {
+//		username: login username, unique
+//		password: login password
+// }
+func CreateUser(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		r := struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}{}
+
+		if err := context.Bind(&r); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error binding request body: %v\n", err))
+		}
+		if r.Username == "" || r.Password == "" {
+			return context.String(http.StatusBadRequest, "Username and password are required")
+		}
+
+		u := db.User{}
+		u.New(*s)
+		err := u.CreateUser(r.Username, r.Password)
+		if err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error creating user %v: %v", r.Username, err))
+		}
+		u.Username = r.Username
+		if err := audit(s, context, "user.create", "user", r.Username, nil, map[string]string{"id": u.Id, "username": u.Username}); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.JSON(http.StatusCreated, map[string]string{"id": u.Id, "username": u.Username})
+	}
+}
+
+// DeleteUser remove user passed as :username param
+func DeleteUser(s *db.Service) echo.HandlerFunc {
+	return func(context echo.Context) error {
+		s := s.WithContext(context.Request().Context())
+		username := context.Param("username")
+
+		u := db.User{}
+		u.New(*s)
+		if err := u.GetUser(username); err != nil {
+			return context.String(http.StatusNotFound, fmt.Sprintf("Error retrieving user %v: %v", username, err))
+		}
+		before := struct {
+			Id       string   `json:"id"`
+			Username string   `json:"username"`
+			Roles    []string `json:"roles"`
+		}{u.Id, u.Username, u.Roles}
+
+		if err := u.DeleteUser(username); err != nil {
+			return context.String(http.StatusBadRequest, fmt.Sprintf("Error deleting user %v: %v", username, err))
+		}
+		if err := audit(s, context, "user.delete", "user", username, before, nil); err != nil {
+			return auditFailed(context, err)
+		}
+
+		return context.String(http.StatusOK, fmt.Sprintf("User %v deleted", username))
+	}
+}
+
 // Check if the passed claim contain "admin" role
 // i: jwt role claim
 func checkIfAdmin(i []interface{}) bool {
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// auditLockKey is the advisory lock serializing audit log appends, so every entry chain to the last one
+const auditLockKey = 7474747
+
+// AuditEntry is a mutation recorded on the append-only audit log
+type AuditEntry struct {
+	service    Service
+	Id         int64           `json:"id"`
+	Actor      string          `json:"actor"`  // Username from JWT
+	Action     string          `json:"action"` // Like user.create or change.accepted
+	TargetType string          `json:"target_type"`
+	TargetId   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Ip         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func (e *AuditEntry) New(s Service) {
+	e.service = s
+}
+
+// AuditFilter narrow audit log queries, zero fields don't filter
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetId   string
+	From       time.Time
+	To         time.Time
+	Limit      int // Default 100
+}
+
+// ComputeHash return entry hash, chained to previous entry (prev) hash
+func (e AuditEntry) ComputeHash(prev string) string {
+	// Encoding a list keeps field boundaries unambiguous
+	fields, _ := json.Marshal([]string{
+		prev,
+		e.Actor,
+		e.Action,
+		e.TargetType,
+		e.TargetId,
+		string(e.Before),
+		string(e.After),
+		e.Ip,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+// Append record the entry, chaining it to the last one. Actor, Action, TargetType and TargetId must be populated
+//
+// Id, CreatedAt, PrevHash and Hash are set on success
+func (e *AuditEntry) Append() error {
+	if e.Actor == "" || e.Action == "" || e.TargetType == "" || e.TargetId == "" {
+		return errors.New("actor, action, target type and target id are required")
+	}
+
+	tx, err := e.service.Db.BeginTx(e.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	// Held until commit, concurrent appends would chain to the same entry otherwise
+	if _, err = tx.ExecContext(e.service.Context(), `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return errors.New(fmt.Sprintf("error locking audit log: %v\n", err))
+	}
+
+	var prev string
+	err = tx.QueryRowContext(e.service.Context(), `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New(fmt.Sprintf("error retrieving last audit entry: %v\n", err))
+	}
+
+	// Postgres keep microseconds, hash what will be read back
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	e.PrevHash = prev
+	e.Hash = e.ComputeHash(prev)
+
+	sqlStatement := `
+					INSERT INTO audit_log (actor, action, target_type, target_id, before, after, ip, created_at, prev_hash, hash)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+					RETURNING id
+`
+	err = tx.QueryRowContext(e.service.Context(), sqlStatement, e.Actor, e.Action, e.TargetType, e.TargetId,
+		nullJson(e.Before), nullJson(e.After), e.Ip, e.CreatedAt, e.PrevHash, e.Hash).Scan(&e.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending audit entry: %v\n", err))
+	}
+	return tx.Commit()
+}
+
+// nullJson store empty values as NULL
+func nullJson(v json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(v), Valid: len(v) > 0}
+}
+
+const sqlAuditEntries = `SELECT id, actor, action, target_type, target_id,
+						   COALESCE(CAST(before as text), ''), COALESCE(CAST(after as text), ''),
+						   ip, created_at, prev_hash, hash
+					FROM audit_log
+`
+
+// GetAll retrieve entries matching (filter), newest first
+func (e AuditEntry) GetAll(filter AuditFilter, dest *[]AuditEntry) error {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	sqlStatement := sqlAuditEntries + `
+					WHERE ($1 = '' OR actor = $1)
+					  AND ($2 = '' OR action = $2)
+					  AND ($3 = '' OR target_type = $3)
+					  AND ($4 = '' OR target_id = $4)
+					  AND (CAST($5 as timestamptz) IS NULL OR created_at >= $5)
+					  AND (CAST($6 as timestamptz) IS NULL OR created_at < $6)
+					ORDER BY id DESC
+					LIMIT $7`
+	from := sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()}
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+	return e.query(dest, sqlStatement, filter.Actor, filter.Action, filter.TargetType, filter.TargetId, from, to, filter.Limit)
+}
+
+func (e AuditEntry) query(dest *[]AuditEntry, sqlStatement string, args ...interface{}) error {
+	rows, err := e.service.Db.QueryContext(e.service.Context(), sqlStatement, args...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving audit entries: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry         AuditEntry
+			before, after string
+		)
+		err = rows.Scan(&entry.Id, &entry.Actor, &entry.Action, &entry.TargetType, &entry.TargetId, &before, &after,
+			&entry.Ip, &entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		if before != "" {
+			entry.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			entry.After = json.RawMessage(after)
+		}
+		*dest = append(*dest, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+	return nil
+}
+
+// ChainError is returned when an audit entry doesn't match its hash or doesn't chain to the previous one
+type ChainError struct {
+	Id     int64  `json:"id"` // First entry breaking the chain
+	Reason string `json:"reason"`
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", e.Id, e.Reason)
+}
+
+// Verify walk the whole audit log checking the hash chain, return the number of entries checked
+//
+// Return a *ChainError on the first entry altered, or following a removed one
+func (e AuditEntry) Verify() (int, error) {
+	var entries []AuditEntry
+	if err := e.query(&entries, sqlAuditEntries+`ORDER BY id`); err != nil {
+		return 0, err
+	}
+	return len(entries), VerifyChain(entries)
+}
+
+// VerifyChain check (entries), oldest first and starting from the first entry ever, chain to each other
+func VerifyChain(entries []AuditEntry) error {
+	prev := ""
+	for _, entry := range entries {
+		if entry.PrevHash != prev {
+			return &ChainError{Id: entry.Id, Reason: "previous hash mismatch, an entry was removed or altered"}
+		}
+		if entry.ComputeHash(prev) != entry.Hash {
+			return &ChainError{Id: entry.Id, Reason: "hash mismatch, entry was altered"}
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
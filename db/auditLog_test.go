@@ -0,0 +1,75 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// auditChain return (n) entries properly chained to each other
+func auditChain(n int) []AuditEntry {
+	var (
+		entries []AuditEntry
+		prev    string
+	)
+	for i := 1; i <= n; i++ {
+		entry := AuditEntry{
+			Id:         int64(i),
+			Actor:      "admin",
+			Action:     "user.password_reset",
+			TargetType: "user",
+			TargetId:   "rossi",
+			After:      json.RawMessage(`{"password":"reset"}`),
+			Ip:         "10.0.0.1",
+			CreatedAt:  time.Date(2020, 1, 6, 9, i, 0, 0, time.UTC),
+			PrevHash:   prev,
+		}
+		entry.Hash = entry.ComputeHash(prev)
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]AuditEntry) []AuditEntry
+		wantId int64 // 0 if chain is intact
+	}{
+		{name: "Intact", tamper: func(e []AuditEntry) []AuditEntry { return e }},
+		{name: "Empty", tamper: func(e []AuditEntry) []AuditEntry { return nil }},
+		{name: "Value altered", tamper: func(e []AuditEntry) []AuditEntry {
+			e[1].After = json.RawMessage(`{"password":"unchanged"}`)
+			return e
+		}, wantId: 2},
+		{name: "Timestamp altered", tamper: func(e []AuditEntry) []AuditEntry {
+			e[2].CreatedAt = e[2].CreatedAt.Add(time.Microsecond)
+			return e
+		}, wantId: 3},
+		{name: "Entry removed", tamper: func(e []AuditEntry) []AuditEntry {
+			return append(e[:1], e[2:]...)
+		}, wantId: 3},
+		{name: "Entry rehashed", tamper: func(e []AuditEntry) []AuditEntry {
+			e[1].Actor = "manager"
+			e[1].Hash = e[1].ComputeHash(e[1].PrevHash)
+			return e
+		}, wantId: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChain(tt.tamper(auditChain(4)))
+			var chain *ChainError
+			if tt.wantId == 0 {
+				if err != nil {
+					t.Errorf("VerifyChain() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &chain) || chain.Id != tt.wantId {
+				t.Errorf("VerifyChain() error = %v, want chain broken at %d", err, tt.wantId)
+			}
+		})
+	}
+}
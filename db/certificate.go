@@ -0,0 +1,173 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateType is a certification operators may hold, like BLSD or an ambulance driving licence
+type CertificateType struct {
+	service     Service
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (c *CertificateType) New(s Service) {
+	c.service = s
+}
+
+// GetAll retrieve every certificate type ordered by name
+func (c CertificateType) GetAll(dest *[]CertificateType) error {
+	sqlStatement := `SELECT id, name, description FROM certificate_types ORDER BY name`
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving certificate types: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var certificateType CertificateType
+		err = rows.Scan(&certificateType.Id, &certificateType.Name, &certificateType.Description)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, certificateType)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+	return nil
+}
+
+// Create insert a new certificate type, Name must be populated. Id is set on success
+func (c *CertificateType) Create() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+
+	sqlStatement := `INSERT INTO certificate_types (name, description) VALUES ($1, $2) RETURNING id`
+	err := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, c.Name, strings.TrimSpace(c.Description)).Scan(&c.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating certificate type: %v\n", err))
+	}
+	return nil
+}
+
+// Delete remove certificate type (id), along with certificates of that type and requirements on it
+func (c CertificateType) Delete(id string) error {
+	sqlStatement := `DELETE FROM certificate_types WHERE id = $1`
+	res, err := c.service.Db.ExecContext(c.service.Context(), sqlStatement, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error deleting certificate type: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no certificate type with passed id")
+	}
+	return nil
+}
+
+// OperatorCertificate is a certificate held by an operator, ExpiresOn is zero for certificates that never expire
+type OperatorCertificate struct {
+	service         Service
+	Id              string    `json:"id"`
+	Operator        string    `json:"operator"`         // User UUID
+	CertificateType string    `json:"certificate_type"` // Certificate type UUID
+	TypeName        string    `json:"type_name"`
+	IssuedOn        time.Time `json:"issued_on"`
+	ExpiresOn       time.Time `json:"expires_on,omitempty"`
+}
+
+func (c *OperatorCertificate) New(s Service) {
+	c.service = s
+}
+
+const sqlOperatorCertificates = `SELECT oc.id, oc.operator, oc.certificate_type, ct.name, oc.issued_on, COALESCE(oc.expires_on, $1)
+					FROM operator_certificates oc
+					INNER JOIN certificate_types ct ON oc.certificate_type = ct.id
+`
+
+// GetAll retrieve certificates held by (operator), every operator certificate if empty
+func (c OperatorCertificate) GetAll(operator string, dest *[]OperatorCertificate) error {
+	sqlStatement := sqlOperatorCertificates + `
+					WHERE $2 = '' OR CAST(oc.operator as varchar) = $2
+					ORDER BY oc.operator, ct.name, oc.issued_on`
+	return c.query(dest, sqlStatement, time.Time{}, operator)
+}
+
+// GetExpiring retrieve certificates expiring by (until), already expired ones included, soonest first
+//
+// Certificates renewed by a later one of the same type are left out
+func (c OperatorCertificate) GetExpiring(until time.Time, dest *[]OperatorCertificate) error {
+	sqlStatement := sqlOperatorCertificates + `
+					WHERE oc.expires_on <= $2
+					  AND NOT EXISTS(SELECT 1
+					                 FROM operator_certificates renewed
+					                 WHERE renewed.operator = oc.operator
+					                   AND renewed.certificate_type = oc.certificate_type
+					                   AND (renewed.expires_on IS NULL OR renewed.expires_on > oc.expires_on))
+					ORDER BY oc.expires_on, oc.operator`
+	return c.query(dest, sqlStatement, time.Time{}, until)
+}
+
+func (c OperatorCertificate) query(dest *[]OperatorCertificate, sqlStatement string, args ...interface{}) error {
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, args...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving operator certificates: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var certificate OperatorCertificate
+		err = rows.Scan(&certificate.Id, &certificate.Operator, &certificate.CertificateType, &certificate.TypeName, &certificate.IssuedOn, &certificate.ExpiresOn)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, certificate)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+	return nil
+}
+
+// Create record a certificate, Operator, CertificateType and IssuedOn must be populated. Id and TypeName are set on success
+func (c *OperatorCertificate) Create() error {
+	if c.Operator == "" || c.CertificateType == "" || c.IssuedOn.IsZero() {
+		return errors.New("operator, certificate type and issue date are required")
+	}
+	if !c.ExpiresOn.IsZero() && c.ExpiresOn.Before(c.IssuedOn) {
+		return errors.New("certificate cannot expire before being issued")
+	}
+
+	sqlStatement := `
+		INSERT INTO operator_certificates (operator, certificate_type, issued_on, expires_on)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, (SELECT name FROM certificate_types WHERE id = $2)
+`
+	expiresOn := sql.NullTime{Time: c.ExpiresOn, Valid: !c.ExpiresOn.IsZero()}
+	err := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, c.Operator, c.CertificateType, c.IssuedOn, expiresOn).Scan(&c.Id, &c.TypeName)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error recording operator certificate: %v\n", err))
+	}
+	return nil
+}
+
+// Delete remove certificate (id)
+func (c OperatorCertificate) Delete(id string) error {
+	sqlStatement := `DELETE FROM operator_certificates WHERE id = $1`
+	res, err := c.service.Db.ExecContext(c.service.Context(), sqlStatement, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error deleting operator certificate: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no operator certificate with passed id")
+	}
+	return nil
+}
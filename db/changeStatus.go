@@ -0,0 +1,46 @@
+package db
+
+import "fmt"
+
+// Shift change statuses
+//
+// Requests start pending, waiting for the counterpart (with) operator. If accepted they reach the manager
+// queue, if declined they're rejected without a manager. Accepted requests become applied once the roster
+// is swapped, until then they may be retried, cancelled by the applicant or expire
+const (
+	ChangePending             = "pending"
+	ChangeCounterpartAccepted = "counterpart_accepted"
+	ChangeAccepted            = "accepted"
+	ChangeRejected            = "rejected"
+	ChangeCancelled           = "cancelled"
+	ChangeExpired             = "expired"
+	ChangeApplied             = "applied"
+)
+
+// changeTransitions list legal status changes, keep in sync with shift_change_transition() trigger
+var changeTransitions = map[string][]string{
+	ChangePending:             {ChangeCounterpartAccepted, ChangeRejected, ChangeCancelled, ChangeExpired},
+	ChangeCounterpartAccepted: {ChangeAccepted, ChangeRejected, ChangeCancelled, ChangeExpired},
+	ChangeAccepted:            {ChangeApplied, ChangeCancelled, ChangeExpired},
+}
+
+// CanTransition tell if a change request may move from status (from) to status (to)
+func CanTransition(from string, to string) bool {
+	for _, next := range changeTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when a change request status change is not allowed
+type TransitionError struct {
+	Id   string // Change request UUID
+	From string // Status change request is in
+	To   string // Status requested
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("change request %s cannot move from %q to %q", e.Id, e.From, e.To)
+}
@@ -0,0 +1,49 @@
+package db
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{ChangePending, ChangeCounterpartAccepted, true},
+		{ChangePending, ChangeRejected, true},
+		{ChangePending, ChangeAccepted, false}, // Counterpart must accept first
+		{ChangeCounterpartAccepted, ChangeAccepted, true},
+		{ChangeCounterpartAccepted, ChangeApplied, false},
+		{ChangeCounterpartAccepted, "acepted", false}, // Typo
+		{ChangeAccepted, ChangeApplied, true},
+		{ChangeAccepted, ChangeAccepted, false}, // Accepting twice would swap twice
+		{ChangeAccepted, ChangeCancelled, true},
+		{ChangeApplied, ChangeCancelled, false},
+		{ChangeRejected, ChangeAccepted, false},
+		{ChangeExpired, ChangePending, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShiftChange_transitionIllegal(t *testing.T) {
+	// Illegal transitions are refused before reaching DB, managers only accept or reject
+	for _, status := range []string{"acepted", ChangeCounterpartAccepted, ChangeCancelled, ChangeExpired, ChangeApplied} {
+		t.Run(status, func(t *testing.T) {
+			s := ShiftChange{Id: "a4bf72a0-e3ea-4f5d-8d1f-1d3f456791ca", Status: status}
+			err := s.ChangeStatus()
+
+			transition, ok := err.(*TransitionError)
+			if !ok {
+				t.Fatalf("ChangeStatus() error = %v, want *TransitionError", err)
+			}
+			if transition.From != ChangeCounterpartAccepted || transition.To != status {
+				t.Errorf("ChangeStatus() error = %+v", transition)
+			}
+		})
+	}
+}
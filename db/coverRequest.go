@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CoverRequest is a request to hand applicant shift on ApplicantDate to Covering operator, who must be free that day
+type CoverRequest struct {
+	service           Service
+	Id                string    `json:"id"`
+	Applicant         string    `json:"applicant"` // User UUID
+	ApplicantDate     time.Time `json:"applicant_date"`
+	Covering          string    `json:"covering"` // User UUID
+	Manager           string    `json:"manager,omitempty"`
+	Status            string    `json:"status"`
+	RequestTimestamp  time.Time `json:"request_timestamp"`
+	ResponseTimestamp time.Time `json:"response_timestamp,omitempty"`
+}
+
+func (c *CoverRequest) New(s Service) {
+	c.service = s
+}
+
+// NewRequest store a new pending cover request, Applicant, ApplicantDate and Covering must be populated. Id is set on success
+func (c *CoverRequest) NewRequest() error {
+	if c.Applicant == "" || c.ApplicantDate.IsZero() || c.Covering == "" {
+		return errors.New(fmt.Sprintf(
+			"Not all required fields supplied: applicant: %v %v - covering: %v",
+			c.Applicant,
+			c.ApplicantDate,
+			c.Covering,
+		))
+	}
+	if c.Applicant == c.Covering {
+		return errors.New("applicant can't cover its own shift")
+	}
+
+	sqlStatement := `
+					INSERT INTO cover_requests (applicant, applicant_date, covering)
+					VALUES ($1, $2, $3)
+					RETURNING id, status, request_timestamp
+`
+	err := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, c.Applicant, c.ApplicantDate, c.Covering).Scan(&c.Id, &c.Status, &c.RequestTimestamp)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating new cover request: %v\n", err))
+	}
+	return nil
+}
+
+// GetById retrieve cover request (id), return error if not found
+func (c *CoverRequest) GetById(id string) error {
+	nullTime := time.Time{}
+	sqlStatement := `SELECT id,
+						   applicant,
+						   applicant_date,
+						   covering,
+						   COALESCE(CAST(manager_name as varchar), '') as manager_name,
+						   status,
+						   request_timestamp,
+						   COALESCE(response_timestamp, $2) as response_timestamp
+					FROM cover_requests
+					WHERE id = $1`
+
+	row := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, id, nullTime)
+	switch err := row.Scan(&c.Id, &c.Applicant, &c.ApplicantDate, &c.Covering, &c.Manager, &c.Status, &c.RequestTimestamp, &c.ResponseTimestamp); err {
+	case sql.ErrNoRows:
+		return errors.New("no cover request with passed id")
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("error retrieving cover request from database: %v\n", err))
+	}
+}
+
+// GetAll retrieve every cover request, newest first. If (operator) is not empty only requests
+// it's applicant or covering operator of are retrieved
+func (c CoverRequest) GetAll(operator string, dest *[]CoverRequest) error {
+	nullTime := time.Time{}
+	sqlStatement := `SELECT id,
+						   applicant,
+						   applicant_date,
+						   covering,
+						   COALESCE(CAST(manager_name as varchar), '') as manager_name,
+						   status,
+						   request_timestamp,
+						   COALESCE(response_timestamp, $1) as response_timestamp
+					FROM cover_requests
+					WHERE $2 = '' OR CAST(applicant as varchar) = $2 OR CAST(covering as varchar) = $2
+					ORDER BY request_timestamp DESC`
+
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, nullTime, operator)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving cover requests: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cover CoverRequest
+		err = rows.Scan(&cover.Id, &cover.Applicant, &cover.ApplicantDate, &cover.Covering, &cover.Manager, &cover.Status, &cover.RequestTimestamp, &cover.ResponseTimestamp)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, cover)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// ChangeStatus set pending cover request as accepted or rejected by Manager, Id, Manager and Status must be populated
+func (c *CoverRequest) ChangeStatus() error {
+	if c.Status != RequestAccepted && c.Status != RequestRejected {
+		return errors.New(fmt.Sprintf("invalid cover request status: %q", c.Status))
+	}
+
+	return c.changeStatus(c.service.Db)
+}
+
+// changeStatus is ChangeStatus through (e), either the DB or a running transaction
+func (c *CoverRequest) changeStatus(e execer) error {
+	timestamp := time.Now()
+	sqlStatement := `
+					UPDATE cover_requests
+					SET manager_name=$2,
+					    status=$3,
+					    response_timestamp=$4
+					WHERE id=$1 AND status=$5
+`
+	res, err := e.ExecContext(c.service.Context(), sqlStatement, c.Id, c.Manager, c.Status, timestamp, RequestPending)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating cover request status: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no pending cover request with passed id")
+	}
+	c.ResponseTimestamp = timestamp
+	return nil
+}
@@ -21,7 +21,7 @@ func (l *Location) New(s Service) {
 
 func (l *Location) Get(name string) error {
 	sqlStatement := `SELECT id, name, geo[0],geo[1], address, "order" FROM locations WHERE name = $1`
-	row := l.service.Db.QueryRow(sqlStatement, name)
+	row := l.service.Db.QueryRowContext(l.service.Context(), sqlStatement, name)
 	switch err := row.Scan(&l.Id, &l.Name, &l.Geo.Latitude, &l.Geo.Longitude, &l.Address, &l.Order); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -34,7 +34,7 @@ func (l *Location) Get(name string) error {
 
 func (l *Location) GetAll(dest *[]Location) error {
 	sqlStatement := `SELECT id, name, geo[0],geo[1], address, "order" FROM locations`
-	rows, err := l.service.Db.Query(sqlStatement)
+	rows, err := l.service.Db.QueryContext(l.service.Context(), sqlStatement)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving locations: %v\n", err))
 	}
@@ -0,0 +1,23 @@
+-- Roster stored natively in Postgres, alternative source to the weekly spreadsheet tabs.
+-- Assignments reference the existing catalog tables (locations, shifts, vehicles, operator_roles).
+
+CREATE TABLE IF NOT EXISTS roster_days
+(
+    id   uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    date date NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS roster_assignments
+(
+    id         uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    day        uuid        NOT NULL REFERENCES roster_days (id) ON DELETE CASCADE,
+    operator   uuid        NOT NULL REFERENCES users (id),
+    location   uuid        NOT NULL REFERENCES locations (id),
+    shift      uuid        NOT NULL REFERENCES shifts (id),
+    vehicle    uuid REFERENCES vehicles (id),
+    role       uuid        NOT NULL REFERENCES operator_roles (id),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (day, operator, shift)
+);
+
+CREATE INDEX IF NOT EXISTS roster_assignments_operator_idx ON roster_assignments (operator);
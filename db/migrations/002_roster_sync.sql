@@ -0,0 +1,35 @@
+-- Bidirectional sync between DB roster and spreadsheet.
+-- Assignments remember the spreadsheet cell they're mirrored to, the snapshot table hold
+-- the value each cell had at last sync, so edits on either side can be told apart.
+
+ALTER TABLE roster_assignments
+    ADD COLUMN IF NOT EXISTS cell text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS roster_assignments_cell_idx ON roster_assignments (day, cell);
+
+CREATE TABLE IF NOT EXISTS roster_sync_cells
+(
+    date      date        NOT NULL,
+    cell      text        NOT NULL,
+    value     text        NOT NULL DEFAULT '',
+    synced_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (date, cell)
+);
+
+CREATE TABLE IF NOT EXISTS roster_sync_conflicts
+(
+    id           uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    date         date        NOT NULL,
+    cell         text        NOT NULL,
+    base_value   text        NOT NULL DEFAULT '',
+    sheet_value  text        NOT NULL DEFAULT '',
+    db_value     text        NOT NULL DEFAULT '',
+    status       varchar     NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution   varchar CHECK (resolution IN ('sheet', 'db')),
+    manager_name uuid REFERENCES users (id),
+    detected_at  timestamptz NOT NULL DEFAULT now(),
+    resolved_at  timestamptz
+);
+
+-- At most one open conflict per cell
+CREATE UNIQUE INDEX IF NOT EXISTS roster_sync_conflicts_open_idx ON roster_sync_conflicts (date, cell) WHERE status = 'open';
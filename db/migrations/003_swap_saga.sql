@@ -0,0 +1,24 @@
+-- Swap sagas track an accepted change request while it's applied to the roster and then committed to DB,
+-- so a failure between the two steps can be compensated or recovered after a crash.
+
+CREATE TABLE IF NOT EXISTS swap_sagas
+(
+    id              uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    change          uuid        NOT NULL REFERENCES shift_change (id),
+    source          varchar     NOT NULL,
+    status          varchar     NOT NULL DEFAULT 'started'
+        CHECK (status IN ('started', 'roster_applied', 'committed', 'compensated', 'failed')),
+    manager_name    uuid        NOT NULL REFERENCES users (id),
+    change_status   varchar     NOT NULL,
+    first_operator  uuid        NOT NULL REFERENCES users (id),
+    first_label     text        NOT NULL,
+    first_date      timestamptz NOT NULL,
+    second_operator uuid        NOT NULL REFERENCES users (id),
+    second_label    text        NOT NULL,
+    second_date     timestamptz NOT NULL,
+    last_error      text        NOT NULL DEFAULT '',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    updated_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS swap_sagas_pending_idx ON swap_sagas (status) WHERE status IN ('started', 'roster_applied');
@@ -0,0 +1,18 @@
+-- Outbox for spreadsheet appends: submissions are stored here first and delivered
+-- to Google Sheets by a background worker with retries.
+
+CREATE TABLE IF NOT EXISTS sheet_outbox
+(
+    id              uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    sheet_id        text        NOT NULL,
+    range           text        NOT NULL,
+    payload         jsonb       NOT NULL,
+    status          varchar     NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        int         NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error      text        NOT NULL DEFAULT '',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    delivered_at    timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS sheet_outbox_due_idx ON sheet_outbox (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,21 @@
+-- Operator aliases link every operator to the labels they're written with on the roster spreadsheet.
+-- Labels are stored uppercase like spreadsheet values. The same label may belong to more than one operator,
+-- lookups report it as ambiguous until an admin fix it.
+
+CREATE TABLE IF NOT EXISTS operator_aliases
+(
+    id         uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    operator   uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    label      text        NOT NULL CHECK (label <> '' AND label = upper(btrim(label))),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (operator, label)
+);
+
+CREATE INDEX IF NOT EXISTS operator_aliases_label_idx ON operator_aliases (label);
+
+-- Seed aliases with surnames, the label every operator was matched by so far
+INSERT INTO operator_aliases (operator, label)
+SELECT "user", upper(btrim(surname))
+FROM operators
+WHERE btrim(surname) <> ''
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,22 @@
+-- Swap chains are shift change requests among more than two operators, applied as a cycle:
+-- every leg operator take the shift of the next leg, the last one take the shift of the first.
+-- A chain is approved or rejected by a manager as a whole.
+
+CREATE TABLE IF NOT EXISTS swap_chains
+(
+    id                 uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    applicant          uuid        NOT NULL REFERENCES users (id),
+    status             text        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    manager_name       uuid REFERENCES users (id),
+    request_timestamp  timestamptz NOT NULL DEFAULT now(),
+    response_timestamp timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS swap_chain_legs
+(
+    chain    uuid        NOT NULL REFERENCES swap_chains (id) ON DELETE CASCADE,
+    position int         NOT NULL CHECK (position >= 0),
+    operator uuid        NOT NULL REFERENCES users (id),
+    date     timestamptz NOT NULL,
+    PRIMARY KEY (chain, position)
+);
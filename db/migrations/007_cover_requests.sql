@@ -0,0 +1,13 @@
+-- Cover requests hand an applicant shift to a free colleague, nothing is swapped back.
+
+CREATE TABLE IF NOT EXISTS cover_requests
+(
+    id                 uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    applicant          uuid        NOT NULL REFERENCES users (id),
+    applicant_date     timestamptz NOT NULL,
+    covering           uuid        NOT NULL REFERENCES users (id) CHECK (covering <> applicant),
+    status             text        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    manager_name       uuid REFERENCES users (id),
+    request_timestamp  timestamptz NOT NULL DEFAULT now(),
+    response_timestamp timestamptz
+);
@@ -0,0 +1,30 @@
+-- Shift offers are shifts operators need to drop, posted to a board where free colleagues bid for them.
+-- Assignment details are copied from the roster when posting so the board can be filtered without reading it.
+-- Awarding an offer record an applied shift_change, applicant handing the shift to the winning bidder on the same date.
+
+CREATE TABLE IF NOT EXISTS shift_offers
+(
+    id         uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    applicant  uuid        NOT NULL REFERENCES users (id),
+    date       timestamptz NOT NULL,
+    location   text        NOT NULL DEFAULT '',
+    shift      text        NOT NULL DEFAULT '',
+    vehicle    text        NOT NULL DEFAULT '',
+    role       text        NOT NULL DEFAULT '',
+    status     text        NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'awarded', 'withdrawn')),
+    awarded_to uuid REFERENCES users (id),
+    change     uuid,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    closed_at  timestamptz
+);
+
+-- A single open offer per applicant shift
+CREATE UNIQUE INDEX IF NOT EXISTS shift_offers_open_idx ON shift_offers (applicant, date) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS shift_offer_bids
+(
+    offer      uuid        NOT NULL REFERENCES shift_offers (id) ON DELETE CASCADE,
+    bidder     uuid        NOT NULL REFERENCES users (id),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (offer, bidder)
+);
@@ -0,0 +1,12 @@
+-- Change requests are first accepted or declined by the counterpart (with_name) operator,
+-- only requests the counterpart accepted reach the manager queue.
+-- Declined requests are rejected without a manager.
+
+ALTER TABLE shift_change
+    ADD COLUMN IF NOT EXISTS counterpart_timestamp timestamptz;
+
+-- Requests already waiting for a manager keep their place in queue
+UPDATE shift_change
+SET status                = 'counterpart_accepted',
+    counterpart_timestamp = request_timestamp
+WHERE status = 'pending';
@@ -0,0 +1,70 @@
+-- Shift change statuses follow a defined lifecycle, enforced by db.CanTransition and by the trigger below:
+--
+--   pending              -> counterpart_accepted, rejected, cancelled, expired
+--   counterpart_accepted -> accepted, rejected, cancelled, expired
+--   accepted             -> applied, cancelled, expired
+--
+-- rejected, cancelled, expired and applied are final. Requests are accepted by the manager first and
+-- applied once the roster is swapped.
+
+ALTER TABLE shift_change
+    ADD COLUMN IF NOT EXISTS applied_timestamp timestamptz;
+
+-- Requests being swapped by an unfinished saga are accepted, the saga commit will apply them
+UPDATE swap_sagas
+SET change_status = 'applied'
+WHERE status IN ('started', 'roster_applied');
+
+UPDATE shift_change
+SET status = 'accepted'
+WHERE id IN (SELECT change FROM swap_sagas WHERE status IN ('started', 'roster_applied'));
+
+-- Other accepted requests were accepted when the roster swap was committed
+UPDATE shift_change
+SET status            = 'applied',
+    applied_timestamp = response_timestamp
+WHERE status = 'accepted'
+  AND id NOT IN (SELECT change FROM swap_sagas WHERE status IN ('started', 'roster_applied'));
+
+-- Unknown statuses were never swapped, only exactly 'accepted' requests were
+UPDATE shift_change
+SET status  = 'rejected',
+    outcome = true
+WHERE status NOT IN ('pending', 'counterpart_accepted', 'accepted', 'rejected', 'cancelled', 'expired', 'applied');
+
+ALTER TABLE shift_change
+    DROP CONSTRAINT IF EXISTS shift_change_status_check;
+ALTER TABLE shift_change
+    ADD CONSTRAINT shift_change_status_check
+        CHECK (status IN ('pending', 'counterpart_accepted', 'accepted', 'rejected', 'cancelled', 'expired', 'applied'));
+
+CREATE OR REPLACE FUNCTION shift_change_transition() RETURNS trigger AS
+$$
+BEGIN
+    IF (OLD.status, NEW.status) IN (
+                                    ('pending', 'counterpart_accepted'),
+                                    ('pending', 'rejected'),
+                                    ('pending', 'cancelled'),
+                                    ('pending', 'expired'),
+                                    ('counterpart_accepted', 'accepted'),
+                                    ('counterpart_accepted', 'rejected'),
+                                    ('counterpart_accepted', 'cancelled'),
+                                    ('counterpart_accepted', 'expired'),
+                                    ('accepted', 'applied'),
+                                    ('accepted', 'cancelled'),
+                                    ('accepted', 'expired')
+        ) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'change request % cannot move from % to %', OLD.id, OLD.status, NEW.status
+        USING ERRCODE = 'check_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS shift_change_transition ON shift_change;
+CREATE TRIGGER shift_change_transition
+    BEFORE UPDATE OF status
+    ON shift_change
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+EXECUTE PROCEDURE shift_change_transition();
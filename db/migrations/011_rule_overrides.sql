@@ -0,0 +1,14 @@
+-- Rule overrides record managers accepting a change request that break swap eligibility rules,
+-- with the violations found at approval and the reason they were overridden.
+
+CREATE TABLE IF NOT EXISTS rule_overrides
+(
+    id            uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    change        uuid        NOT NULL REFERENCES shift_change (id),
+    manager_name  uuid        NOT NULL REFERENCES users (id),
+    justification text        NOT NULL CHECK (btrim(justification) <> ''),
+    violations    jsonb       NOT NULL,
+    created_at    timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS rule_overrides_change_idx ON rule_overrides (change);
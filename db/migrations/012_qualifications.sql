@@ -0,0 +1,38 @@
+-- Qualifications track which certificates operators hold and which ones roster roles and vehicles require.
+-- A certificate without expiry never lapses. An operator is qualified for an assignment on a date when they
+-- hold every certificate required by its role and its vehicle, issued on or before that date and not yet expired.
+
+CREATE TABLE IF NOT EXISTS certificate_types
+(
+    id          uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    name        text        NOT NULL UNIQUE CHECK (btrim(name) <> ''),
+    description text        NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS operator_certificates
+(
+    id               uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    operator         uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    certificate_type uuid        NOT NULL REFERENCES certificate_types (id) ON DELETE CASCADE,
+    issued_on        date        NOT NULL,
+    expires_on       date CHECK (expires_on >= issued_on),
+    created_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS operator_certificates_operator_idx ON operator_certificates (operator);
+CREATE INDEX IF NOT EXISTS operator_certificates_expires_on_idx ON operator_certificates (expires_on);
+
+CREATE TABLE IF NOT EXISTS role_requirements
+(
+    role             uuid NOT NULL REFERENCES operator_roles (id) ON DELETE CASCADE,
+    certificate_type uuid NOT NULL REFERENCES certificate_types (id) ON DELETE CASCADE,
+    PRIMARY KEY (role, certificate_type)
+);
+
+CREATE TABLE IF NOT EXISTS vehicle_requirements
+(
+    vehicle          uuid NOT NULL REFERENCES vehicles (id) ON DELETE CASCADE,
+    certificate_type uuid NOT NULL REFERENCES certificate_types (id) ON DELETE CASCADE,
+    PRIMARY KEY (vehicle, certificate_type)
+);
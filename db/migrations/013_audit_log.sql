@@ -0,0 +1,47 @@
+-- Audit log records every mutation made through the API: who made it, from where, on what, and the values
+-- before and after. Entries are append-only, updates and deletes are refused by the trigger below.
+--
+-- Every entry hash cover its own fields and the previous entry hash, so editing or removing an entry
+-- bypassing the trigger breaks the chain from that entry on, see db.AuditEntry.Verify.
+-- before and after are json, not jsonb, so they're stored byte for byte as hashed.
+
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id          bigserial PRIMARY KEY,
+    actor       text        NOT NULL, -- Username from JWT, kept as text so entries outlive deleted users
+    action      text        NOT NULL,
+    target_type text        NOT NULL,
+    target_id   text        NOT NULL,
+    before      json,
+    after       json,
+    ip          text        NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL,
+    prev_hash   text        NOT NULL,
+    hash        text        NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_type, target_id);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit log is append-only, % refused', TG_OP
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_log
+    FOR EACH ROW
+EXECUTE PROCEDURE audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE
+    ON audit_log
+    FOR EACH STATEMENT
+EXECUTE PROCEDURE audit_log_append_only();
@@ -0,0 +1,10 @@
+-- Notification opt-outs list the email notifications an operator asked not to receive, one row per event.
+-- Operators without rows receive every notification, see notify.Events.
+
+CREATE TABLE IF NOT EXISTS notification_opt_outs
+(
+    operator   uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event      text        NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (operator, event)
+);
@@ -0,0 +1,9 @@
+-- An operator holds a shift once per day, but the check is deferred to commit: swapping two seats of the
+-- same shift moves operators one row at a time, and the first update would clash with the second row.
+-- Deferrable constraints can't arbitrate ON CONFLICT, db.RosterAssignment.Save updates then inserts instead.
+
+ALTER TABLE roster_assignments
+    DROP CONSTRAINT IF EXISTS roster_assignments_day_operator_shift_key;
+ALTER TABLE roster_assignments
+    ADD CONSTRAINT roster_assignments_day_operator_shift_key
+        UNIQUE (day, operator, shift) DEFERRABLE INITIALLY DEFERRED;
@@ -0,0 +1,30 @@
+-- Operators created after aliases were seeded had no alias, so they couldn't be found on the spreadsheet.
+-- Every operator without aliases now gets its surname as alias, on creation or once a surname is set.
+-- Operators already having aliases are left alone: their labels are managed by admins.
+
+CREATE OR REPLACE FUNCTION operator_default_alias() RETURNS trigger AS
+$$
+BEGIN
+    IF btrim(NEW.surname) <> '' AND NOT EXISTS(SELECT 1 FROM operator_aliases WHERE operator = NEW."user") THEN
+        INSERT INTO operator_aliases (operator, label)
+        VALUES (NEW."user", upper(btrim(NEW.surname)))
+        ON CONFLICT DO NOTHING;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS operator_default_alias ON operators;
+CREATE TRIGGER operator_default_alias
+    AFTER INSERT OR UPDATE OF surname
+    ON operators
+    FOR EACH ROW
+EXECUTE PROCEDURE operator_default_alias();
+
+-- Operators created since aliases were seeded
+INSERT INTO operator_aliases (operator, label)
+SELECT o."user", upper(btrim(o.surname))
+FROM operators o
+WHERE btrim(o.surname) <> ''
+  AND NOT EXISTS(SELECT 1 FROM operator_aliases a WHERE a.operator = o."user")
+ON CONFLICT DO NOTHING;
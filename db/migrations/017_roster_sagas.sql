@@ -0,0 +1,23 @@
+-- Roster sagas track accepted requests other than shift changes while they're applied to the roster and then
+-- committed to DB, like swap sagas do for shift changes. Legs are the operator assignments written, with the
+-- label operators were written with, so the roster write can be checked or reverted after a crash.
+--
+-- A request is applied by a single saga at a time, a second one fail to start until the first is finished.
+
+CREATE TABLE IF NOT EXISTS roster_sagas
+(
+    id           uuid PRIMARY KEY     DEFAULT gen_random_uuid(),
+    kind         varchar     NOT NULL CHECK (kind IN ('chain')),
+    target       uuid        NOT NULL, -- Request applied, table depends on kind
+    source       varchar     NOT NULL,
+    status       varchar     NOT NULL DEFAULT 'started'
+        CHECK (status IN ('started', 'roster_applied', 'committed', 'compensated', 'failed')),
+    manager_name uuid        NOT NULL REFERENCES users (id),
+    legs         json        NOT NULL,
+    last_error   text        NOT NULL DEFAULT '',
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS roster_sagas_in_flight_idx ON roster_sagas (kind, target)
+    WHERE status IN ('started', 'roster_applied');
@@ -0,0 +1,6 @@
+-- Cover requests and shift offer awards are applied as roster sagas too, like swap chains
+
+ALTER TABLE roster_sagas
+    DROP CONSTRAINT IF EXISTS roster_sagas_kind_check;
+ALTER TABLE roster_sagas
+    ADD CONSTRAINT roster_sagas_kind_check CHECK (kind IN ('chain', 'cover', 'offer'));
@@ -0,0 +1,18 @@
+-- A change request is swapped by a single saga at a time, like roster sagas: a retry while the first saga is
+-- still running fail to start instead of swapping the roster back.
+--
+-- Duplicated unfinished sagas left by concurrent retries are closed first, keeping the latest, and left for
+-- the manager to verify.
+
+UPDATE swap_sagas
+SET status     = 'failed',
+    last_error = 'duplicated saga, verify roster manually',
+    updated_at = now()
+WHERE status IN ('started', 'roster_applied')
+  AND id NOT IN (SELECT DISTINCT ON (change) id
+                 FROM swap_sagas
+                 WHERE status IN ('started', 'roster_applied')
+                 ORDER BY change, created_at DESC);
+
+CREATE UNIQUE INDEX IF NOT EXISTS swap_sagas_in_flight_idx ON swap_sagas (change)
+    WHERE status IN ('started', 'roster_applied');
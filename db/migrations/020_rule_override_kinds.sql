@@ -0,0 +1,15 @@
+-- Rule overrides are recorded for every request a manager accept, not only shift changes: kind tell which
+-- request target is, like roster sagas. Existing overrides were all recorded on shift changes.
+
+ALTER TABLE rule_overrides
+    DROP CONSTRAINT IF EXISTS rule_overrides_change_fkey;
+
+ALTER TABLE rule_overrides
+    RENAME COLUMN change TO target;
+
+ALTER TABLE rule_overrides
+    ADD COLUMN IF NOT EXISTS kind varchar NOT NULL DEFAULT 'change'
+        CHECK (kind IN ('change', 'chain', 'cover', 'offer'));
+
+DROP INDEX IF EXISTS rule_overrides_change_idx;
+CREATE INDEX IF NOT EXISTS rule_overrides_target_idx ON rule_overrides (kind, target);
@@ -0,0 +1,6 @@
+-- Driving licences are checked on operator certificates by the driver swap rule, see rules.Config
+-- driver_certificate. The default rules expect a certificate type called Patente.
+
+INSERT INTO certificate_types (name, description)
+VALUES ('Patente', 'Ambulance driving licence, required on driver roles by the default swap rules')
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,20 @@
+-- Rule overrides are recorded by the saga applying the request, in its commit transaction, so an override is
+-- never left behind by a request that failed to apply. Sagas carry the override until then.
+--
+-- A request is applied once, so it has a single override: duplicates left by retries are removed first,
+-- keeping the latest.
+
+ALTER TABLE swap_sagas
+    ADD COLUMN IF NOT EXISTS override json;
+
+ALTER TABLE roster_sagas
+    ADD COLUMN IF NOT EXISTS override json;
+
+DELETE
+FROM rule_overrides
+WHERE id NOT IN (SELECT DISTINCT ON (kind, target) id
+                 FROM rule_overrides
+                 ORDER BY kind, target, created_at DESC);
+
+DROP INDEX IF EXISTS rule_overrides_target_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS rule_overrides_target_key ON rule_overrides (kind, target);
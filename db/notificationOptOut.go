@@ -0,0 +1,68 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NotificationOptOut is an email notification event an operator asked not to receive
+type NotificationOptOut struct {
+	service  Service
+	Operator string `json:"operator"` // User UUID
+	Event    string `json:"event"`
+}
+
+func (o *NotificationOptOut) New(s Service) {
+	o.service = s
+}
+
+// GetAll retrieve events (operator) opted out of
+func (o NotificationOptOut) GetAll(operator string, dest *[]string) error {
+	sqlStatement := `SELECT event FROM notification_opt_outs WHERE operator = $1 ORDER BY event`
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, operator)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving notification opt-outs: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event string
+		if err = rows.Scan(&event); err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+	return nil
+}
+
+// OptedOut tell if (operator) opted out of (event)
+func (o NotificationOptOut) OptedOut(operator string, event string) (bool, error) {
+	var optedOut bool
+	sqlStatement := `SELECT EXISTS(SELECT 1 FROM notification_opt_outs WHERE operator = $1 AND event = $2)`
+	err := o.service.Db.QueryRowContext(o.service.Context(), sqlStatement, operator, event).Scan(&optedOut)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("error retrieving notification opt-out: %v\n", err))
+	}
+	return optedOut, nil
+}
+
+// Set opt Operator out of Event, or back in if (enabled)
+func (o NotificationOptOut) Set(enabled bool) error {
+	if o.Operator == "" || o.Event == "" {
+		return errors.New("operator and event are required")
+	}
+
+	sqlStatement := `INSERT INTO notification_opt_outs (operator, event) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if enabled {
+		sqlStatement = `DELETE FROM notification_opt_outs WHERE operator = $1 AND event = $2`
+	}
+	_, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Operator, o.Event)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating notification opt-out: %v\n", err))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OperatorAlias is a label an operator is written with on the roster spreadsheet
+type OperatorAlias struct {
+	service  Service
+	Id       string `json:"id"`
+	Operator string `json:"operator"` // User UUID
+	Label    string `json:"label"`    // Stored uppercase, like spreadsheet values
+}
+
+func (a *OperatorAlias) New(s Service) {
+	a.service = s
+}
+
+// GetAll retrieve every alias, ordered by operator and creation so the first alias of an operator is the oldest
+func (a OperatorAlias) GetAll(dest *[]OperatorAlias) error {
+	sqlStatement := `SELECT id, operator, label FROM operator_aliases ORDER BY operator, created_at, label`
+	rows, err := a.service.Db.QueryContext(a.service.Context(), sqlStatement)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving operator aliases: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias OperatorAlias
+		err = rows.Scan(&alias.Id, &alias.Operator, &alias.Label)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, alias)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+
+	return nil
+}
+
+// Create insert a new alias, Operator and Label must be populated. Id is set on success
+func (a *OperatorAlias) Create() error {
+	a.Label = strings.ToUpper(strings.TrimSpace(a.Label))
+	if a.Operator == "" || a.Label == "" {
+		return errors.New("operator and label are required")
+	}
+
+	sqlStatement := `
+		INSERT INTO operator_aliases (operator, label)
+		VALUES ($1, $2)
+		RETURNING id
+`
+	err := a.service.Db.QueryRowContext(a.service.Context(), sqlStatement, a.Operator, a.Label).Scan(&a.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating operator alias: %v\n", err))
+	}
+	return nil
+}
+
+// Delete remove alias (id)
+func (a OperatorAlias) Delete(id string) error {
+	sqlStatement := `DELETE FROM operator_aliases WHERE id = $1`
+	res, err := a.service.Db.ExecContext(a.service.Context(), sqlStatement, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error deleting operator alias: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no operator alias with passed id")
+	}
+	return nil
+}
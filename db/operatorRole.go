@@ -19,7 +19,7 @@ func (r *OperatorRole) New(service Service) {
 
 func (r *OperatorRole) Get(name string) error {
 	sqlStatement := `SELECT id, name, "order" FROM operator_roles WHERE name = $1`
-	row := r.service.Db.QueryRow(sqlStatement, name)
+	row := r.service.Db.QueryRowContext(r.service.Context(), sqlStatement, name)
 	switch err := row.Scan(&r.Id, &r.Name, &r.Order); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -32,7 +32,7 @@ func (r *OperatorRole) Get(name string) error {
 
 func (r *OperatorRole) GetAll(dest *[]OperatorRole) error {
 	sqlStatement := `SELECT id,name,"order" FROM operator_roles`
-	rows, err := r.service.Db.Query(sqlStatement)
+	rows, err := r.service.Db.QueryContext(r.service.Context(), sqlStatement)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving roles: %v\n", err))
 	}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outbox entry statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+)
+
+// OutboxEntry is a spreadsheet append waiting to be delivered
+type OutboxEntry struct {
+	service       Service
+	Id            string          `json:"id"`
+	SheetId       string          `json:"sheet_id"` // Spreadsheet to append to
+	Range         string          `json:"range"`    // Range to append after, in !A1 format
+	Payload       [][]interface{} `json:"payload"`  // Rows to append
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   time.Time       `json:"delivered_at,omitempty"`
+}
+
+func (o *OutboxEntry) New(s Service) {
+	o.service = s
+}
+
+const sqlSelectOutbox = `id,
+						   sheet_id,
+						   range,
+						   payload,
+						   status,
+						   attempts,
+						   next_attempt_at,
+						   last_error,
+						   created_at,
+						   COALESCE(delivered_at, $1)`
+
+// scanOutbox scan a row selected with sqlSelectOutbox columns
+func scanOutbox(row interface{ Scan(...interface{}) error }, o *OutboxEntry) error {
+	var payload []byte
+	err := row.Scan(&o.Id, &o.SheetId, &o.Range, &payload, &o.Status, &o.Attempts, &o.NextAttemptAt, &o.LastError, &o.CreatedAt, &o.DeliveredAt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, &o.Payload)
+}
+
+// Enqueue store a new append to be delivered as soon as possible
+//
+// Populate required field before invoke:
+// SheetId, Range, Payload
+func (o *OutboxEntry) Enqueue() error {
+	sqlStatement := `
+					INSERT INTO sheet_outbox (sheet_id, range, payload)
+					VALUES ($1, $2, $3)
+					RETURNING id, status, next_attempt_at, created_at
+`
+	payload, err := json.Marshal(o.Payload)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error encoding outbox payload: %v\n", err))
+	}
+
+	err = o.service.Db.QueryRowContext(o.service.Context(), sqlStatement, o.SheetId, o.Range, payload).Scan(&o.Id, &o.Status, &o.NextAttemptAt, &o.CreatedAt)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error storing outbox entry: %v\n", err))
+	}
+	return nil
+}
+
+// ClaimDue lease up to (limit) pending entries whose next attempt is due, for (lease) duration
+//
+// Leased entries are not returned by other calls until lease expire, so concurrent workers don't deliver twice
+//
+// dest []OutboxEntry: You must pass an array pointer to OutboxEntry who will be populated with retrieved content
+func (o *OutboxEntry) ClaimDue(limit int, lease time.Duration, dest *[]OutboxEntry) error {
+	sqlStatement := `
+					UPDATE sheet_outbox
+					SET next_attempt_at = now() + $3 * interval '1 second'
+					WHERE id IN (SELECT id
+					             FROM sheet_outbox
+					             WHERE status = 'pending' AND next_attempt_at <= now()
+					             ORDER BY created_at
+					             LIMIT $2
+					             FOR UPDATE SKIP LOCKED)
+					RETURNING ` + sqlSelectOutbox
+
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, time.Time{}, limit, lease.Seconds())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error claiming outbox entries: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry OutboxEntry
+		if err = scanOutbox(rows, &entry); err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		entry.service = o.service
+		*dest = append(*dest, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// GetById retrieve outbox entry from db, filtered by passed ID, return error if not found
+func (o *OutboxEntry) GetById(id string) error {
+	sqlStatement := `SELECT ` + sqlSelectOutbox + ` FROM sheet_outbox WHERE id = $2`
+	switch err := scanOutbox(o.service.Db.QueryRowContext(o.service.Context(), sqlStatement, time.Time{}, id), o); err {
+	case sql.ErrNoRows:
+		return errors.New("no row where retrieved")
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("error retrieving outbox entry from database: %v\n", err))
+	}
+}
+
+// GetAllByStatus retrieve all entries in (status), newest first
+//
+// dest []OutboxEntry: You must pass an array pointer to OutboxEntry who will be populated with retrieved content
+func (o *OutboxEntry) GetAllByStatus(status string, dest *[]OutboxEntry) error {
+	sqlStatement := `SELECT ` + sqlSelectOutbox + ` FROM sheet_outbox WHERE status = $2 ORDER BY created_at DESC`
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, time.Time{}, status)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving outbox entries: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry OutboxEntry
+		if err = scanOutbox(rows, &entry); err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// MarkDelivered set entry as delivered
+func (o *OutboxEntry) MarkDelivered() error {
+	sqlStatement := `
+					UPDATE sheet_outbox
+					SET status = 'delivered',
+					    attempts = attempts + 1,
+					    last_error = '',
+					    delivered_at = now()
+					WHERE id = $1
+`
+	_, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating outbox entry: %v\n", err))
+	}
+	o.Status = OutboxDelivered
+	return nil
+}
+
+// MarkFailed record a failed delivery attempt, scheduling the next one at (next) or dead lettering the entry if (dead)
+func (o *OutboxEntry) MarkFailed(cause error, next time.Time, dead bool) error {
+	sqlStatement := `
+					UPDATE sheet_outbox
+					SET status = $2,
+					    attempts = attempts + 1,
+					    last_error = $3,
+					    next_attempt_at = $4
+					WHERE id = $1
+`
+	status := OutboxPending
+	if dead {
+		status = OutboxDead
+	}
+
+	_, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id, status, cause.Error(), next)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating outbox entry: %v\n", err))
+	}
+	o.Status = status
+	o.Attempts++
+	o.LastError = cause.Error()
+	o.NextAttemptAt = next
+	return nil
+}
+
+// Replay put a dead entry back in the delivery queue, resetting attempts
+func (o *OutboxEntry) Replay() error {
+	sqlStatement := `
+					UPDATE sheet_outbox
+					SET status = 'pending',
+					    attempts = 0,
+					    next_attempt_at = now()
+					WHERE id = $1 AND status = 'dead'
+`
+	res, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error replaying outbox entry: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no dead outbox entry with passed id")
+	}
+	o.Status = OutboxPending
+	o.Attempts = 0
+	return nil
+}
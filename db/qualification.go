@@ -0,0 +1,172 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Requirement kinds, what a requirement is attached to
+const (
+	RequirementRole    = "role"
+	RequirementVehicle = "vehicle"
+)
+
+// requirementTables map requirement kinds to their table, target column and target catalog
+var requirementTables = map[string][3]string{
+	RequirementRole:    {"role_requirements", "role", "operator_roles"},
+	RequirementVehicle: {"vehicle_requirements", "vehicle", "vehicles"},
+}
+
+// Requirement is a certificate needed to be assigned to a role or a vehicle
+type Requirement struct {
+	service         Service
+	Kind            string `json:"kind"`             // RequirementRole or RequirementVehicle
+	Target          string `json:"target"`           // Operator role or vehicle UUID
+	TargetName      string `json:"target_name"`      // Operator role or vehicle name
+	CertificateType string `json:"certificate_type"` // Certificate type UUID
+	TypeName        string `json:"type_name"`
+}
+
+func (r *Requirement) New(s Service) {
+	r.service = s
+}
+
+// GetAll retrieve every role and vehicle requirement
+func (r Requirement) GetAll(dest *[]Requirement) error {
+	for _, kind := range []string{RequirementRole, RequirementVehicle} {
+		table := requirementTables[kind]
+		sqlStatement := fmt.Sprintf(`SELECT r.%[2]s, t.name, r.certificate_type, ct.name
+					FROM %[1]s r
+					INNER JOIN %[3]s t ON r.%[2]s = t.id
+					INNER JOIN certificate_types ct ON r.certificate_type = ct.id
+					ORDER BY t."order", ct.name`, table[0], table[1], table[2])
+
+		rows, err := r.service.Db.QueryContext(r.service.Context(), sqlStatement)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error retrieving %s requirements: %v\n", kind, err))
+		}
+		for rows.Next() {
+			requirement := Requirement{Kind: kind}
+			err = rows.Scan(&requirement.Target, &requirement.TargetName, &requirement.CertificateType, &requirement.TypeName)
+			if err != nil {
+				rows.Close()
+				return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+			}
+			*dest = append(*dest, requirement)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+		}
+	}
+	return nil
+}
+
+// Create add the requirement, Kind, Target and CertificateType must be populated
+func (r Requirement) Create() error {
+	table, ok := requirementTables[r.Kind]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown requirement kind %q, must be %s or %s", r.Kind, RequirementRole, RequirementVehicle))
+	}
+	if r.Target == "" || r.CertificateType == "" {
+		return errors.New("target and certificate type are required")
+	}
+
+	sqlStatement := fmt.Sprintf(`INSERT INTO %s (%s, certificate_type) VALUES ($1, $2) ON CONFLICT DO NOTHING`, table[0], table[1])
+	_, err := r.service.Db.ExecContext(r.service.Context(), sqlStatement, r.Target, r.CertificateType)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating %s requirement: %v\n", r.Kind, err))
+	}
+	return nil
+}
+
+// Delete remove the requirement, Kind, Target and CertificateType must be populated
+func (r Requirement) Delete() error {
+	table, ok := requirementTables[r.Kind]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown requirement kind %q, must be %s or %s", r.Kind, RequirementRole, RequirementVehicle))
+	}
+
+	sqlStatement := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND certificate_type = $2`, table[0], table[1])
+	res, err := r.service.Db.ExecContext(r.service.Context(), sqlStatement, r.Target, r.CertificateType)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error deleting %s requirement: %v\n", r.Kind, err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New(fmt.Sprintf("no such %s requirement", r.Kind))
+	}
+	return nil
+}
+
+// Qualification check operators against role and vehicle requirements
+type Qualification struct {
+	service Service
+}
+
+func (q *Qualification) New(s Service) {
+	q.service = s
+}
+
+// Missing return names of certificates required by (role) and (vehicle) that (operator) doesn't hold valid on (date)
+//
+// Role and vehicle are catalog names, as found on roster assignments. An empty result means operator is qualified
+func (q Qualification) Missing(operator string, role string, vehicle string, date time.Time) ([]string, error) {
+	sqlStatement := `SELECT ct.name
+					FROM certificate_types ct
+					WHERE ct.id IN (SELECT rr.certificate_type
+					                FROM role_requirements rr
+					                INNER JOIN operator_roles r ON rr.role = r.id
+					                WHERE r.name = $2
+					                UNION
+					                SELECT vr.certificate_type
+					                FROM vehicle_requirements vr
+					                INNER JOIN vehicles v ON vr.vehicle = v.id
+					                WHERE v.name = $3)
+					  AND NOT EXISTS(SELECT 1
+					                 FROM operator_certificates oc
+					                 WHERE CAST(oc.operator as varchar) = $1
+					                   AND oc.certificate_type = ct.id
+					                   AND oc.issued_on <= $4
+					                   AND (oc.expires_on IS NULL OR oc.expires_on >= $4))
+					ORDER BY ct.name`
+
+	rows, err := q.service.Db.QueryContext(q.service.Context(), sqlStatement, operator, role, vehicle, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error checking operator qualifications: %v\n", err))
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		missing = append(missing, name)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error appending rows to result %v\n", err))
+	}
+	return missing, nil
+}
+
+// Holds tell if (operator) hold a (certificate) type, by name, valid on (date)
+func (q Qualification) Holds(operator string, certificate string, date time.Time) (bool, error) {
+	sqlStatement := `SELECT EXISTS(SELECT 1
+					              FROM operator_certificates oc
+					              INNER JOIN certificate_types ct ON oc.certificate_type = ct.id
+					              WHERE CAST(oc.operator as varchar) = $1
+					                AND ct.name = $2
+					                AND oc.issued_on <= $3
+					                AND (oc.expires_on IS NULL OR oc.expires_on >= $3))`
+
+	var holds bool
+	err := q.service.Db.QueryRowContext(q.service.Context(), sqlStatement, operator, certificate, date.Format("2006-01-02")).Scan(&holds)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("error checking operator certificate: %v\n", err))
+	}
+	return holds, nil
+}
@@ -0,0 +1,400 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoAssignment is returned when operator has no assignment on the requested day
+var ErrNoAssignment = errors.New("no assignment found")
+
+// RosterAssignment represent an operator assigned to a location, shift, vehicle and role on a roster day
+//
+// Catalog references are exposed by name, the same values found in the spreadsheet roles cells
+type RosterAssignment struct {
+	service  Service
+	Id       string    `json:"id"`
+	Date     time.Time `json:"date"`
+	Operator string    `json:"operator"` // User UUID
+	Location string    `json:"location"`
+	Shift    string    `json:"shift"`
+	Vehicle  string    `json:"vehicle,omitempty"`
+	Role     string    `json:"role"`
+	Cell     string    `json:"cell,omitempty"` // Spreadsheet cell the assignment is mirrored to (week!A1)
+}
+
+func (a *RosterAssignment) New(s Service) {
+	a.service = s
+}
+
+const sqlSelectAssignment = `SELECT a.id,
+						   d.date,
+						   a.operator,
+						   l.name,
+						   s.name,
+						   COALESCE(v.name, ''),
+						   r.name,
+						   COALESCE(a.cell, '')
+					FROM roster_assignments a
+						INNER JOIN roster_days d ON a.day = d.id
+						INNER JOIN locations l ON a.location = l.id
+						INNER JOIN shifts s ON a.shift = s.id
+						LEFT JOIN vehicles v ON a.vehicle = v.id
+						INNER JOIN operator_roles r ON a.role = r.id
+`
+
+// Get retrieve operator's assignment for passed date, if operator work more than a shift the first one is returned
+func (a *RosterAssignment) Get(operator string, date time.Time) error {
+	sqlStatement := sqlSelectAssignment + `
+					WHERE a.operator = $1 AND d.date = $2
+					ORDER BY s."order"
+					LIMIT 1`
+
+	row := a.service.Db.QueryRowContext(a.service.Context(), sqlStatement, operator, date)
+	switch err := row.Scan(&a.Id, &a.Date, &a.Operator, &a.Location, &a.Shift, &a.Vehicle, &a.Role, &a.Cell); err {
+	case sql.ErrNoRows:
+		return ErrNoAssignment
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("error retrieving assignment from database: %v\n", err))
+	}
+}
+
+// GetAllByDate retrieve every assignment of passed day
+//
+// dest []RosterAssignment: You must pass an array pointer to RosterAssignment who will be populated with retrieved content
+func (a *RosterAssignment) GetAllByDate(date time.Time, dest *[]RosterAssignment) error {
+	sqlStatement := sqlSelectAssignment + `
+					WHERE d.date = $1
+					ORDER BY s."order", l."order", r."order"`
+
+	rows, err := a.service.Db.QueryContext(a.service.Context(), sqlStatement, date)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving assignments: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assignment RosterAssignment
+		err = rows.Scan(&assignment.Id, &assignment.Date, &assignment.Operator, &assignment.Location, &assignment.Shift, &assignment.Vehicle, &assignment.Role, &assignment.Cell)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, assignment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// Save insert the assignment, or update it if the operator already hold the same shift that day
+//
+// Populate required field before invoke:
+// Date, Operator, Location, Shift, Role (Vehicle and Cell are optional)
+//
+// Catalog fields are resolved by name, an unknown name return an error
+func (a *RosterAssignment) Save() error {
+	sqlDay := `
+					INSERT INTO roster_days (date)
+					VALUES ($1)
+					ON CONFLICT (date) DO UPDATE SET date = excluded.date
+					RETURNING id
+`
+	sqlVehicle := `SELECT id FROM vehicles WHERE name = $1`
+	sqlUpdate := `
+					UPDATE roster_assignments
+					SET location   = (SELECT id FROM locations WHERE name = $4),
+					    vehicle    = $5,
+					    role       = (SELECT id FROM operator_roles WHERE name = $6),
+					    cell       = NULLIF($7, ''),
+					    updated_at = now()
+					WHERE day = $1
+					  AND operator = $2
+					  AND shift = (SELECT id FROM shifts WHERE name = $3)
+					RETURNING id
+`
+	sqlInsert := `
+					INSERT INTO roster_assignments (day, operator, shift, location, vehicle, role, cell)
+					VALUES ($1,
+					        $2,
+					        (SELECT id FROM shifts WHERE name = $3),
+					        (SELECT id FROM locations WHERE name = $4),
+					        $5,
+					        (SELECT id FROM operator_roles WHERE name = $6),
+					        NULLIF($7, ''))
+					RETURNING id
+`
+	sqlFreeCell := `
+					DELETE FROM roster_assignments a
+					USING shifts s
+					WHERE a.shift = s.id
+					  AND a.day = $1
+					  AND a.cell = $2
+					  AND NOT (a.operator = $3 AND s.name = $4)
+`
+	tx, err := a.service.Db.BeginTx(a.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var day string
+	err = tx.QueryRowContext(a.service.Context(), sqlDay, a.Date).Scan(&day)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating roster day: %v\n", err))
+	}
+
+	// A cell hold a single operator, drop whoever was mirrored there before
+	if a.Cell != "" {
+		_, err = tx.ExecContext(a.service.Context(), sqlFreeCell, day, a.Cell, a.Operator, a.Shift)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error freeing assignment cell: %v\n", err))
+		}
+	}
+
+	// Vehicle is optional, but a name not in catalog must not be stored as no vehicle
+	var vehicle sql.NullString
+	if a.Vehicle != "" {
+		err = tx.QueryRowContext(a.service.Context(), sqlVehicle, a.Vehicle).Scan(&vehicle)
+		if err == sql.ErrNoRows {
+			return errors.New(fmt.Sprintf("unknown vehicle %q", a.Vehicle))
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("error retrieving vehicle: %v\n", err))
+		}
+	}
+
+	// Unique (day, operator, shift) is deferred so it can't arbitrate an upsert, update first and insert if missing
+	args := []interface{}{day, a.Operator, a.Shift, a.Location, vehicle, a.Role, a.Cell}
+	err = tx.QueryRowContext(a.service.Context(), sqlUpdate, args...).Scan(&a.Id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(a.service.Context(), sqlInsert, args...).Scan(&a.Id)
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error saving assignment: %v\n", err))
+	}
+
+	return tx.Commit()
+}
+
+// DeleteByCell remove the assignment mirrored to spreadsheet (cell) on (date), if any
+func (a *RosterAssignment) DeleteByCell(date time.Time, cell string) error {
+	sqlStatement := `
+					DELETE FROM roster_assignments a
+					USING roster_days d
+					WHERE a.day = d.id AND d.date = $1 AND a.cell = $2
+`
+	_, err := a.service.Db.ExecContext(a.service.Context(), sqlStatement, date, cell)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error deleting assignment: %v\n", err))
+	}
+	return nil
+}
+
+// RosterSwap switch two operators assignments on the DB roster, DB counterpart of gsuite.ShiftsToSwitch
+type RosterSwap struct {
+	service        Service
+	FirstOperator  string    // 1st operator user UUID
+	FirstDate      time.Time // 1st operator date to change
+	SecondOperator string    // 2nd operator user UUID
+	SecondDate     time.Time // 2nd operator date to change
+}
+
+func (s *RosterSwap) New(service Service) {
+	s.service = service
+}
+
+// Apply actually switch the assignments in a single transaction
+//
+// 1st operator take 2nd operator assignment and vice versa. Swapping two seats of the same shift relies on
+// the deferred unique (day, operator, shift) constraint, checked once both rows moved
+func (s RosterSwap) Apply() error {
+	sqlFind := `
+					SELECT a.id
+					FROM roster_assignments a
+						INNER JOIN roster_days d ON a.day = d.id
+						INNER JOIN shifts s ON a.shift = s.id
+					WHERE a.operator = $1 AND d.date = $2
+					ORDER BY s."order"
+					LIMIT 1
+					FOR UPDATE OF a
+`
+	sqlUpdate := `
+					UPDATE roster_assignments
+					SET operator = $2,
+					    updated_at = now()
+					WHERE id = $1
+`
+	if s.FirstOperator == "" || s.FirstDate.IsZero() || s.SecondOperator == "" || s.SecondDate.IsZero() {
+		return errors.New(fmt.Sprintf(
+			"Not all required fields supplied: 1sOperator: %v %v - 2ndOperator %v %v",
+			s.FirstOperator,
+			s.FirstDate,
+			s.SecondOperator,
+			s.SecondDate,
+		))
+	}
+
+	tx, err := s.service.Db.BeginTx(s.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var firstId, secondId string
+	err = tx.QueryRowContext(s.service.Context(), sqlFind, s.FirstOperator, s.FirstDate).Scan(&firstId)
+	if err != nil {
+		return errors.New(fmt.Sprintf("cannot retrieve 1st operator assignment: %v\n", err))
+	}
+	err = tx.QueryRowContext(s.service.Context(), sqlFind, s.SecondOperator, s.SecondDate).Scan(&secondId)
+	if err != nil {
+		return errors.New(fmt.Sprintf("cannot retrieve 2nd operator assignment: %v\n", err))
+	}
+
+	if _, err = tx.ExecContext(s.service.Context(), sqlUpdate, firstId, s.SecondOperator); err != nil {
+		return errors.New(fmt.Sprintf("error switching 1st operator assignment: %v\n", err))
+	}
+	if _, err = tx.ExecContext(s.service.Context(), sqlUpdate, secondId, s.FirstOperator); err != nil {
+		return errors.New(fmt.Sprintf("error switching 2nd operator assignment: %v\n", err))
+	}
+
+	return tx.Commit()
+}
+
+// RosterRotation rotate assignments among swap chain legs on the DB roster, DB counterpart of gsuite.ShiftChain
+type RosterRotation struct {
+	service Service
+	Legs    []SwapChainLeg // Every leg operator take next leg assignment, the last one take the first
+}
+
+func (r *RosterRotation) New(service Service) {
+	r.service = service
+}
+
+// Apply actually rotate the assignments in a single transaction
+func (r RosterRotation) Apply() error {
+	sqlFind := `
+					SELECT a.id
+					FROM roster_assignments a
+						INNER JOIN roster_days d ON a.day = d.id
+						INNER JOIN shifts s ON a.shift = s.id
+					WHERE a.operator = $1 AND d.date = $2
+					ORDER BY s."order"
+					LIMIT 1
+					FOR UPDATE OF a
+`
+	sqlUpdate := `
+					UPDATE roster_assignments
+					SET operator = $2,
+					    updated_at = now()
+					WHERE id = $1
+`
+	if len(r.Legs) < 2 {
+		return errors.New("a rotation need at least two legs")
+	}
+
+	tx, err := r.service.Db.BeginTx(r.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	// Lock every assignment before moving any, so legs are looked up on the roster as it was
+	ids := make([]string, len(r.Legs))
+	for i, leg := range r.Legs {
+		err = tx.QueryRowContext(r.service.Context(), sqlFind, leg.Operator, leg.Date).Scan(&ids[i])
+		if err != nil {
+			return errors.New(fmt.Sprintf("cannot retrieve leg %d assignment: %v\n", i, err))
+		}
+	}
+
+	// Leg i assignment goes to the previous leg operator
+	for i, id := range ids {
+		previous := r.Legs[(i+len(r.Legs)-1)%len(r.Legs)]
+		if _, err = tx.ExecContext(r.service.Context(), sqlUpdate, id, previous.Operator); err != nil {
+			return errors.New(fmt.Sprintf("error rotating leg %d assignment: %v\n", i, err))
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RosterCover hand an operator assignment to a covering operator on the DB roster, DB counterpart of gsuite.ShiftCover
+type RosterCover struct {
+	service   Service
+	Applicant string    // Applicant user UUID
+	Date      time.Time // Date of the assignment to cover
+	Covering  string    // Covering operator user UUID, must be free on Date
+}
+
+func (c *RosterCover) New(service Service) {
+	c.service = service
+}
+
+// Apply actually move the assignment in a single transaction
+func (c RosterCover) Apply() error {
+	sqlFind := `
+					SELECT a.id
+					FROM roster_assignments a
+						INNER JOIN roster_days d ON a.day = d.id
+						INNER JOIN shifts s ON a.shift = s.id
+					WHERE a.operator = $1 AND d.date = $2
+					ORDER BY s."order"
+					LIMIT 1
+					FOR UPDATE OF a
+`
+	sqlBusy := `
+					SELECT EXISTS (
+						SELECT 1
+						FROM roster_assignments a
+							INNER JOIN roster_days d ON a.day = d.id
+						WHERE a.operator = $1 AND d.date = $2
+					)
+`
+	sqlUpdate := `
+					UPDATE roster_assignments
+					SET operator = $2,
+					    updated_at = now()
+					WHERE id = $1
+`
+	if c.Applicant == "" || c.Date.IsZero() || c.Covering == "" {
+		return errors.New(fmt.Sprintf(
+			"Not all required fields supplied: applicant: %v %v - covering: %v",
+			c.Applicant,
+			c.Date,
+			c.Covering,
+		))
+	}
+
+	tx, err := c.service.Db.BeginTx(c.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(c.service.Context(), sqlFind, c.Applicant, c.Date).Scan(&id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("cannot retrieve applicant assignment: %v\n", err))
+	}
+
+	var busy bool
+	err = tx.QueryRowContext(c.service.Context(), sqlBusy, c.Covering, c.Date).Scan(&busy)
+	if err != nil {
+		return errors.New(fmt.Sprintf("cannot retrieve covering operator assignments: %v\n", err))
+	}
+	if busy {
+		return errors.New(fmt.Sprintf("covering operator is already on shift on %v", c.Date.Format("2006-01-02")))
+	}
+
+	if _, err = tx.ExecContext(c.service.Context(), sqlUpdate, id, c.Covering); err != nil {
+		return errors.New(fmt.Sprintf("error covering assignment: %v\n", err))
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Roster saga kinds, the request a roster saga apply
+const (
+	SagaChain = "chain" // Swap chain, accepted at commit
+	SagaCover = "cover" // Cover request, accepted at commit
+	SagaOffer = "offer" // Shift offer, awarded to 2nd leg operator at commit
+)
+
+// SagaLeg is an operator assignment written by a roster saga
+type SagaLeg struct {
+	Operator string    `json:"operator"` // User UUID
+	Label    string    `json:"label"`    // Name operator was written with on the spreadsheet
+	Date     time.Time `json:"date"`
+}
+
+// RosterSaga record an accepted request, other than a shift change, while it's applied to the roster and
+// committed to DB. Works like SwapSaga, with the same statuses
+type RosterSaga struct {
+	service   Service
+	Id        string    `json:"id"`
+	Kind      string    `json:"kind"`   // One of Saga* kinds
+	Target    string    `json:"target"` // UUID of the request applied
+	Source    string    `json:"source"` // Roster source the request is applied to
+	Status    string    `json:"status"` // One of Saga* statuses
+	Manager   string    `json:"manager"`
+	Legs      []SagaLeg `json:"legs"` // Meaning depends on kind, see roster package
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Override of broken eligibility rules, recorded at commit along with the request outcome
+	Override *RuleOverride `json:"override,omitempty"`
+}
+
+func (s *RosterSaga) New(service Service) {
+	s.service = service
+}
+
+// Start record saga intent before touching the roster
+//
+// Populate required field before invoke: Kind, Target, Source, Manager, Legs, Override if any.
+// Return ErrSagaInFlight if another saga is applying the same request
+func (s *RosterSaga) Start() error {
+	sqlStatement := `
+					INSERT INTO roster_sagas (kind, target, source, manager_name, legs, override)
+					VALUES ($1, $2, $3, $4, $5, $6)
+					RETURNING id, status, created_at, updated_at
+`
+	legs, err := json.Marshal(s.Legs)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error encoding roster saga legs: %v\n", err))
+	}
+	override, err := encodeOverride(s.Override)
+	if err != nil {
+		return err
+	}
+	row := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, s.Kind, s.Target, s.Source, s.Manager, string(legs), override)
+	err = row.Scan(&s.Id, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrSagaInFlight
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error recording roster saga: %v\n", err))
+	}
+	return nil
+}
+
+// SetStatus move saga to (status), recording (lastError) if any
+//
+// Only unfinished sagas move, so a saga recovered by another instance in the meantime is left alone
+func (s *RosterSaga) SetStatus(status string, lastError string) error {
+	sqlStatement := `
+					UPDATE roster_sagas
+					SET status = $2,
+					    last_error = $3,
+					    updated_at = now()
+					WHERE id = $1 AND status IN ($4, $5)
+`
+	res, err := s.service.Db.ExecContext(s.service.Context(), sqlStatement, s.Id, status, lastError, SagaStarted, SagaRosterApplied)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating roster saga: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New(fmt.Sprintf("roster saga %s already finished", s.Id))
+	}
+	s.Status = status
+	s.LastError = lastError
+	return nil
+}
+
+// Claim take over saga, left unfinished and untouched for more than (age), on behalf of recovery
+//
+// Return false if saga moved on or was claimed by someone else since it was retrieved, see SwapSaga.Claim
+func (s *RosterSaga) Claim(age time.Duration) (bool, error) {
+	sqlStatement := `
+					UPDATE roster_sagas
+					SET updated_at = now()
+					WHERE id = $1 AND status = $2 AND updated_at < $3
+					RETURNING updated_at
+`
+	err := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, s.Id, s.Status, time.Now().Add(-age)).Scan(&s.UpdatedAt)
+	switch err {
+	case sql.ErrNoRows:
+		return false, nil
+	case nil:
+		return true, nil
+	default:
+		return false, errors.New(fmt.Sprintf("error claiming roster saga: %v\n", err))
+	}
+}
+
+// Commit record saga request outcome and override if any, then mark saga as committed, in a single transaction
+func (s *RosterSaga) Commit() error {
+	sqlStatement := `
+					UPDATE roster_sagas
+					SET status = $2,
+					    last_error = '',
+					    updated_at = now()
+					WHERE id = $1 AND status IN ($3, $4)
+`
+	tx, err := s.service.Db.BeginTx(s.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	switch s.Kind {
+	case SagaChain:
+		chain := SwapChain{service: s.service, Id: s.Target, Manager: s.Manager, Status: RequestAccepted}
+		err = chain.changeStatus(tx)
+	case SagaCover:
+		cover := CoverRequest{service: s.service, Id: s.Target, Manager: s.Manager, Status: RequestAccepted}
+		err = cover.changeStatus(tx)
+	case SagaOffer:
+		offer := ShiftOffer{service: s.service, Id: s.Target}
+		err = s.requireLegs(2)
+		if err == nil {
+			err = offer.award(tx, s.Legs[1].Operator, s.Manager)
+		}
+	default:
+		err = errors.New(fmt.Sprintf("unknown roster saga kind %q", s.Kind))
+	}
+	if err != nil {
+		return err
+	}
+	// Roster saga kinds are named after rule override kinds
+	if err = commitOverride(s.service, tx, s.Override, s.Kind, s.Target); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(s.service.Context(), sqlStatement, s.Id, SagaCommitted, SagaStarted, SagaRosterApplied)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating roster saga: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New(fmt.Sprintf("roster saga %s already finished", s.Id))
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error committing roster saga: %v\n", err))
+	}
+
+	s.Status = SagaCommitted
+	s.LastError = ""
+	return nil
+}
+
+// Committed tell if saga request outcome is already recorded, by this saga or another instance running it
+func (s *RosterSaga) Committed() (bool, error) {
+	var (
+		sqlStatement string
+		args         []interface{}
+	)
+	switch s.Kind {
+	case SagaChain:
+		sqlStatement = `SELECT status = $2 FROM swap_chains WHERE id = $1`
+		args = []interface{}{s.Target, RequestAccepted}
+	case SagaCover:
+		sqlStatement = `SELECT status = $2 FROM cover_requests WHERE id = $1`
+		args = []interface{}{s.Target, RequestAccepted}
+	case SagaOffer:
+		if err := s.requireLegs(2); err != nil {
+			return false, err
+		}
+		sqlStatement = `SELECT status = $2 AND COALESCE(CAST(awarded_to as varchar), '') = $3 FROM shift_offers WHERE id = $1`
+		args = []interface{}{s.Target, OfferAwarded, s.Legs[1].Operator}
+	default:
+		return false, errors.New(fmt.Sprintf("unknown roster saga kind %q", s.Kind))
+	}
+
+	var committed bool
+	if err := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, args...).Scan(&committed); err != nil {
+		return false, errors.New(fmt.Sprintf("error retrieving roster saga request: %v\n", err))
+	}
+	return committed, nil
+}
+
+// requireLegs return error if saga has less than (n) legs
+func (s RosterSaga) requireLegs(n int) error {
+	if len(s.Legs) < n {
+		return errors.New(fmt.Sprintf("%s roster saga %s need %d legs, got %d", s.Kind, s.Id, n, len(s.Legs)))
+	}
+	return nil
+}
+
+// GetAllUnfinished retrieve sagas left in started or roster_applied status for more than (age)
+//
+// dest []RosterSaga: You must pass an array pointer to RosterSaga who will be populated with retrieved content
+func (s *RosterSaga) GetAllUnfinished(age time.Duration, dest *[]RosterSaga) error {
+	sqlStatement := `SELECT id,
+						   kind,
+						   target,
+						   source,
+						   status,
+						   manager_name,
+						   CAST(legs as text),
+						   last_error,
+						   created_at,
+						   updated_at,
+						   CAST(override as text)
+					FROM roster_sagas
+					WHERE status IN ($1, $2) AND updated_at < $3
+					ORDER BY created_at`
+
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, SagaStarted, SagaRosterApplied, time.Now().Add(-age))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving roster sagas: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			saga     RosterSaga
+			legs     string
+			override sql.NullString
+		)
+		err = rows.Scan(&saga.Id, &saga.Kind, &saga.Target, &saga.Source, &saga.Status, &saga.Manager, &legs,
+			&saga.LastError, &saga.CreatedAt, &saga.UpdatedAt, &override)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		if saga.Override, err = decodeOverride(s.service, override); err != nil {
+			return err
+		}
+		if err = json.Unmarshal([]byte(legs), &saga.Legs); err != nil {
+			return errors.New(fmt.Sprintf("error decoding roster saga %s legs: %v\n", saga.Id, err))
+		}
+		saga.service = s.service
+		*dest = append(*dest, saga)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SyncCell is the value a roster spreadsheet cell had when DB and spreadsheet were last in sync
+type SyncCell struct {
+	service  Service
+	Date     time.Time `json:"date"`
+	Cell     string    `json:"cell"`
+	Value    string    `json:"value"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+func (c *SyncCell) New(s Service) {
+	c.service = s
+}
+
+// GetAllByDate retrieve every synced cell of passed day
+//
+// dest []SyncCell: You must pass an array pointer to SyncCell who will be populated with retrieved content
+func (c *SyncCell) GetAllByDate(date time.Time, dest *[]SyncCell) error {
+	sqlStatement := `SELECT date, cell, value, synced_at FROM roster_sync_cells WHERE date = $1`
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, date)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving synced cells: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell SyncCell
+		err = rows.Scan(&cell.Date, &cell.Cell, &cell.Value, &cell.SyncedAt)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, cell)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// Save store cell value as last synced value
+//
+// Populate required field before invoke:
+// Date, Cell, Value
+func (c *SyncCell) Save() error {
+	sqlStatement := `
+					INSERT INTO roster_sync_cells (date, cell, value, synced_at)
+					VALUES ($1, $2, $3, now())
+					ON CONFLICT (date, cell) DO UPDATE
+					    SET value     = excluded.value,
+					        synced_at = excluded.synced_at
+`
+	_, err := c.service.Db.ExecContext(c.service.Context(), sqlStatement, c.Date, c.Cell, c.Value)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error saving synced cell: %v\n", err))
+	}
+	return nil
+}
+
+// SyncConflict is a roster cell changed both on DB and spreadsheet since last sync
+type SyncConflict struct {
+	service    Service
+	Id         string    `json:"id"`
+	Date       time.Time `json:"date"`
+	Cell       string    `json:"cell"`
+	BaseValue  string    `json:"base_value"`  // Value at last sync
+	SheetValue string    `json:"sheet_value"` // Value found on spreadsheet
+	DbValue    string    `json:"db_value"`    // Value found on DB roster
+	Status     string    `json:"status"`      // One of "open" or "resolved"
+	Resolution string    `json:"resolution,omitempty"`
+	Manager    string    `json:"manager,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+	ResolvedAt time.Time `json:"resolved_at,omitempty"`
+}
+
+func (c *SyncConflict) New(s Service) {
+	c.service = s
+}
+
+const sqlSelectSyncConflict = `SELECT id,
+						   date,
+						   cell,
+						   base_value,
+						   sheet_value,
+						   db_value,
+						   status,
+						   COALESCE(resolution, ''),
+						   COALESCE(CAST(manager_name as varchar), ''),
+						   detected_at,
+						   COALESCE(resolved_at, $1)
+					FROM roster_sync_conflicts
+`
+
+// GetById retrieve conflict from db, filtered by passed ID, return error if not found
+func (c *SyncConflict) GetById(id string) error {
+	sqlStatement := sqlSelectSyncConflict + `WHERE id = $2`
+	row := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, time.Time{}, id)
+	switch err := row.Scan(&c.Id, &c.Date, &c.Cell, &c.BaseValue, &c.SheetValue, &c.DbValue, &c.Status, &c.Resolution, &c.Manager, &c.DetectedAt, &c.ResolvedAt); err {
+	case sql.ErrNoRows:
+		return errors.New("no row where retrieved")
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("error retrieving sync conflict from database: %v\n", err))
+	}
+}
+
+// GetAllOpen retrieve all unresolved conflicts, ordered by roster date
+//
+// dest []SyncConflict: You must pass an array pointer to SyncConflict who will be populated with retrieved content
+func (c *SyncConflict) GetAllOpen(dest *[]SyncConflict) error {
+	sqlStatement := sqlSelectSyncConflict + `WHERE status = 'open' ORDER BY date, cell`
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, time.Time{})
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving sync conflicts: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conflict SyncConflict
+		err = rows.Scan(&conflict.Id, &conflict.Date, &conflict.Cell, &conflict.BaseValue, &conflict.SheetValue, &conflict.DbValue, &conflict.Status, &conflict.Resolution, &conflict.Manager, &conflict.DetectedAt, &conflict.ResolvedAt)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, conflict)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// Open record a new conflict, or refresh values of the one already open on the same cell
+//
+// Populate required field before invoke:
+// Date, Cell, BaseValue, SheetValue, DbValue
+func (c *SyncConflict) Open() error {
+	sqlStatement := `
+					INSERT INTO roster_sync_conflicts (date, cell, base_value, sheet_value, db_value)
+					VALUES ($1, $2, $3, $4, $5)
+					ON CONFLICT (date, cell) WHERE status = 'open' DO UPDATE
+					    SET base_value  = excluded.base_value,
+					        sheet_value = excluded.sheet_value,
+					        db_value    = excluded.db_value
+					RETURNING id, status, detected_at
+`
+	row := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, c.Date, c.Cell, c.BaseValue, c.SheetValue, c.DbValue)
+	err := row.Scan(&c.Id, &c.Status, &c.DetectedAt)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error recording sync conflict: %v\n", err))
+	}
+	return nil
+}
+
+// Resolve mark conflict as resolved
+//
+// set required fields in struct before invoking:
+// Id, Manager, Resolution (one of "sheet" or "db", the side that was kept)
+func (c *SyncConflict) Resolve() error {
+	timestamp := time.Now()
+	sqlStatement := `
+					UPDATE roster_sync_conflicts
+					SET status       = 'resolved',
+					    resolution   = $2,
+					    manager_name = $3,
+					    resolved_at  = $4
+					WHERE id = $1 AND status = 'open'
+`
+	res, err := c.service.Db.ExecContext(c.service.Context(), sqlStatement, c.Id, c.Resolution, c.Manager, timestamp)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error resolving sync conflict: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no open conflict with passed id")
+	}
+	c.Status = "resolved"
+	c.ResolvedAt = timestamp
+	return nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rule override kinds, the request a manager accepted breaking the rules
+const (
+	OverrideChange = "change" // Shift change request
+	OverrideChain  = "chain"  // Swap chain
+	OverrideCover  = "cover"  // Cover request
+	OverrideOffer  = "offer"  // Shift offer award
+)
+
+// RuleOverride record a manager accepting a request breaking swap eligibility rules
+type RuleOverride struct {
+	service       Service
+	Id            string          `json:"id"`
+	Kind          string          `json:"kind"`    // One of Override* kinds
+	Target        string          `json:"target"`  // UUID of the request accepted
+	Manager       string          `json:"manager"` // Manager user UUID
+	Justification string          `json:"justification"`
+	Violations    json.RawMessage `json:"violations"` // Violations found at approval, see rules.Violation
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func (o *RuleOverride) New(s Service) {
+	o.service = s
+}
+
+// Create record the override, Kind, Target, Manager, Justification and Violations must be populated. Id is set on success
+//
+// A request has a single override, accepting it is recorded once. Overrides of requests applied to the roster are
+// recorded by their saga commit instead, see SwapSaga.Override
+func (o *RuleOverride) Create() error {
+	return o.create(o.service.Db)
+}
+
+// create record the override running on (e), the DB or a transaction
+func (o *RuleOverride) create(e execer) error {
+	o.Justification = strings.TrimSpace(o.Justification)
+	if o.Kind == "" || o.Target == "" || o.Manager == "" || o.Justification == "" || len(o.Violations) == 0 {
+		return errors.New("kind, target, manager, justification and violations are required")
+	}
+
+	sqlStatement := `
+					INSERT INTO rule_overrides (kind, target, manager_name, justification, violations)
+					VALUES ($1, $2, $3, $4, $5)
+					RETURNING id, created_at
+`
+	err := e.QueryRowContext(o.service.Context(), sqlStatement, o.Kind, o.Target, o.Manager, o.Justification, string(o.Violations)).Scan(&o.Id, &o.CreatedAt)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error recording rule override: %v\n", err))
+	}
+	return nil
+}
+
+// GetAllByTarget retrieve overrides of (kind) request (target), oldest first
+func (o RuleOverride) GetAllByTarget(kind string, target string, dest *[]RuleOverride) error {
+	sqlStatement := `SELECT id, kind, target, manager_name, justification, violations, created_at
+					FROM rule_overrides
+					WHERE kind = $1 AND target = $2
+					ORDER BY created_at`
+
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, kind, target)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving rule overrides: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			override   RuleOverride
+			violations []byte
+		)
+		err = rows.Scan(&override.Id, &override.Kind, &override.Target, &override.Manager, &override.Justification, &violations, &override.CreatedAt)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		override.Violations = violations
+		*dest = append(*dest, override)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// encodeOverride return (o) as stored by sagas until commit, NULL if there's no override
+func encodeOverride(o *RuleOverride) (sql.NullString, error) {
+	if o == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(o)
+	if err != nil {
+		return sql.NullString{}, errors.New(fmt.Sprintf("error encoding rule override: %v\n", err))
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// decodeOverride return override stored by a saga as (v), nil if there's none
+func decodeOverride(service Service, v sql.NullString) (*RuleOverride, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+	o := &RuleOverride{service: service}
+	if err := json.Unmarshal([]byte(v.String), o); err != nil {
+		return nil, errors.New(fmt.Sprintf("error decoding rule override: %v\n", err))
+	}
+	return o, nil
+}
+
+// commitOverride record saga override (o), if any, on (kind) request (target) within transaction (tx)
+func commitOverride(service Service, tx execer, o *RuleOverride, kind string, target string) error {
+	if o == nil {
+		return nil
+	}
+	o.service = service
+	o.Kind = kind
+	o.Target = target
+	return o.create(tx)
+}
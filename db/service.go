@@ -1,9 +1,33 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 )
 
 type Service struct {
-	Db *sql.DB
+	Db  *sql.DB
+	ctx context.Context // Request context queries run under, nil for background work
+}
+
+// WithContext return a copy of the service running queries under (ctx), so they're cancelled with it
+//
+// Return a pointer so handlers can shadow their *Service with the request scoped one
+func (s Service) WithContext(ctx context.Context) *Service {
+	s.ctx = ctx
+	return &s
+}
+
+// Context return the context queries run under
+func (s Service) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx, used by statements that may run inside a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
@@ -19,7 +19,7 @@ func (s *Shift) New(service Service) {
 
 func (s *Shift) Get(name string) error {
 	sqlStatement := `SELECT id,name,"order" FROM shifts WHERE name = $1`
-	row := s.service.Db.QueryRow(sqlStatement, name)
+	row := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, name)
 	switch err := row.Scan(&s.Id, &s.Name, &s.Order); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -32,7 +32,7 @@ func (s *Shift) Get(name string) error {
 
 func (s *Shift) GetAll(dest *[]Shift) error {
 	sqlStatement := `SELECT id,name,"order" FROM shifts`
-	rows, err := s.service.Db.Query(sqlStatement)
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving shifts: %v\n", err))
 	}
@@ -8,17 +8,18 @@ import (
 )
 
 type ShiftChange struct {
-	service           Service
-	Id                string    `json:"id"`
-	Manager           string    `json:"manager,omitempty"`
-	Outcome           bool      `json:"outcome"`
-	Status            string    `json:"status"`
-	RequestTimestamp  time.Time `json:"request_timestamp"`
-	ResponseTimestamp time.Time `json:"response_timestamp,omitempty"`
-	ApplicantName     string    `json:"applicant_name"`
-	ApplicantDate     time.Time `json:"applicant_date"`
-	WithName          string    `json:"with_name"`
-	WithDate          time.Time `json:"with_date"`
+	service              Service
+	Id                   string    `json:"id"`
+	Manager              string    `json:"manager,omitempty"`
+	Outcome              bool      `json:"outcome"`
+	Status               string    `json:"status"`
+	RequestTimestamp     time.Time `json:"request_timestamp"`
+	CounterpartTimestamp time.Time `json:"counterpart_timestamp,omitempty"`
+	ResponseTimestamp    time.Time `json:"response_timestamp,omitempty"`
+	ApplicantName        string    `json:"applicant_name"`
+	ApplicantDate        time.Time `json:"applicant_date"`
+	WithName             string    `json:"with_name"`
+	WithDate             time.Time `json:"with_date"`
 }
 
 func (s *ShiftChange) New(service Service) {
@@ -35,6 +36,7 @@ func (s *ShiftChange) GetById(id string) error {
 						   outcome,
 						   status,
 						   request_timestamp,
+						   COALESCE(counterpart_timestamp, $2) as counterpart_timestamp,
 						   COALESCE(response_timestamp, $2) as response_timestamp,
 						   applicant_name,
 						   applicant_date,
@@ -43,8 +45,8 @@ func (s *ShiftChange) GetById(id string) error {
 					FROM shift_change
 					WHERE id = $1`
 
-	row := s.service.Db.QueryRow(sqlStatement, id, nullTime)
-	switch err := row.Scan(&s.Id, &s.Manager, &s.Outcome, &s.Status, &s.RequestTimestamp, &s.ResponseTimestamp, &s.ApplicantName, &s.ApplicantDate, &s.WithName, &s.WithDate); err {
+	row := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, id, nullTime)
+	switch err := row.Scan(&s.Id, &s.Manager, &s.Outcome, &s.Status, &s.RequestTimestamp, &s.CounterpartTimestamp, &s.ResponseTimestamp, &s.ApplicantName, &s.ApplicantDate, &s.WithName, &s.WithDate); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
 	case nil:
@@ -56,6 +58,9 @@ func (s *ShiftChange) GetById(id string) error {
 
 // GetAll retrieve all shift changes from db, ordered from newest to older
 //
+// Requests still waiting for the counterpart, declined by it, or cancelled or expired before its answer
+// are left out as they never reached the manager
+//
 // dest []ShiftChange: You must pass an array pointer to ShiftChange who will be populated with retrieved content
 func (s *ShiftChange) GetAll(dest *[]ShiftChange) error {
 	nullTime := time.Time{}
@@ -64,6 +69,7 @@ func (s *ShiftChange) GetAll(dest *[]ShiftChange) error {
 						   s.outcome,
 						   s.status,
 						   s.request_timestamp,
+						   COALESCE(s.counterpart_timestamp, $1) as counterpart_timestamp,
 						   COALESCE(s.response_timestamp, $1) as response_timestamp,
 						   CONCAT(a.surname, ' ', a.name)                as applicant_surname,
 						   s.applicant_date,
@@ -72,9 +78,12 @@ func (s *ShiftChange) GetAll(dest *[]ShiftChange) error {
 					FROM shift_change s
 						INNER JOIN operators a on s.applicant_name = a."user"
 						INNER JOIN operators w on s.with_name = w."user"
+					WHERE s.status <> $2
+					  AND NOT (s.status = $3 AND s.manager_name IS NULL)
+					  AND NOT (s.status IN ($4, $5) AND s.counterpart_timestamp IS NULL)
 					ORDER BY s.applicant_date DESC`
 
-	rows, err := s.service.Db.Query(sqlStatement, nullTime)
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, nullTime, ChangePending, ChangeRejected, ChangeCancelled, ChangeExpired)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving shifts change: %v\n", err))
 	}
@@ -82,7 +91,7 @@ func (s *ShiftChange) GetAll(dest *[]ShiftChange) error {
 
 	for rows.Next() {
 		var shiftChange ShiftChange
-		err = rows.Scan(&shiftChange.Id, &shiftChange.Manager, &shiftChange.Outcome, &shiftChange.Status, &shiftChange.RequestTimestamp, &shiftChange.ResponseTimestamp, &shiftChange.ApplicantName, &shiftChange.ApplicantDate, &shiftChange.WithName, &shiftChange.WithDate)
+		err = rows.Scan(&shiftChange.Id, &shiftChange.Manager, &shiftChange.Outcome, &shiftChange.Status, &shiftChange.RequestTimestamp, &shiftChange.CounterpartTimestamp, &shiftChange.ResponseTimestamp, &shiftChange.ApplicantName, &shiftChange.ApplicantDate, &shiftChange.WithName, &shiftChange.WithDate)
 		if err != nil {
 			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
 		}
@@ -108,6 +117,7 @@ func (s *ShiftChange) GetAllByApplicant(dest *[]ShiftChange) error {
 						   s.outcome,
 						   s.status,
 						   s.request_timestamp,
+						   COALESCE(s.counterpart_timestamp, $1) as counterpart_timestamp,
 						   COALESCE(s.response_timestamp, $1) as response_timestamp,
 						   a.surname as applicant_name,
 						   s.applicant_date,
@@ -120,7 +130,7 @@ func (s *ShiftChange) GetAllByApplicant(dest *[]ShiftChange) error {
 					ORDER BY request_timestamp DESC
 `
 
-	rows, err := s.service.Db.Query(sqlStatement, nulltime, s.ApplicantName)
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, nulltime, s.ApplicantName)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving shift changes: %v\n", err))
 	}
@@ -128,7 +138,7 @@ func (s *ShiftChange) GetAllByApplicant(dest *[]ShiftChange) error {
 
 	for rows.Next() {
 		var shiftChange ShiftChange
-		err = rows.Scan(&shiftChange.Id, &shiftChange.Manager, &shiftChange.Outcome, &shiftChange.Status, &shiftChange.RequestTimestamp, &shiftChange.ResponseTimestamp, &shiftChange.ApplicantName, &shiftChange.ApplicantDate, &shiftChange.WithName, &shiftChange.WithDate)
+		err = rows.Scan(&shiftChange.Id, &shiftChange.Manager, &shiftChange.Outcome, &shiftChange.Status, &shiftChange.RequestTimestamp, &shiftChange.CounterpartTimestamp, &shiftChange.ResponseTimestamp, &shiftChange.ApplicantName, &shiftChange.ApplicantDate, &shiftChange.WithName, &shiftChange.WithDate)
 		if err != nil {
 			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
 		}
@@ -141,6 +151,66 @@ func (s *ShiftChange) GetAllByApplicant(dest *[]ShiftChange) error {
 	return nil
 }
 
+// GetAllIncoming retrieve shift changes requests waiting for counterpart operator answer, newest first
+//
+// dest []ShiftChange: You must pass an array pointer to ShiftChange who will be populated with retrieved content
+//
+// set with_name UUID before call
+func (s *ShiftChange) GetAllIncoming(dest *[]ShiftChange) error {
+	nulltime := time.Time{}
+	sqlStatement := `SELECT s.id,
+						   COALESCE(CAST(s.manager_name as varchar), '') as manager_name,
+						   s.outcome,
+						   s.status,
+						   s.request_timestamp,
+						   COALESCE(s.counterpart_timestamp, $1) as counterpart_timestamp,
+						   COALESCE(s.response_timestamp, $1) as response_timestamp,
+						   a.surname as applicant_name,
+						   s.applicant_date,
+						   w.surname as with_name,
+						   s.with_date
+					FROM shift_change s
+						INNER JOIN operators a ON s.applicant_name = a."user"
+         				INNER JOIN operators w ON s.with_name = w."user"
+					WHERE with_name = $2 AND status = $3
+					ORDER BY request_timestamp DESC
+`
+
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, nulltime, s.WithName, ChangePending)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving shift changes: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shiftChange ShiftChange
+		err = rows.Scan(&shiftChange.Id, &shiftChange.Manager, &shiftChange.Outcome, &shiftChange.Status, &shiftChange.RequestTimestamp, &shiftChange.CounterpartTimestamp, &shiftChange.ResponseTimestamp, &shiftChange.ApplicantName, &shiftChange.ApplicantDate, &shiftChange.WithName, &shiftChange.WithDate)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, shiftChange)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// CounterpartResponse record counterpart operator answer to a pending request
+//
+// set required fields in struct before invoking: ID, WithName (counterpart UUID)
+//
+// Accepted requests move to the manager queue, declined ones are rejected.
+// Return a *TransitionError if request is not pending
+func (s *ShiftChange) CounterpartResponse(accepted bool) error {
+	status := ChangeRejected
+	if accepted {
+		status = ChangeCounterpartAccepted
+	}
+	return s.transition(s.service.Db, ChangePending, status, stageCounterpart)
+}
+
 // NewRequest create a new shift request, setting initial status
 //
 // Populate required field before invoke:
@@ -149,38 +219,148 @@ func (s *ShiftChange) GetAllByApplicant(dest *[]ShiftChange) error {
 // Applicant is the operator who ask for change
 //
 // With is the operator to change with
-func (s ShiftChange) NewRequest() error {
+func (s *ShiftChange) NewRequest() error {
 	sqlStatement := `
 					INSERT INTO shift_change (applicant_name, applicant_date, with_name, with_date)
 					VALUES ($1,$2,$3,$4)
+					RETURNING id, status
 `
-	_, err := s.service.Db.Exec(sqlStatement, s.ApplicantName, s.ApplicantDate, s.WithName, s.WithDate)
-	if err != nil {
+	row := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, s.ApplicantName, s.ApplicantDate, s.WithName, s.WithDate)
+	if err := row.Scan(&s.Id, &s.Status); err != nil {
 		return errors.New(fmt.Sprintf("error creating new shift change request: %v\n", err))
 	}
 	return nil
 }
 
-// ChangeStatus update change request status
+// ChangeStatus record manager decision on a request accepted by the counterpart
 //
 // set required fields in struct before invoking, non required fields will be discarded:
-// ID, Manager, Status
+// ID, Manager, Status (one of accepted or rejected)
 //
-// If successful set outcome to true (standing shift request has been evaded and require no further attention) and response timestamp
+// If successful set outcome to true (standing shift request has been evaded and require no further attention) and response timestamp.
+// Return a *TransitionError if request is not waiting for manager or status is not a legal one
 func (s *ShiftChange) ChangeStatus() error {
+	if s.Status != ChangeAccepted && s.Status != ChangeRejected {
+		return &TransitionError{Id: s.Id, From: ChangeCounterpartAccepted, To: s.Status}
+	}
+	return s.transition(s.service.Db, ChangeCounterpartAccepted, s.Status, stageManager)
+}
+
+// Cancel withdraw a request on behalf of its applicant, allowed until it's applied
+//
+// set required fields in struct before invoking: ID, ApplicantName (applicant UUID)
+//
+// An accepted request being swapped in the meantime has its saga commit fail, reverting the roster.
+// Return a *TransitionError if request is already closed
+func (s *ShiftChange) Cancel() error {
+	applicant := s.ApplicantName
+	if err := s.GetById(s.Id); err != nil {
+		return err
+	}
+	if s.ApplicantName != applicant {
+		return errors.New("change request was not made by user")
+	}
+	return s.transition(s.service.Db, s.Status, ChangeCancelled, stageClosed)
+}
+
+// Expire close requests still open with a date before (today), they can no longer be swapped
+//
+// Accepted requests being swapped by an unfinished saga are left alone, the saga will close them.
+//
+// dest []ShiftChange: populated with expired requests, applicant and counterpart UUIDs and dates only
+func (s ShiftChange) Expire(today time.Time, dest *[]ShiftChange) error {
+	sqlStatement := `
+					UPDATE shift_change
+					SET status=$1,
+					    outcome=true
+					WHERE status IN ($2, $3, $4)
+					  AND LEAST(applicant_date, with_date) < CAST($5 as date)
+					  AND id NOT IN (SELECT change FROM swap_sagas WHERE status IN ($6, $7))
+					RETURNING id, applicant_name, applicant_date, with_name, with_date
+`
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, ChangeExpired,
+		ChangePending, ChangeCounterpartAccepted, ChangeAccepted, today.Format("2006-01-02"), SagaStarted, SagaRosterApplied)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error expiring shift changes: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		expired := ShiftChange{Status: ChangeExpired, Outcome: true}
+		err = rows.Scan(&expired.Id, &expired.ApplicantName, &expired.ApplicantDate, &expired.WithName, &expired.WithDate)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		*dest = append(*dest, expired)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
+
+// Change request lifecycle stages, each one record its own timestamp
+const (
+	stageCounterpart = iota // Counterpart answer, set counterpart_timestamp
+	stageManager            // Manager decision, set manager_name and response_timestamp
+	stageApplied            // Roster swapped, set applied_timestamp
+	stageClosed             // Request withdrawn or expired, no timestamp
+)
+
+// transition move change request from status (from) to (to) through (e), either the DB or a running transaction
+//
+// Update only happens if request is still in (from) status, so concurrent managers can't both accept it.
+// If set, WithName must match the request counterpart.
+// Return a *TransitionError if transition is illegal or request moved on in the meantime
+func (s *ShiftChange) transition(e execer, from string, to string, stage int) error {
+	if !CanTransition(from, to) {
+		return &TransitionError{Id: s.Id, From: from, To: to}
+	}
+
 	timestamp := time.Now()
 	sqlStatement := `
 					UPDATE shift_change
-					SET manager_name=$2, 
-					    outcome=true,
-					    status=$3,
-					    response_timestamp=$4
-					WHERE id=$1
+					SET status=$3,
+					    outcome=$4,
+					    manager_name=CASE WHEN $5 THEN CAST($6 as uuid) ELSE manager_name END,
+					    counterpart_timestamp=CASE WHEN $7 THEN CAST($9 as timestamptz) ELSE counterpart_timestamp END,
+					    response_timestamp=CASE WHEN $5 THEN CAST($9 as timestamptz) ELSE response_timestamp END,
+					    applied_timestamp=CASE WHEN $8 THEN CAST($9 as timestamptz) ELSE applied_timestamp END
+					WHERE id=$1 AND status=$2 AND ($10 = '' OR CAST(with_name as varchar) = $10)
 `
-	_, err := s.service.Db.Exec(sqlStatement, s.Id, s.Manager, s.Status, timestamp)
+	// Requests still waiting for an answer require further attention
+	outcome := to != ChangePending && to != ChangeCounterpartAccepted
+	manager := sql.NullString{String: s.Manager, Valid: s.Manager != ""}
+	res, err := e.ExecContext(s.service.Context(), sqlStatement, s.Id, from, to, outcome,
+		stage == stageManager, manager, stage == stageCounterpart, stage == stageApplied, timestamp, s.WithName)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error updating status: %v\n", err))
 	}
-	s.Outcome = true
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		var current string
+		err = e.QueryRowContext(s.service.Context(), `SELECT status FROM shift_change WHERE id = $1`, s.Id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return errors.New("no change request with passed id")
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("error retrieving status: %v\n", err))
+		}
+		if current == from {
+			// Still in (from) status, so it's the counterpart not matching
+			return errors.New("change request is not addressed to user")
+		}
+		return &TransitionError{Id: s.Id, From: current, To: to}
+	}
+
+	s.Status = to
+	s.Outcome = outcome
+	switch stage {
+	case stageCounterpart:
+		s.CounterpartTimestamp = timestamp
+	case stageManager:
+		s.ResponseTimestamp = timestamp
+	}
 	return nil
 }
@@ -0,0 +1,316 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Shift offer statuses
+const (
+	OfferOpen      = "open"
+	OfferAwarded   = "awarded"
+	OfferWithdrawn = "withdrawn"
+)
+
+// ShiftOfferBid is a colleague volunteering for a shift offer
+type ShiftOfferBid struct {
+	Bidder    string    `json:"bidder"` // User UUID
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShiftOffer is a shift an operator need to drop, posted to the board for colleagues to bid on
+type ShiftOffer struct {
+	service   Service
+	Id        string          `json:"id"`
+	Applicant string          `json:"applicant"` // User UUID
+	Date      time.Time       `json:"date"`
+	Location  string          `json:"location"`
+	Shift     string          `json:"shift"`
+	Vehicle   string          `json:"vehicle"`
+	Role      string          `json:"role"`
+	Status    string          `json:"status"`
+	AwardedTo string          `json:"awarded_to,omitempty"` // User UUID
+	Change    string          `json:"change,omitempty"`     // Shift change recorded on award
+	CreatedAt time.Time       `json:"created_at"`
+	ClosedAt  time.Time       `json:"closed_at,omitempty"`
+	Bids      []ShiftOfferBid `json:"bids"`
+}
+
+// OfferFilter select open offers, empty fields match everything
+type OfferFilter struct {
+	Date     time.Time
+	Location string
+	Role     string
+}
+
+func (o *ShiftOffer) New(s Service) {
+	o.service = s
+}
+
+// Create post a new open offer, Applicant and Date must be populated. Id is set on success
+//
+// Only one open offer per applicant shift is allowed
+func (o *ShiftOffer) Create() error {
+	if o.Applicant == "" || o.Date.IsZero() {
+		return errors.New(fmt.Sprintf("Not all required fields supplied: applicant: %v %v", o.Applicant, o.Date))
+	}
+
+	sqlStatement := `
+					INSERT INTO shift_offers (applicant, date, location, shift, vehicle, role)
+					VALUES ($1, $2, $3, $4, $5, $6)
+					RETURNING id, status, created_at
+`
+	err := o.service.Db.QueryRowContext(o.service.Context(), sqlStatement, o.Applicant, o.Date, o.Location, o.Shift, o.Vehicle, o.Role).Scan(&o.Id, &o.Status, &o.CreatedAt)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating shift offer: %v\n", err))
+	}
+	return nil
+}
+
+const sqlSelectOffers = `SELECT id,
+						   applicant,
+						   date,
+						   location,
+						   shift,
+						   vehicle,
+						   role,
+						   status,
+						   COALESCE(CAST(awarded_to as varchar), '') as awarded_to,
+						   COALESCE(CAST(change as varchar), '') as change,
+						   created_at,
+						   COALESCE(closed_at, $1) as closed_at
+					FROM shift_offers`
+
+// scan populate offer from a sqlSelectOffers row
+func (o *ShiftOffer) scan(row interface{ Scan(...interface{}) error }) error {
+	return row.Scan(&o.Id, &o.Applicant, &o.Date, &o.Location, &o.Shift, &o.Vehicle, &o.Role, &o.Status, &o.AwardedTo, &o.Change, &o.CreatedAt, &o.ClosedAt)
+}
+
+// GetById retrieve offer (id) with its bids, return error if not found
+func (o *ShiftOffer) GetById(id string) error {
+	row := o.service.Db.QueryRowContext(o.service.Context(), sqlSelectOffers+` WHERE id = $2`, time.Time{}, id)
+	switch err := o.scan(row); err {
+	case sql.ErrNoRows:
+		return errors.New("no shift offer with passed id")
+	case nil:
+	default:
+		return errors.New(fmt.Sprintf("error retrieving shift offer from database: %v\n", err))
+	}
+
+	bids, err := o.getBids(o.Id)
+	if err != nil {
+		return err
+	}
+	o.Bids = bids[o.Id]
+	if o.Bids == nil {
+		o.Bids = []ShiftOfferBid{}
+	}
+	return nil
+}
+
+// GetOpen retrieve open offers matching (f) with their bids, soonest first
+func (o ShiftOffer) GetOpen(f OfferFilter, dest *[]ShiftOffer) error {
+	sqlStatement := sqlSelectOffers + `
+					WHERE status = $2
+					  AND ($3 = '' OR date::date = CAST($3 as date))
+					  AND ($4 = '' OR location = $4)
+					  AND ($5 = '' OR role = $5)
+					ORDER BY date, created_at`
+
+	var date string
+	if !f.Date.IsZero() {
+		date = f.Date.Format("2006-01-02")
+	}
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, time.Time{}, OfferOpen, date, f.Location, f.Role)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving shift offers: %v\n", err))
+	}
+	defer rows.Close()
+
+	var offers []ShiftOffer
+	for rows.Next() {
+		var offer ShiftOffer
+		if err = offer.scan(rows); err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		offers = append(offers, offer)
+	}
+	if err = rows.Err(); err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+
+	bids, err := o.getBids("")
+	if err != nil {
+		return err
+	}
+	for _, offer := range offers {
+		offer.service = o.service
+		offer.Bids = bids[offer.Id]
+		if offer.Bids == nil {
+			offer.Bids = []ShiftOfferBid{}
+		}
+		*dest = append(*dest, offer)
+	}
+	return nil
+}
+
+// getBids retrieve bids of offer (id) or of every open offer if (id) is empty, grouped by offer id, oldest first
+func (o ShiftOffer) getBids(id string) (map[string][]ShiftOfferBid, error) {
+	sqlStatement := `SELECT b.offer, b.bidder, b.created_at
+					FROM shift_offer_bids b
+						INNER JOIN shift_offers o ON b.offer = o.id
+					WHERE ($1 = '' AND o.status = $2) OR CAST(b.offer as varchar) = $1
+					ORDER BY b.offer, b.created_at`
+
+	rows, err := o.service.Db.QueryContext(o.service.Context(), sqlStatement, id, OfferOpen)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error retrieving shift offer bids: %v\n", err))
+	}
+	defer rows.Close()
+
+	bids := map[string][]ShiftOfferBid{}
+	for rows.Next() {
+		var (
+			offer string
+			bid   ShiftOfferBid
+		)
+		if err = rows.Scan(&offer, &bid.Bidder, &bid.CreatedAt); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		bids[offer] = append(bids[offer], bid)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return bids, nil
+}
+
+// Withdraw close open offer (o.Id), only its applicant can withdraw it
+func (o *ShiftOffer) Withdraw(applicant string) error {
+	sqlStatement := `
+					UPDATE shift_offers
+					SET status = $3,
+					    closed_at = now()
+					WHERE id = $1 AND applicant = $2 AND status = $4
+`
+	res, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id, applicant, OfferWithdrawn, OfferOpen)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error withdrawing shift offer: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no open shift offer with passed id posted by user")
+	}
+	o.Status = OfferWithdrawn
+	return nil
+}
+
+// Bid volunteer (bidder) for open offer (o.Id), applicant can't bid on its own offer
+func (o ShiftOffer) Bid(bidder string) error {
+	sqlStatement := `
+					INSERT INTO shift_offer_bids (offer, bidder)
+					SELECT id, $2
+					FROM shift_offers
+					WHERE id = $1 AND status = $3 AND applicant <> $2
+					ON CONFLICT DO NOTHING
+`
+	res, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id, bidder, OfferOpen)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error bidding on shift offer: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no open shift offer to bid on, or bid already placed")
+	}
+	return nil
+}
+
+// WithdrawBid remove (bidder) bid from open offer (o.Id)
+func (o ShiftOffer) WithdrawBid(bidder string) error {
+	sqlStatement := `
+					DELETE FROM shift_offer_bids b
+					USING shift_offers o
+					WHERE b.offer = o.id AND b.offer = $1 AND b.bidder = $2 AND o.status = $3
+`
+	res, err := o.service.Db.ExecContext(o.service.Context(), sqlStatement, o.Id, bidder, OfferOpen)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error withdrawing bid: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no bid by user on an open shift offer")
+	}
+	return nil
+}
+
+// Award close open offer (o.Id) giving it to (bidder), chosen by (manager), in a single transaction
+//
+// An applied shift change from applicant to bidder on offer date is recorded, so awarded offers
+// show up in change requests history. Change is set on success
+func (o *ShiftOffer) Award(bidder string, manager string) error {
+	tx, err := o.service.Db.BeginTx(o.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	if err = o.award(tx, bidder, manager); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error committing award: %v\n", err))
+	}
+	return nil
+}
+
+// award is Award through (e), a running transaction: offer row stay locked until it ends
+func (o *ShiftOffer) award(e execer, bidder string, manager string) error {
+	sqlLock := `SELECT applicant, date, status FROM shift_offers WHERE id = $1 FOR UPDATE`
+	sqlBid := `SELECT EXISTS (SELECT 1 FROM shift_offer_bids WHERE offer = $1 AND bidder = $2)`
+	sqlChange := `
+					INSERT INTO shift_change (applicant_name, applicant_date, with_name, with_date, manager_name, outcome, status,
+					                          response_timestamp, applied_timestamp)
+					VALUES ($1, $2, $3, $2, $4, true, $5, now(), now())
+					RETURNING id
+`
+	sqlAward := `
+					UPDATE shift_offers
+					SET status = $2,
+					    awarded_to = $3,
+					    change = $4,
+					    closed_at = now()
+					WHERE id = $1
+`
+
+	var status string
+	switch err := e.QueryRowContext(o.service.Context(), sqlLock, o.Id).Scan(&o.Applicant, &o.Date, &status); err {
+	case sql.ErrNoRows:
+		return errors.New("no shift offer with passed id")
+	case nil:
+	default:
+		return errors.New(fmt.Sprintf("error retrieving shift offer: %v\n", err))
+	}
+	if status != OfferOpen {
+		return errors.New(fmt.Sprintf("shift offer already %s", status))
+	}
+
+	var bid bool
+	if err := e.QueryRowContext(o.service.Context(), sqlBid, o.Id, bidder).Scan(&bid); err != nil {
+		return errors.New(fmt.Sprintf("error retrieving bid: %v\n", err))
+	}
+	if !bid {
+		return errors.New("shift offer can only be awarded to a bidder")
+	}
+
+	var change string
+	if err := e.QueryRowContext(o.service.Context(), sqlChange, o.Applicant, o.Date, bidder, manager, ChangeApplied).Scan(&change); err != nil {
+		return errors.New(fmt.Sprintf("error recording shift change: %v\n", err))
+	}
+	if _, err := e.ExecContext(o.service.Context(), sqlAward, o.Id, OfferAwarded, bidder, change); err != nil {
+		return errors.New(fmt.Sprintf("error awarding shift offer: %v\n", err))
+	}
+
+	o.Status = OfferAwarded
+	o.AwardedTo = bidder
+	o.Change = change
+	return nil
+}
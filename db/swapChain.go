@@ -0,0 +1,239 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Request statuses, shared by swap chains and cover requests
+const (
+	RequestPending  = "pending"
+	RequestAccepted = "accepted"
+	RequestRejected = "rejected"
+)
+
+// SwapChainLeg is an operator shift taking part in a swap chain
+type SwapChainLeg struct {
+	Operator string    `json:"operator"` // User UUID
+	Date     time.Time `json:"date"`     // Date of the shift operator give away
+}
+
+// SwapChain is a shift change request among more than two operators
+//
+// Every leg operator take the shift of the next leg, the last one take the shift of the first,
+// so A, B, C legs mean A take B shift, B take C shift and C take A shift
+type SwapChain struct {
+	service           Service
+	Id                string         `json:"id"`
+	Applicant         string         `json:"applicant"`
+	Manager           string         `json:"manager,omitempty"`
+	Status            string         `json:"status"`
+	RequestTimestamp  time.Time      `json:"request_timestamp"`
+	ResponseTimestamp time.Time      `json:"response_timestamp,omitempty"`
+	Legs              []SwapChainLeg `json:"legs"`
+}
+
+func (c *SwapChain) New(s Service) {
+	c.service = s
+}
+
+// ValidateLegs check (legs) form a valid chain with (applicant) in it
+//
+// A chain need at least two legs and the same operator shift can't appear twice
+func ValidateLegs(applicant string, legs []SwapChainLeg) error {
+	if len(legs) < 2 {
+		return errors.New("a swap chain need at least two legs")
+	}
+
+	seen := map[string]bool{}
+	found := false
+	for i, leg := range legs {
+		if leg.Operator == "" || leg.Date.IsZero() {
+			return errors.New(fmt.Sprintf("leg %d: operator and date are required", i))
+		}
+		key := leg.Operator + leg.Date.Format("20060102")
+		if seen[key] {
+			return errors.New(fmt.Sprintf("leg %d: operator %s shift on %s already in chain", i, leg.Operator, leg.Date.Format("2006-01-02")))
+		}
+		seen[key] = true
+		if leg.Operator == applicant {
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("applicant must take part in the swap chain")
+	}
+	return nil
+}
+
+// NewRequest store a new pending swap chain with its legs, Applicant and Legs must be populated. Id is set on success
+func (c *SwapChain) NewRequest() error {
+	if err := ValidateLegs(c.Applicant, c.Legs); err != nil {
+		return err
+	}
+
+	sqlChain := `
+					INSERT INTO swap_chains (applicant)
+					VALUES ($1)
+					RETURNING id, status, request_timestamp
+`
+	sqlLeg := `
+					INSERT INTO swap_chain_legs (chain, position, operator, date)
+					VALUES ($1, $2, $3, $4)
+`
+
+	tx, err := c.service.Db.BeginTx(c.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(c.service.Context(), sqlChain, c.Applicant).Scan(&c.Id, &c.Status, &c.RequestTimestamp)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating swap chain: %v\n", err))
+	}
+	for position, leg := range c.Legs {
+		if _, err = tx.ExecContext(c.service.Context(), sqlLeg, c.Id, position, leg.Operator, leg.Date); err != nil {
+			return errors.New(fmt.Sprintf("error creating swap chain leg %d: %v\n", position, err))
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetById retrieve swap chain (id) with its legs, return error if not found
+func (c *SwapChain) GetById(id string) error {
+	nullTime := time.Time{}
+	sqlStatement := `SELECT id,
+						   applicant,
+						   COALESCE(CAST(manager_name as varchar), '') as manager_name,
+						   status,
+						   request_timestamp,
+						   COALESCE(response_timestamp, $2) as response_timestamp
+					FROM swap_chains
+					WHERE id = $1`
+
+	row := c.service.Db.QueryRowContext(c.service.Context(), sqlStatement, id, nullTime)
+	switch err := row.Scan(&c.Id, &c.Applicant, &c.Manager, &c.Status, &c.RequestTimestamp, &c.ResponseTimestamp); err {
+	case sql.ErrNoRows:
+		return errors.New("no swap chain with passed id")
+	case nil:
+	default:
+		return errors.New(fmt.Sprintf("error retrieving swap chain from database: %v\n", err))
+	}
+
+	legs, err := c.getLegs(c.Id)
+	if err != nil {
+		return err
+	}
+	c.Legs = legs[c.Id]
+	return nil
+}
+
+// GetAll retrieve every swap chain with its legs, newest first
+func (c SwapChain) GetAll(dest *[]SwapChain) error {
+	nullTime := time.Time{}
+	sqlStatement := `SELECT id,
+						   applicant,
+						   COALESCE(CAST(manager_name as varchar), '') as manager_name,
+						   status,
+						   request_timestamp,
+						   COALESCE(response_timestamp, $1) as response_timestamp
+					FROM swap_chains
+					ORDER BY request_timestamp DESC`
+
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, nullTime)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving swap chains: %v\n", err))
+	}
+	defer rows.Close()
+
+	var chains []SwapChain
+	for rows.Next() {
+		var chain SwapChain
+		err = rows.Scan(&chain.Id, &chain.Applicant, &chain.Manager, &chain.Status, &chain.RequestTimestamp, &chain.ResponseTimestamp)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		chains = append(chains, chain)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+
+	legs, err := c.getLegs("")
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		chain.service = c.service
+		chain.Legs = legs[chain.Id]
+		*dest = append(*dest, chain)
+	}
+	return nil
+}
+
+// getLegs retrieve legs of chain (id) or of every chain if (id) is empty, grouped by chain id in position order
+func (c SwapChain) getLegs(id string) (map[string][]SwapChainLeg, error) {
+	sqlStatement := `SELECT chain, operator, date
+					FROM swap_chain_legs
+					WHERE $1 = '' OR CAST(chain as varchar) = $1
+					ORDER BY chain, position`
+
+	rows, err := c.service.Db.QueryContext(c.service.Context(), sqlStatement, id)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error retrieving swap chain legs: %v\n", err))
+	}
+	defer rows.Close()
+
+	legs := map[string][]SwapChainLeg{}
+	for rows.Next() {
+		var (
+			chain string
+			leg   SwapChainLeg
+		)
+		if err = rows.Scan(&chain, &leg.Operator, &leg.Date); err != nil {
+			return nil, errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		legs[chain] = append(legs[chain], leg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return legs, nil
+}
+
+// ChangeStatus set pending chain as accepted or rejected by Manager, Id, Manager and Status must be populated
+//
+// Chains are managed as a whole and only once, an already managed chain return error
+func (c *SwapChain) ChangeStatus() error {
+	if c.Status != RequestAccepted && c.Status != RequestRejected {
+		return errors.New(fmt.Sprintf("invalid swap chain status: %q", c.Status))
+	}
+
+	return c.changeStatus(c.service.Db)
+}
+
+// changeStatus is ChangeStatus through (e), either the DB or a running transaction
+func (c *SwapChain) changeStatus(e execer) error {
+	timestamp := time.Now()
+	sqlStatement := `
+					UPDATE swap_chains
+					SET manager_name=$2,
+					    status=$3,
+					    response_timestamp=$4
+					WHERE id=$1 AND status=$5
+`
+	res, err := e.ExecContext(c.service.Context(), sqlStatement, c.Id, c.Manager, c.Status, timestamp, RequestPending)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating swap chain status: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no pending swap chain with passed id")
+	}
+	c.ResponseTimestamp = timestamp
+	return nil
+}
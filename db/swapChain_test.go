@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateLegs(t *testing.T) {
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	tuesday := time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		applicant string
+		legs      []SwapChainLeg
+		wantErr   bool
+	}{
+		{"Three legs", "a", []SwapChainLeg{{"a", monday}, {"b", tuesday}, {"c", monday}}, false},
+		{"Same operator on different days", "a", []SwapChainLeg{{"a", monday}, {"b", tuesday}, {"a", tuesday}}, false},
+		{"Single leg", "a", []SwapChainLeg{{"a", monday}}, true},
+		{"Applicant not in chain", "d", []SwapChainLeg{{"a", monday}, {"b", tuesday}}, true},
+		{"Repeated leg", "a", []SwapChainLeg{{"a", monday}, {"b", tuesday}, {"a", monday}}, true},
+		{"Missing date", "a", []SwapChainLeg{{"a", monday}, {"b", time.Time{}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateLegs(tt.applicant, tt.legs); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLegs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Swap saga statuses
+const (
+	SagaStarted       = "started"        // Intent recorded, roster not touched yet
+	SagaRosterApplied = "roster_applied" // Roster swapped, change request not updated yet
+	SagaCommitted     = "committed"      // Roster swapped and change request updated
+	SagaCompensated   = "compensated"    // Roster swap reverted after a failed DB update
+	SagaFailed        = "failed"         // Nothing to do or manual intervention required, see LastError
+)
+
+// ErrSagaInFlight is returned starting a saga for a request already being applied by another one
+var ErrSagaInFlight = errors.New("request is already being applied to the roster")
+
+// isUniqueViolation tell if (err) is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
+
+// SwapSaga record an accepted change request while it's applied to the roster and committed to DB
+type SwapSaga struct {
+	service        Service
+	Id             string    `json:"id"`
+	Change         string    `json:"change"`        // Shift change request UUID
+	Source         string    `json:"source"`        // Roster source the swap is applied to
+	Status         string    `json:"status"`        // One of Saga* statuses
+	Manager        string    `json:"manager"`       // Manager user UUID
+	ChangeStatus   string    `json:"change_status"` // Status to set on change request at commit
+	FirstOperator  string    `json:"first_operator"`
+	FirstLabel     string    `json:"first_label"`
+	FirstDate      time.Time `json:"first_date"`
+	SecondOperator string    `json:"second_operator"`
+	SecondLabel    string    `json:"second_label"`
+	SecondDate     time.Time `json:"second_date"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// Override of broken eligibility rules, recorded at commit along with the change request status
+	Override *RuleOverride `json:"override,omitempty"`
+}
+
+func (s *SwapSaga) New(service Service) {
+	s.service = service
+}
+
+// Start record swap intent before touching the roster
+//
+// Populate required field before invoke:
+// Change, Source, Manager, ChangeStatus, First*, Second*, Override if any.
+// Return ErrSagaInFlight if another saga is swapping the same change request
+func (s *SwapSaga) Start() error {
+	sqlStatement := `
+					INSERT INTO swap_sagas (change, source, manager_name, change_status,
+					                        first_operator, first_label, first_date,
+					                        second_operator, second_label, second_date, override)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+					RETURNING id, status, created_at, updated_at
+`
+	override, err := encodeOverride(s.Override)
+	if err != nil {
+		return err
+	}
+	row := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, s.Change, s.Source, s.Manager, s.ChangeStatus,
+		s.FirstOperator, s.FirstLabel, s.FirstDate, s.SecondOperator, s.SecondLabel, s.SecondDate, override)
+	err = row.Scan(&s.Id, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrSagaInFlight
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error recording swap saga: %v\n", err))
+	}
+	return nil
+}
+
+// SetStatus move saga to (status), recording (lastError) if any
+//
+// Only unfinished sagas move, so a saga recovered by another instance in the meantime is left alone
+func (s *SwapSaga) SetStatus(status string, lastError string) error {
+	sqlStatement := `
+					UPDATE swap_sagas
+					SET status = $2,
+					    last_error = $3,
+					    updated_at = now()
+					WHERE id = $1 AND status IN ($4, $5)
+`
+	res, err := s.service.Db.ExecContext(s.service.Context(), sqlStatement, s.Id, status, lastError, SagaStarted, SagaRosterApplied)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating swap saga: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New(fmt.Sprintf("swap saga %s already finished", s.Id))
+	}
+	s.Status = status
+	s.LastError = lastError
+	return nil
+}
+
+// Claim take over saga, left unfinished and untouched for more than (age), on behalf of recovery
+//
+// Claiming bump updated_at, so other instances won't pick it up for another (age).
+// Return false if saga moved on or was claimed by someone else since it was retrieved
+func (s *SwapSaga) Claim(age time.Duration) (bool, error) {
+	sqlStatement := `
+					UPDATE swap_sagas
+					SET updated_at = now()
+					WHERE id = $1 AND status = $2 AND updated_at < $3
+					RETURNING updated_at
+`
+	err := s.service.Db.QueryRowContext(s.service.Context(), sqlStatement, s.Id, s.Status, time.Now().Add(-age)).Scan(&s.UpdatedAt)
+	switch err {
+	case sql.ErrNoRows:
+		return false, nil
+	case nil:
+		return true, nil
+	default:
+		return false, errors.New(fmt.Sprintf("error claiming swap saga: %v\n", err))
+	}
+}
+
+// Commit update change request status, record the override if any and mark saga as committed in a single transaction
+func (s *SwapSaga) Commit() error {
+	sqlStatement := `
+					UPDATE swap_sagas
+					SET status = $2,
+					    last_error = '',
+					    updated_at = now()
+					WHERE id = $1 AND status IN ($3, $4)
+`
+	tx, err := s.service.Db.BeginTx(s.service.Context(), nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	change := ShiftChange{service: s.service, Id: s.Change, Manager: s.Manager}
+	if err = change.transition(tx, ChangeAccepted, s.ChangeStatus, stageApplied); err != nil {
+		return err
+	}
+	if err = commitOverride(s.service, tx, s.Override, OverrideChange, s.Change); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(s.service.Context(), sqlStatement, s.Id, SagaCommitted, SagaStarted, SagaRosterApplied)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error updating swap saga: %v\n", err))
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New(fmt.Sprintf("swap saga %s already finished", s.Id))
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error committing swap saga: %v\n", err))
+	}
+
+	s.Status = SagaCommitted
+	s.LastError = ""
+	return nil
+}
+
+// Committed tell if saga change request is already applied, by this saga or another instance running it
+func (s *SwapSaga) Committed() (bool, error) {
+	var status string
+	err := s.service.Db.QueryRowContext(s.service.Context(), `SELECT status FROM shift_change WHERE id = $1`, s.Change).Scan(&status)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("error retrieving change request status: %v\n", err))
+	}
+	return status == ChangeApplied, nil
+}
+
+// GetAllUnfinished retrieve sagas left in started or roster_applied status for more than (age)
+//
+// dest []SwapSaga: You must pass an array pointer to SwapSaga who will be populated with retrieved content
+func (s *SwapSaga) GetAllUnfinished(age time.Duration, dest *[]SwapSaga) error {
+	sqlStatement := `SELECT id,
+						   change,
+						   source,
+						   status,
+						   manager_name,
+						   change_status,
+						   first_operator,
+						   first_label,
+						   first_date,
+						   second_operator,
+						   second_label,
+						   second_date,
+						   last_error,
+						   created_at,
+						   updated_at,
+						   CAST(override as text)
+					FROM swap_sagas
+					WHERE status IN ($1, $2) AND updated_at < $3
+					ORDER BY created_at`
+
+	rows, err := s.service.Db.QueryContext(s.service.Context(), sqlStatement, SagaStarted, SagaRosterApplied, time.Now().Add(-age))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving swap sagas: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			saga     SwapSaga
+			override sql.NullString
+		)
+		err = rows.Scan(&saga.Id, &saga.Change, &saga.Source, &saga.Status, &saga.Manager, &saga.ChangeStatus,
+			&saga.FirstOperator, &saga.FirstLabel, &saga.FirstDate,
+			&saga.SecondOperator, &saga.SecondLabel, &saga.SecondDate,
+			&saga.LastError, &saga.CreatedAt, &saga.UpdatedAt, &override)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error scanning row: %v\n", err))
+		}
+		if saga.Override, err = decodeOverride(s.service, override); err != nil {
+			return err
+		}
+		saga.service = s.service
+		*dest = append(*dest, saga)
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error appending rows to result: %v\n", err))
+	}
+	return nil
+}
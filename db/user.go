@@ -32,7 +32,7 @@ func (u User) GetAllUser(dest *[]User) error {
 					from operators
 					order by surname asc
 					`
-	rows, err = u.service.Db.Query(sqlStatement)
+	rows, err = u.service.Db.QueryContext(u.service.Context(), sqlStatement)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving users: %v\n", err))
 	}
@@ -48,7 +48,7 @@ func (u User) GetAllUser(dest *[]User) error {
 	}
 	err = rows.Err()
 	if err != nil {
-		fmt.Sprintf("error appending row to result: %v\n", err)
+		return errors.New(fmt.Sprintf("error appending row to result: %v\n", err))
 	}
 
 	return nil
@@ -56,7 +56,7 @@ func (u User) GetAllUser(dest *[]User) error {
 
 func (u *User) GetUser(username string) error {
 	sqlStatement := `SELECT id, username, password,roles FROM users WHERE username=$1`
-	row := u.service.Db.QueryRow(sqlStatement, username)
+	row := u.service.Db.QueryRowContext(u.service.Context(), sqlStatement, username)
 	switch err := row.Scan(&u.Id, &u.Username, &u.Password, pq.Array(&u.Roles)); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -67,6 +67,29 @@ func (u *User) GetUser(username string) error {
 	}
 }
 
+// GetById retrieve user (id) with operator details, password excluded. Users without operator details
+// have them empty
+func (u *User) GetById(id string) error {
+	sqlStatement := `SELECT u.id,
+						   u.username,
+						   COALESCE(o.surname, ''),
+						   COALESCE(o.name, ''),
+						   COALESCE(o.mail, '')
+					FROM users u
+					LEFT JOIN operators o on u.id = o."user"
+					WHERE u.id = $1`
+
+	row := u.service.Db.QueryRowContext(u.service.Context(), sqlStatement, id)
+	switch err := row.Scan(&u.Id, &u.Username, &u.Surname, &u.Name, &u.Mail); err {
+	case sql.ErrNoRows:
+		return errors.New("no row where retrieved")
+	case nil:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("error retrieving user from database: %v\n", err))
+	}
+}
+
 func (u *User) GetUserDetail(username string) error {
 	sqlStatement := `SELECT
 						   u.username,
@@ -77,7 +100,7 @@ func (u *User) GetUserDetail(username string) error {
 					INNER JOIN operators o on u.id = o."user"
 					WHERE u.username = $1`
 
-	row := u.service.Db.QueryRow(sqlStatement, username)
+	row := u.service.Db.QueryRowContext(u.service.Context(), sqlStatement, username)
 	switch err := row.Scan(&u.Username, &u.Surname, &u.Name, &u.Mail); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -102,7 +125,7 @@ func (u *User) CreateUser(username, password string) error {
 		return errors.New(fmt.Sprintf("Error hashing password: %v\n", err))
 	}
 
-	err = u.service.Db.QueryRow(sqlStatement, username, hashedPwd).Scan(&u.Id)
+	err = u.service.Db.QueryRowContext(u.service.Context(), sqlStatement, username, hashedPwd).Scan(&u.Id)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error creating new user: %v\n", err))
 	}
@@ -120,7 +143,7 @@ func (u *User) ResetPassword(username, password string) error {
 		return errors.New(fmt.Sprintf("Error hashing password: %v\n", err))
 	}
 
-	_, err = u.service.Db.Exec(sqlStatement, username, hashedPwd)
+	_, err = u.service.Db.ExecContext(u.service.Context(), sqlStatement, username, hashedPwd)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error occurred while resetting password: %v\n", err))
 	}
@@ -132,7 +155,7 @@ func (u *User) DeleteUser(username string) error {
 		DELETE FROM users
 		WHERE username = $1
 `
-	_, err := u.service.Db.Exec(sqlStatement, username)
+	_, err := u.service.Db.ExecContext(u.service.Context(), sqlStatement, username)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error deleting user: %v\n", err))
 	}
@@ -19,7 +19,7 @@ func (v *Vehicle) New(s Service) {
 
 func (v *Vehicle) Get(name string) error {
 	sqlStatement := `SELECT id, name, "order" FROM vehicles WHERE name = $1`
-	row := v.service.Db.QueryRow(sqlStatement, name)
+	row := v.service.Db.QueryRowContext(v.service.Context(), sqlStatement, name)
 	switch err := row.Scan(&v.Id, &v.Name, &v.Order); err {
 	case sql.ErrNoRows:
 		return errors.New("no row where retrieved")
@@ -32,7 +32,7 @@ func (v *Vehicle) Get(name string) error {
 
 func (v *Vehicle) GetAll(dest *[]Vehicle) error {
 	sqlStatement := `SELECT id,name,"order" FROM vehicles`
-	rows, err := v.service.Db.Query(sqlStatement)
+	rows, err := v.service.Db.QueryContext(v.service.Context(), sqlStatement)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error retrieving vehicles: %v\n", err))
 	}
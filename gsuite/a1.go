@@ -0,0 +1,208 @@
+package gsuite
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cell is a spreadsheet cell coordinate in A1 notation, column and row are 1 based
+//
+// A zero column or row mean the coordinate is unbounded on that axis (A:C, B4:C)
+type Cell struct {
+	Col int
+	Row int
+}
+
+// Range is a spreadsheet range in the !A1 format (Sheet!A1:B2), Sheet is optional
+type Range struct {
+	Sheet string
+	Start Cell
+	End   Cell
+}
+
+// ColumnName convert a 1 based column index to its letters (1 -> A, 27 -> AA)
+func ColumnName(col int) string {
+	var name []byte
+	for col > 0 {
+		col--
+		name = append([]byte{byte('A' + col%26)}, name...)
+		col /= 26
+	}
+	return string(name)
+}
+
+// ColumnIndex convert column letters to a 1 based column index (A -> 1, AA -> 27)
+func ColumnIndex(name string) (int, error) {
+	if name == "" {
+		return 0, errors.New("empty column name")
+	}
+
+	col := 0
+	for _, r := range strings.ToUpper(name) {
+		if r < 'A' || r > 'Z' {
+			return 0, errors.New(fmt.Sprintf("malformed column name %s", name))
+		}
+		col = col*26 + int(r-'A') + 1
+	}
+	return col, nil
+}
+
+// ParseCell parse a complete cell reference (A1, AB12)
+func ParseCell(s string) (Cell, error) {
+	c, err := parseRef(s)
+	if err != nil {
+		return c, err
+	}
+	if c.Col == 0 || c.Row == 0 {
+		return c, errors.New(fmt.Sprintf("incomplete cell reference %s", s))
+	}
+	return c, nil
+}
+
+// parseRef parse a cell reference where either column or row may be missing (A1, A, 1)
+func parseRef(s string) (Cell, error) {
+	var c Cell
+	ref := strings.ToUpper(strings.TrimSpace(strings.Replace(s, "$", "", -1)))
+
+	index := 0
+	for index < len(ref) && ref[index] >= 'A' && ref[index] <= 'Z' {
+		index++
+	}
+	if index > 0 {
+		c.Col, _ = ColumnIndex(ref[:index])
+	}
+	if index < len(ref) {
+		row, err := strconv.Atoi(ref[index:])
+		if err != nil || row < 1 {
+			return c, errors.New(fmt.Sprintf("malformed cell reference %s", s))
+		}
+		c.Row = row
+	}
+	if c.Col == 0 && c.Row == 0 {
+		return c, errors.New(fmt.Sprintf("malformed cell reference %s", s))
+	}
+	return c, nil
+}
+
+// String format cell in A1 notation
+func (c Cell) String() string {
+	var row string
+	if c.Row > 0 {
+		row = strconv.Itoa(c.Row)
+	}
+	return ColumnName(c.Col) + row
+}
+
+// Offset return the cell (rows) below and (cols) right of c
+func (c Cell) Offset(rows int, cols int) Cell {
+	return Cell{Col: c.Col + cols, Row: c.Row + rows}
+}
+
+// ParseRange parse a range in the !A1 format (Sheet!A1:B2, 'My sheet'!B4:C, A1)
+//
+// A single cell is parsed as a range starting and ending on it
+func ParseRange(s string) (Range, error) {
+	var r Range
+
+	ref := s
+	if index := strings.LastIndex(s, "!"); index >= 0 {
+		r.Sheet = strings.Replace(strings.Trim(s[:index], "'"), "''", "'", -1)
+		ref = s[index+1:]
+	}
+
+	cells := strings.SplitN(ref, ":", 2)
+	start, err := parseRef(cells[0])
+	if err != nil {
+		return r, err
+	}
+	r.Start = start
+
+	if len(cells) == 1 {
+		if start.Col == 0 || start.Row == 0 {
+			return r, errors.New(fmt.Sprintf("incomplete cell reference %s", s))
+		}
+		r.End = start
+		return r, nil
+	}
+
+	end, err := parseRef(cells[1])
+	if err != nil {
+		return r, err
+	}
+	r.End = end
+
+	// Open ranges (A:C) start at first row, (1:3) at first column
+	if r.Start.Row == 0 {
+		r.Start.Row = 1
+	}
+	if r.Start.Col == 0 {
+		r.Start.Col = 1
+	}
+	return r, nil
+}
+
+// String format range in the !A1 format, quoting sheet name if needed
+func (r Range) String() string {
+	var prefix string
+	if r.Sheet != "" {
+		prefix = quoteSheet(r.Sheet) + "!"
+	}
+	if r.End == r.Start {
+		return prefix + r.Start.String()
+	}
+	return prefix + r.Start.String() + ":" + r.End.String()
+}
+
+// Offset return the range moved (rows) down and (cols) right, unbounded ends stay unbounded
+func (r Range) Offset(rows int, cols int) Range {
+	res := r
+	res.Start = r.Start.Offset(rows, cols)
+	if r.End.Row > 0 {
+		res.End.Row += rows
+	}
+	if r.End.Col > 0 {
+		res.End.Col += cols
+	}
+	return res
+}
+
+// Contains tell if cell (c) is inside range
+func (r Range) Contains(c Cell) bool {
+	return c.Col >= r.Start.Col && c.Row >= r.Start.Row &&
+		(r.End.Col == 0 || c.Col <= r.End.Col) &&
+		(r.End.Row == 0 || c.Row <= r.End.Row)
+}
+
+// Rows return the number of rows in range, 0 if unbounded
+func (r Range) Rows() int {
+	if r.End.Row == 0 {
+		return 0
+	}
+	return r.End.Row - r.Start.Row + 1
+}
+
+// Cols return the number of columns in range, 0 if unbounded
+func (r Range) Cols() int {
+	if r.End.Col == 0 {
+		return 0
+	}
+	return r.End.Col - r.Start.Col + 1
+}
+
+// CellAt return the absolute cell at zero based (rowIndex, colIndex) from range start, on range sheet
+func (r Range) CellAt(rowIndex int, colIndex int) Range {
+	c := r.Start.Offset(rowIndex, colIndex)
+	return Range{Sheet: r.Sheet, Start: c, End: c}
+}
+
+// quoteSheet quote sheet names containing anything but letters, digits and underscores
+func quoteSheet(name string) string {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return "'" + strings.Replace(name, "'", "''", -1) + "'"
+		}
+	}
+	return name
+}
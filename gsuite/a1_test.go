@@ -0,0 +1,119 @@
+package gsuite
+
+import "testing"
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		col  int
+		want string
+	}{
+		{1, "A"},
+		{26, "Z"},
+		{27, "AA"},
+		{52, "AZ"},
+		{53, "BA"},
+		{702, "ZZ"},
+		{703, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := ColumnName(tt.col); got != tt.want {
+			t.Errorf("ColumnName(%v) = %v, want %v", tt.col, got, tt.want)
+		}
+		got, err := ColumnIndex(tt.want)
+		if err != nil || got != tt.col {
+			t.Errorf("ColumnIndex(%v) = %v, error = %v, want %v", tt.want, got, err, tt.col)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       string
+		want    Range
+		wantErr bool
+	}{
+		{
+			name: "Sheet and cells",
+			r:    "Config!A1:B7",
+			want: Range{Sheet: "Config", Start: Cell{Col: 1, Row: 1}, End: Cell{Col: 2, Row: 7}},
+		},
+		{
+			name: "Multi letter columns",
+			r:    "2!Y1:AB3",
+			want: Range{Sheet: "2", Start: Cell{Col: 25, Row: 1}, End: Cell{Col: 28, Row: 3}},
+		},
+		{
+			name: "Quoted sheet and open end",
+			r:    "'My sheet'!B4:C",
+			want: Range{Sheet: "My sheet", Start: Cell{Col: 2, Row: 4}, End: Cell{Col: 3}},
+		},
+		{
+			name: "Single cell without sheet",
+			r:    "AA12",
+			want: Range{Start: Cell{Col: 27, Row: 12}, End: Cell{Col: 27, Row: 12}},
+		},
+		{
+			name:    "Incomplete single cell",
+			r:       "Config!A",
+			wantErr: true,
+		},
+		{
+			name:    "Malformed row",
+			r:       "Config!A0:B2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRange(tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseRange() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRange_String(t *testing.T) {
+	tests := []struct {
+		r    Range
+		want string
+	}{
+		{Range{Sheet: "2", Start: Cell{Col: 25, Row: 1}, End: Cell{Col: 27, Row: 3}}, "2!Y1:AA3"},
+		{Range{Sheet: "My sheet", Start: Cell{Col: 2, Row: 4}, End: Cell{Col: 3}}, "'My sheet'!B4:C"},
+		{Range{Start: Cell{Col: 28, Row: 2}, End: Cell{Col: 28, Row: 2}}, "AB2"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("Range.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestRange_CellAt(t *testing.T) {
+	r, err := ParseRange("2!Y1:AA3")
+	if err != nil {
+		t.Fatalf("ParseRange() error = %v", err)
+	}
+	tests := []struct {
+		rowIndex int
+		colIndex int
+		want     string
+	}{
+		{0, 0, "2!Y1"},
+		{0, 2, "2!AA1"},
+		{2, 3, "2!AB3"},
+	}
+	for _, tt := range tests {
+		if got := r.CellAt(tt.rowIndex, tt.colIndex).String(); got != tt.want {
+			t.Errorf("CellAt(%v, %v) = %v, want %v", tt.rowIndex, tt.colIndex, got, tt.want)
+		}
+	}
+	if !r.Contains(Cell{Col: 26, Row: 2}) || r.Contains(Cell{Col: 28, Row: 1}) {
+		t.Errorf("Contains() wrong result for Y1:AA3")
+	}
+}
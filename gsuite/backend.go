@@ -0,0 +1,158 @@
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Backend represent the spreadsheet storage Service read from and write to
+//
+// Every method take the context the call is cancelled with, the spreadsheet ID to operate on and
+// ranges in the !A1 format (Sheet!A1:B2), values are returned as strings like Google Sheets does for formatted values
+type Backend interface {
+	// Get retrieve all values in range (r)
+	Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error)
+	// Append add (data) after the table found at range (r) and return an HTTP like status code
+	Append(ctx context.Context, sheetId string, r string, data [][]interface{}) (int, error)
+	// BatchUpdate write all passed cells in a single call
+	BatchUpdate(ctx context.Context, sheetId string, d []CellToUpdate) error
+}
+
+// DefaultBackend, if set, is used by Service.New instead of connecting to Google Sheets
+//
+// Used to run the server in local dev mode or tests against a MemoryBackend
+var DefaultBackend Backend
+
+// googleBackend is the Backend implementation talking to Google Sheets API
+type googleBackend struct {
+	srv *sheets.Service
+}
+
+// clientTimeout bound every Google Sheets call, quota waits and retries included, even when its context has
+// no deadline
+const clientTimeout = 2 * time.Minute
+
+var (
+	sharedGoogle   Backend
+	sharedGoogleMu sync.Mutex
+)
+
+// sharedGoogleBackend return the process wide Google Sheets backend, creating it on first use
+//
+// SHEETS_ENDPOINT, if set, point the shared client to another Sheets API server (like a sheetstest fake).
+// A failed creation is not remembered, next call will try again
+func sharedGoogleBackend() (Backend, error) {
+	sharedGoogleMu.Lock()
+	defer sharedGoogleMu.Unlock()
+
+	if sharedGoogle == nil {
+		var opts []option.ClientOption
+		if endpoint := os.Getenv("SHEETS_ENDPOINT"); endpoint != "" {
+			opts = append(opts, option.WithEndpoint(endpoint))
+		} else if os.Getenv("GOOGLE_API") == "" {
+			return nil, errors.New("can't read GOOGLE_API secret from env")
+		}
+
+		backend, err := newGoogleBackend(opts...)
+		if err != nil {
+			return nil, err
+		}
+		sharedGoogle = newCoalescingBackend(backend)
+	}
+	return sharedGoogle, nil
+}
+
+// newGoogleBackend create a Google Sheets backend, (opts) are applied to the Sheets client
+// GOOGLE_API is the auth secret, if missing requests are not authenticated as fake servers don't need it
+func newGoogleBackend(opts ...option.ClientOption) (Backend, error) {
+	client := &http.Client{Timeout: clientTimeout}
+	if secret := os.Getenv("GOOGLE_API"); secret != "" {
+		conf, err := google.JWTConfigFromJSON([]byte(secret), sheets.SpreadsheetsScope)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error parsing GOOGLE_API secret: %v\n", err))
+		}
+		// Client outlive any request, calls are cancelled through their own context or clientTimeout
+		client = conf.Client(context.Background())
+		client.Timeout = clientTimeout
+	}
+
+	// Keep calls within Sheets quota, retrying rate limited and failed requests
+	client.Transport = NewQuotaTransport(client.Transport)
+
+	opts = append([]option.ClientOption{option.WithHTTPClient(client)}, opts...)
+	srv, err := sheets.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error creating Google Sheets client: %v\n", err))
+	}
+
+	return googleBackend{srv: srv}, nil
+}
+
+func (b googleBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	res, err := b.srv.Spreadsheets.Values.Get(sheetId, r).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return res.Values, nil
+}
+
+func (b googleBackend) BatchGet(ctx context.Context, sheetId string, ranges []string) ([][][]interface{}, error) {
+	res, err := b.srv.Spreadsheets.Values.BatchGet(sheetId).Ranges(ranges...).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][][]interface{}, len(ranges))
+	for index, valueRange := range res.ValueRanges {
+		if index < len(values) {
+			values[index] = valueRange.Values
+		}
+	}
+	return values, nil
+}
+
+func (b googleBackend) Append(ctx context.Context, sheetId string, r string, data [][]interface{}) (int, error) {
+	var values = sheets.ValueRange{
+		Values: data,
+	}
+
+	res, err := b.srv.Spreadsheets.Values.Append(sheetId, r, &values).ValueInputOption("USER_ENTERED").Context(ctx).Do()
+	if err != nil {
+		return 0, err
+	}
+	return res.HTTPStatusCode, nil
+}
+
+func (b googleBackend) BatchUpdate(ctx context.Context, sheetId string, d []CellToUpdate) error {
+	// Prepare sheets.ValueRange array
+	var data []*sheets.ValueRange
+	// Cycle through d and populate request array
+	for _, cellRef := range d {
+		value := &sheets.ValueRange{
+			Range:  cellRef.Range,
+			Values: [][]interface{}{{cellRef.Value}},
+		}
+		data = append(data, value)
+	}
+
+	// Prepare batch request
+	rb := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "USER_ENTERED",
+		Data:             data,
+	}
+
+	_, err := b.srv.Spreadsheets.Values.BatchUpdate(sheetId, rb).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
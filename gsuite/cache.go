@@ -0,0 +1,119 @@
+package gsuite
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is used when ROSTER_CACHE_TTL is not set
+const defaultCacheTTL = 5 * time.Minute
+
+// BatchGetter is implemented by backends able to read several ranges in a single call
+type BatchGetter interface {
+	// BatchGet retrieve all values of every range in (ranges), results are in the same order
+	BatchGet(ctx context.Context, sheetId string, ranges []string) ([][][]interface{}, error)
+}
+
+// RosterCache keep recently read roster ranges, keyed by spreadsheet and range
+//
+// Entries expire after ttl and are dropped as soon as the service write to their tab.
+// Safe for concurrent use.
+type RosterCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	sheetId string
+	r       string
+}
+
+type cacheEntry struct {
+	tab     string
+	values  [][]interface{}
+	expires time.Time
+}
+
+var (
+	sharedCache     *RosterCache
+	sharedCacheOnce sync.Once
+)
+
+// NewRosterCache return an empty cache keeping entries for (ttl)
+func NewRosterCache(ttl time.Duration) *RosterCache {
+	return &RosterCache{ttl: ttl, now: time.Now, entries: map[cacheKey]cacheEntry{}}
+}
+
+// defaultCache return the process wide cache, nil if disabled with ROSTER_CACHE_TTL=0
+//
+// ROSTER_CACHE_TTL is a Go duration (90s, 5m)
+func defaultCache() *RosterCache {
+	sharedCacheOnce.Do(func() {
+		ttl := defaultCacheTTL
+		if env := os.Getenv("ROSTER_CACHE_TTL"); env != "" {
+			parsed, err := time.ParseDuration(env)
+			if err != nil {
+				fmt.Printf("Malformed ROSTER_CACHE_TTL %v, using %v: %v\n", env, defaultCacheTTL, err)
+			} else {
+				ttl = parsed
+			}
+		}
+		if ttl > 0 {
+			sharedCache = NewRosterCache(ttl)
+		}
+	})
+	return sharedCache
+}
+
+// get return cached values of range (r), if present and not expired
+func (c *RosterCache) get(sheetId string, r string) ([][]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[cacheKey{sheetId, r}]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expires) {
+		delete(c.entries, cacheKey{sheetId, r})
+		return nil, false
+	}
+	return entry.values, true
+}
+
+// put store values of range (r)
+func (c *RosterCache) put(sheetId string, r string, values [][]interface{}) {
+	var tab string
+	if parsed, err := ParseRange(r); err == nil {
+		tab = parsed.Sheet
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey{sheetId, r}] = cacheEntry{tab: tab, values: values, expires: c.now().Add(c.ttl)}
+}
+
+// Invalidate drop every cached range of (tab) in spreadsheet (sheetId)
+func (c *RosterCache) Invalidate(sheetId string, tab string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if key.sheetId == sheetId && entry.tab == tab {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateRange drop cached ranges of the tab range (r) belong to
+func (c *RosterCache) invalidateRange(sheetId string, r string) {
+	parsed, err := ParseRange(r)
+	if err != nil {
+		return
+	}
+	c.Invalidate(sheetId, parsed.Sheet)
+}
@@ -0,0 +1,117 @@
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingBackend count backend round trips
+type countingBackend struct {
+	*MemoryBackend
+	gets         int
+	batchGets    int
+	batchUpdates int
+}
+
+func (b *countingBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	b.gets++
+	return b.MemoryBackend.Get(ctx, sheetId, r)
+}
+
+func (b *countingBackend) BatchGet(ctx context.Context, sheetId string, ranges []string) ([][][]interface{}, error) {
+	b.batchGets++
+	return b.MemoryBackend.BatchGet(ctx, sheetId, ranges)
+}
+
+func (b *countingBackend) BatchUpdate(ctx context.Context, sheetId string, d []CellToUpdate) error {
+	b.batchUpdates++
+	return b.MemoryBackend.BatchUpdate(ctx, sheetId, d)
+}
+
+func TestService_ReadDayCached(t *testing.T) {
+	b := &countingBackend{MemoryBackend: newTestRoster(t)}
+	cache := NewRosterCache(time.Minute)
+	now := time.Date(2020, 1, 6, 8, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	s := Service{}
+	s.NewWithBackend(b, "roster")
+	s = s.WithCache(cache)
+
+	c := DayCoord{}
+	if err := c.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := c.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if b.gets != 1 {
+		t.Errorf("day coordinates read %d times, want 1", b.gets)
+	}
+
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	read := func(d time.Time) [][]interface{} {
+		res, err := s.ReadDay(c, d)
+		if err != nil {
+			t.Fatalf("ReadDay() error = %v", err)
+		}
+		return res
+	}
+
+	// Whole week prefetched on first read
+	read(monday)
+	if got := read(tuesday); len(got) != 2 || got[0][1] != "ROSSI" {
+		t.Errorf("ReadDay() got = %v", got)
+	}
+	if b.batchGets != 1 {
+		t.Errorf("week read %d times, want 1", b.batchGets)
+	}
+
+	// Own writes invalidate the week
+	if err := s.UpdateCell("2!F1", "neri"); err != nil {
+		t.Fatalf("UpdateCell() error = %v", err)
+	}
+	if got := read(tuesday); got[0][1] != "NERI" {
+		t.Errorf("ReadDay() after write got = %v, want NERI", got[0][1])
+	}
+	if b.batchGets != 2 {
+		t.Errorf("week read %d times after write, want 2", b.batchGets)
+	}
+
+	// Writes through an uncached copy invalidate too
+	if err := s.Uncached().UpdateCell("2!F1", "rossi"); err != nil {
+		t.Fatalf("UpdateCell() error = %v", err)
+	}
+	if got := read(tuesday); got[0][1] != "ROSSI" {
+		t.Errorf("ReadDay() after uncached write got = %v, want ROSSI", got[0][1])
+	}
+
+	// Entries expire after TTL
+	read(monday)
+	reads := b.batchGets
+	now = now.Add(2 * time.Minute)
+	read(monday)
+	if b.batchGets != reads+1 {
+		t.Errorf("week read %d times after TTL, want %d", b.batchGets, reads+1)
+	}
+}
+
+func TestService_ReadDayCancelled(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+
+	c := DayCoord{}
+	if err := c.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.WithContext(ctx).ReadDay(c, time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadDay() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -3,19 +3,14 @@ package gsuite
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"time"
 )
 
 type DayCoord struct {
-	sheetId   string
-	monday    string
-	tuesday   string
-	wednesday string
-	thursday  string
-	friday    string
-	saturday  string
-	sunday    string
+	sheetId string
+	layout  *Layout  // Layout day blocks were loaded from, weekly tabs are named after it
+	days    [7]Range // Day block ranges, indexed by time.Weekday (sunday first)
 }
 
 // Initialize a new gsheet day coordinates struct reading from gsheet range
@@ -23,46 +18,96 @@ type DayCoord struct {
 func (c *DayCoord) New() error {
 	// Create new gsheet service, passing config sheetId id
 	service := Service{}
-	c.sheetId = os.Getenv("SHIFT_ID")
-	err := service.New(c.sheetId)
+	err := service.New(os.Getenv("SHIFT_ID"))
 	if err != nil {
 		return errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
 	}
 
+	return c.Load(service)
+}
+
+// Load populate day coordinates from active layout, reading its days range through an existing service (s) if needed
+func (c *DayCoord) Load(s Service) error {
+	c.sheetId = s.sheetId
+	c.layout = ActiveLayout()
+
+	// Day blocks listed inline in layout, nothing to read
+	if len(c.layout.Days) > 0 {
+		coord, err := c.layout.dayBlocks()
+		if err != nil {
+			return err
+		}
+		return c.Update(coord)
+	}
+
 	// Call read method to actually retrieve data
-	response, err := service.ReadRange(os.Getenv("WEEKDAY_RANGE"))
+	response, err := s.readCached(c.layout.DaysRange)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error retrieving data from gsheet: %v\n", err))
+		return fmt.Errorf("error retrieving data from gsheet: %w", err)
 	}
 
-	// Cycle through response populating struct
-	c.monday = fmt.Sprintf("%s:%s", response[0][0], response[0][1])
-	c.tuesday = fmt.Sprintf("%s:%s", response[1][0], response[1][1])
-	c.wednesday = fmt.Sprintf("%s:%s", response[2][0], response[2][1])
-	c.thursday = fmt.Sprintf("%s:%s", response[3][0], response[3][1])
-	c.friday = fmt.Sprintf("%s:%s", response[4][0], response[4][1])
-	c.saturday = fmt.Sprintf("%s:%s", response[5][0], response[5][1])
-	c.sunday = fmt.Sprintf("%s:%s", response[6][0], response[6][1])
-	return nil
+	// Convert response to strings and populate struct
+	var coord [][]string
+	for _, row := range response {
+		var cells []string
+		for _, cell := range row {
+			cells = append(cells, fmt.Sprint(cell))
+		}
+		coord = append(coord, cells)
+	}
+	return c.Update(coord)
 }
 
 // Update day coordinates from passed 2d array of string
+//
+// coord must hold 7 rows, monday first, each with start and end cell of the day block
 func (c *DayCoord) Update(coord [][]string) error {
+	if len(coord) < 7 {
+		return errors.New(fmt.Sprintf("expected 7 day coordinates, got %d\n", len(coord)))
+	}
+
 	// Cycle through coord param populating struct
-	c.monday = fmt.Sprintf("%s:%s", coord[0][0], coord[0][1])
-	c.tuesday = fmt.Sprintf("%s:%s", coord[1][0], coord[1][1])
-	c.wednesday = fmt.Sprintf("%s:%s", coord[2][0], coord[2][1])
-	c.thursday = fmt.Sprintf("%s:%s", coord[3][0], coord[3][1])
-	c.friday = fmt.Sprintf("%s:%s", coord[4][0], coord[4][1])
-	c.saturday = fmt.Sprintf("%s:%s", coord[5][0], coord[5][1])
-	c.sunday = fmt.Sprintf("%s:%s", coord[6][0], coord[6][1])
+	for index, row := range coord[:7] {
+		if len(row) < 2 {
+			return errors.New(fmt.Sprintf("day %d coordinates must have start and end cell\n", index+1))
+		}
+		r, err := ParseRange(fmt.Sprintf("%s:%s", row[0], row[1]))
+		if err != nil {
+			return errors.New(fmt.Sprintf("malformed day %d coordinates: %v\n", index+1, err))
+		}
+		// Rows are monday first, time.Weekday is sunday first
+		c.days[(index+1)%7] = r
+	}
 
 	return nil
 }
 
-// Default error check with fatal if err != nil
-func CheckErrorAndPanic(err error) {
-	if err != nil {
-		log.Fatal(err)
+// Day return the block range of week day (w), without sheet name
+func (c DayCoord) Day(w time.Weekday) Range {
+	return c.days[w]
+}
+
+// Tab return the weekly roster tab holding date (d)
+func (c DayCoord) Tab(d time.Time) string {
+	if c.layout == nil {
+		return ActiveLayout().Tab(d)
 	}
+	return c.layout.Tab(d)
+}
+
+// Spreadsheet return the ID of the roster spreadsheet holding date (d)
+func (c DayCoord) Spreadsheet(d time.Time) string {
+	layout := c.layout
+	if layout == nil {
+		layout = ActiveLayout()
+	}
+	if sheetId := layout.Spreadsheet(d); sheetId != "" {
+		return sheetId
+	}
+	return c.sheetId
+}
+
+// CellAt return the roster coordinate (week!A1) of the cell at zero based (rowIndex, colIndex) inside day (d) block
+func (c DayCoord) CellAt(d time.Time, rowIndex int, colIndex int) string {
+	return offsetCoordinates(c, d, Cell{Col: colIndex + 1, Row: rowIndex + 1}.String())
 }
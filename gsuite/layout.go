@@ -0,0 +1,269 @@
+package gsuite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LayoutVersion is the layout descriptor version understood by this build
+const LayoutVersion = 1
+
+// Request sheet kinds, keys of Layout.Requests
+const (
+	RequestShifts      = "shifts"
+	RequestLicense     = "license"
+	RequestPermissions = "permissions"
+	RequestIllness     = "illness"
+)
+
+// Layout describe where roster and request data live in the spreadsheets
+//
+// Loaded from the JSON file in ROSTER_LAYOUT, see layout.example.json.
+// Day blocks are either listed inline (days) or read from a config range of the roster spreadsheet (days_range).
+// Weekly tabs are resolved by ISO year and week: years can map to their own spreadsheet and tab naming,
+// so dates around New Year land on the spreadsheet of the ISO year they belong to
+type Layout struct {
+	Version      int                `json:"version"`
+	WeekTab      string             `json:"week_tab"`      // Weekly tab name, {week} and {year} are replaced with ISO week and year
+	Days         []string           `json:"days"`          // 7 day block ranges without sheet (A1:C3), monday first
+	DaysRange    string             `json:"days_range"`    // Range holding start and end cell of the 7 day blocks (Config!A1:B7)
+	Roles        string             `json:"roles"`         // Roles mirror sheet range, laid out like day blocks
+	Requests     map[string]string  `json:"requests"`      // Request kind -> range submissions are appended to (Ferie!A4)
+	PostedShifts string             `json:"posted_shifts"` // Range posted shifts are read back from
+	Years        map[int]YearLayout `json:"years"`         // ISO year -> roster spreadsheet of that year
+
+	legacy bool // Built from env variables, days range is checked only when read like before layouts
+}
+
+// YearLayout is the roster spreadsheet of a single ISO year
+type YearLayout struct {
+	SpreadsheetId string `json:"spreadsheet_id"` // Empty for SHIFT_ID
+	WeekTab       string `json:"week_tab"`       // Empty for layout week_tab
+}
+
+// CurrentLayout is the layout loaded at startup, when nil ActiveLayout fall back to the legacy env variables
+var CurrentLayout *Layout
+
+// ActiveLayout return the layout in use
+func ActiveLayout() *Layout {
+	if CurrentLayout != nil {
+		return CurrentLayout
+	}
+	return LayoutFromEnv()
+}
+
+// LayoutFromEnv build the legacy layout: WEEKDAY_RANGE and ROLES_RANGE env variables, tabs named by ISO week
+//
+// Env variables keep their meaning from before layouts: WEEKDAY_RANGE is any range whose first 7 rows hold
+// day blocks start and end cell, of ROLES_RANGE only the sheet name is used, roles are read from its A1 cell on.
+// SHIFT_ID_<year> env variables (SHIFT_ID_2021) map an ISO year to its own roster spreadsheet
+func LayoutFromEnv() *Layout {
+	years := map[int]YearLayout{}
+	for _, env := range os.Environ() {
+		split := strings.SplitN(env, "=", 2)
+		if !strings.HasPrefix(split[0], "SHIFT_ID_") || len(split) != 2 {
+			continue
+		}
+		year, err := strconv.Atoi(strings.TrimPrefix(split[0], "SHIFT_ID_"))
+		if err != nil {
+			continue
+		}
+		years[year] = YearLayout{SpreadsheetId: split[1]}
+	}
+
+	return &Layout{
+		Version:   LayoutVersion,
+		WeekTab:   "{week}",
+		DaysRange: os.Getenv("WEEKDAY_RANGE"),
+		Roles:     legacyRoles(os.Getenv("ROLES_RANGE")),
+		Requests: map[string]string{
+			RequestShifts:      "Cartellini!A4",
+			RequestLicense:     "Ferie!A4",
+			RequestPermissions: "PermessiOrari!A4",
+			RequestIllness:     "Malattie!A4",
+		},
+		PostedShifts: "Cartellini!B4:C",
+		Years:        years,
+		legacy:       true,
+	}
+}
+
+// legacyRoles return the roles range of ROLES_RANGE env variable (env): whole sheet named before the "!"
+//
+// Roles cells used to be read at the same coordinates of roster cells, whatever range followed the sheet name
+func legacyRoles(env string) string {
+	index := strings.Index(env, "!")
+	if index < 0 {
+		return env
+	}
+	return env[:index+1] + "A:ZZZ"
+}
+
+// LoadLayout read and validate a JSON layout descriptor from (path)
+func LoadLayout(path string) (*Layout, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error reading layout file: %v\n", err))
+	}
+
+	var l Layout
+	if err = json.Unmarshal(content, &l); err != nil {
+		return nil, errors.New(fmt.Sprintf("error parsing layout file: %v\n", err))
+	}
+	if err = l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Validate check the layout is complete and every range is well formed
+func (l *Layout) Validate() error {
+	if l.Version != LayoutVersion {
+		return errors.New(fmt.Sprintf("unsupported layout version %d, expected %d", l.Version, LayoutVersion))
+	}
+	if !strings.Contains(l.WeekTab, "{week}") {
+		return errors.New("layout week_tab must contain {week}")
+	}
+
+	// Day blocks
+	switch {
+	case len(l.Days) > 0 && l.DaysRange != "":
+		return errors.New("layout must set either days or days_range, not both")
+	case len(l.Days) > 0:
+		if _, err := l.dayBlocks(); err != nil {
+			return err
+		}
+	case l.DaysRange != "":
+		r, err := ParseRange(l.DaysRange)
+		if err != nil {
+			return errors.New(fmt.Sprintf("malformed layout days_range: %v", err))
+		}
+		if !l.legacy && (r.Sheet == "" || r.Rows() != 7 || r.Cols() != 2) {
+			return errors.New(fmt.Sprintf("layout days_range %s must be a 7x2 range with sheet name", l.DaysRange))
+		}
+	default:
+		return errors.New("layout must set days or days_range")
+	}
+
+	if err := l.validateYears(); err != nil {
+		return err
+	}
+
+	if err := requireSheetRange("roles", l.Roles); err != nil {
+		return err
+	}
+	for _, kind := range []string{RequestShifts, RequestLicense, RequestPermissions, RequestIllness} {
+		if err := requireSheetRange("requests."+kind, l.Requests[kind]); err != nil {
+			return err
+		}
+	}
+	return requireSheetRange("posted_shifts", l.PostedShifts)
+}
+
+// Tab return the weekly roster tab holding date (d)
+func (l *Layout) Tab(d time.Time) string {
+	year, week := d.ISOWeek()
+	return strings.NewReplacer("{week}", strconv.Itoa(week), "{year}", strconv.Itoa(year)).Replace(l.weekTab(year))
+}
+
+// Spreadsheet return the roster spreadsheet ID holding date (d), empty if it's SHIFT_ID
+func (l *Layout) Spreadsheet(d time.Time) string {
+	year, _ := d.ISOWeek()
+	return l.Years[year].SpreadsheetId
+}
+
+// weekTab return tab naming of ISO (year)
+func (l *Layout) weekTab(year int) string {
+	if tab := l.Years[year].WeekTab; tab != "" {
+		return tab
+	}
+	return l.WeekTab
+}
+
+// validateYears check every year tab naming and that no two years resolve to the same tabs
+func (l *Layout) validateYears() error {
+	var years []int
+	for year := range l.Years {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	seen := map[string]int{} // spreadsheet ID -> year, for spreadsheets with year agnostic tab names
+	for _, year := range years {
+		tab := l.weekTab(year)
+		if !strings.Contains(tab, "{week}") {
+			return errors.New(fmt.Sprintf("layout week_tab of year %d must contain {week}", year))
+		}
+		if strings.Contains(tab, "{year}") {
+			continue
+		}
+		sheetId := l.Years[year].SpreadsheetId
+		if other, ok := seen[sheetId]; ok {
+			return errors.New(fmt.Sprintf("layout years %d and %d share spreadsheet and tab names, add {year} to week_tab", other, year))
+		}
+		seen[sheetId] = year
+	}
+	return nil
+}
+
+// Request return the range submissions of (kind) are appended to
+func (l *Layout) Request(kind string) string {
+	return l.Requests[kind]
+}
+
+// dayBlocks parse inline day blocks as rows of start and end cell, the format DayCoord.Update expect
+func (l *Layout) dayBlocks() ([][]string, error) {
+	if len(l.Days) != 7 {
+		return nil, errors.New(fmt.Sprintf("layout days must list 7 day blocks, got %d", len(l.Days)))
+	}
+
+	var (
+		coord  [][]string
+		blocks []Range
+	)
+	for index, day := range l.Days {
+		r, err := ParseRange(day)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("malformed layout day %d: %v", index+1, err))
+		}
+		if r.Sheet != "" || r.Rows() == 0 || r.Cols() == 0 {
+			return nil, errors.New(fmt.Sprintf("layout day %d must be a bounded range without sheet name", index+1))
+		}
+		for other, block := range blocks {
+			if overlap(r, block) {
+				return nil, errors.New(fmt.Sprintf("layout day %d overlaps day %d", index+1, other+1))
+			}
+		}
+		blocks = append(blocks, r)
+		coord = append(coord, []string{r.Start.String(), r.End.String()})
+	}
+	return coord, nil
+}
+
+// requireSheetRange check (r) is a well formed range including sheet name
+func requireSheetRange(name string, r string) error {
+	if r == "" {
+		return errors.New(fmt.Sprintf("layout %s is missing", name))
+	}
+	parsed, err := ParseRange(r)
+	if err != nil {
+		return errors.New(fmt.Sprintf("malformed layout %s: %v", name, err))
+	}
+	if parsed.Sheet == "" {
+		return errors.New(fmt.Sprintf("layout %s must contain sheet name", name))
+	}
+	return nil
+}
+
+// overlap tell if two bounded ranges share any cell
+func overlap(a Range, b Range) bool {
+	return a.Start.Col <= b.End.Col && b.Start.Col <= a.End.Col &&
+		a.Start.Row <= b.End.Row && b.Start.Row <= a.End.Row
+}
@@ -0,0 +1,207 @@
+package gsuite
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testLayout return a valid layout with inline day blocks matching newTestRoster
+func testLayout() *Layout {
+	return &Layout{
+		Version: LayoutVersion,
+		WeekTab: "{year}-W{week}",
+		Days:    []string{"A1:C3", "E1:G3", "I1:K3", "M1:O3", "Q1:S3", "U1:W3", "Y1:AA3"},
+		Roles:   "Ruoli!A1:C3",
+		Requests: map[string]string{
+			RequestShifts:      "Cartellini!A4",
+			RequestLicense:     "Ferie!A4",
+			RequestPermissions: "PermessiOrari!A4",
+			RequestIllness:     "Malattie!A4",
+		},
+		PostedShifts: "Cartellini!B4:C",
+	}
+}
+
+func TestLayout_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(l *Layout)
+		wantErr bool
+	}{
+		{
+			name: "Valid inline days",
+			edit: func(l *Layout) {},
+		},
+		{
+			name: "Valid days range",
+			edit: func(l *Layout) { l.Days = nil; l.DaysRange = "Config!A1:B7" },
+		},
+		{
+			name:    "Unsupported version",
+			edit:    func(l *Layout) { l.Version = 2 },
+			wantErr: true,
+		},
+		{
+			name:    "Week tab without week",
+			edit:    func(l *Layout) { l.WeekTab = "Roster {year}" },
+			wantErr: true,
+		},
+		{
+			name:    "Both days and days range",
+			edit:    func(l *Layout) { l.DaysRange = "Config!A1:B7" },
+			wantErr: true,
+		},
+		{
+			name:    "Six days",
+			edit:    func(l *Layout) { l.Days = l.Days[:6] },
+			wantErr: true,
+		},
+		{
+			name:    "Overlapping days",
+			edit:    func(l *Layout) { l.Days[1] = "C1:E3" },
+			wantErr: true,
+		},
+		{
+			name:    "Days range not 7x2",
+			edit:    func(l *Layout) { l.Days = nil; l.DaysRange = "Config!A1:B6" },
+			wantErr: true,
+		},
+		{
+			name: "Years with own spreadsheet",
+			edit: func(l *Layout) {
+				l.WeekTab = "{week}"
+				l.Years = map[int]YearLayout{2020: {SpreadsheetId: "a"}, 2021: {SpreadsheetId: "b"}}
+			},
+		},
+		{
+			name: "Years sharing spreadsheet with year in tab name",
+			edit: func(l *Layout) {
+				l.Years = map[int]YearLayout{2020: {SpreadsheetId: "a"}, 2021: {SpreadsheetId: "a"}}
+			},
+		},
+		{
+			name: "Years sharing spreadsheet and tab names",
+			edit: func(l *Layout) {
+				l.WeekTab = "{week}"
+				l.Years = map[int]YearLayout{2020: {SpreadsheetId: "a"}, 2021: {SpreadsheetId: "a"}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Missing request sheet",
+			edit:    func(l *Layout) { delete(l.Requests, RequestIllness) },
+			wantErr: true,
+		},
+		{
+			name:    "Roles without sheet",
+			edit:    func(l *Layout) { l.Roles = "A1:C3" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := testLayout()
+			tt.edit(l)
+			if err := l.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLayout_Tab(t *testing.T) {
+	l := testLayout()
+	l.Years = map[int]YearLayout{2021: {SpreadsheetId: "roster-2021", WeekTab: "W{week}"}}
+
+	tests := []struct {
+		date      time.Time
+		wantTab   string
+		wantSheet string
+	}{
+		{time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC), "2020-W53", ""},
+		{time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC), "2020-W53", ""}, // Sunday, still ISO 2020
+		{time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), "W1", "roster-2021"},
+		{time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC), "2020-W1", ""}, // Monday, already ISO 2020
+	}
+	for _, tt := range tests {
+		if got := l.Tab(tt.date); got != tt.wantTab {
+			t.Errorf("Tab(%v) = %v, want %v", tt.date, got, tt.wantTab)
+		}
+		if got := l.Spreadsheet(tt.date); got != tt.wantSheet {
+			t.Errorf("Spreadsheet(%v) = %v, want %v", tt.date, got, tt.wantSheet)
+		}
+	}
+}
+
+func TestLoadLayout_Example(t *testing.T) {
+	if _, err := LoadLayout("../layout.example.json"); err != nil {
+		t.Errorf("LoadLayout() error = %v", err)
+	}
+}
+
+func TestService_ReadDayWithLayout(t *testing.T) {
+	m := newTestRoster(t)
+	CurrentLayout = testLayout()
+	defer func() { CurrentLayout = nil }()
+
+	// Same content as week 2 tab, named after layout
+	err := m.SetRange("roster", "2020-W2!E1", [][]string{{"GIALLI", "ROSSI"}, {"VERDI"}})
+	if err != nil {
+		t.Fatalf("SetRange() error = %v", err)
+	}
+
+	s := Service{}
+	s.NewWithBackend(m, "roster")
+	c := DayCoord{}
+	if err = c.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tuesday := time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+	got, err := s.ReadDay(c, tuesday)
+	if err != nil {
+		t.Fatalf("ReadDay() error = %v", err)
+	}
+	want := [][]interface{}{{"GIALLI", "ROSSI"}, {"VERDI"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDay() got = %v, want %v", got, want)
+	}
+	if cell := c.CellAt(tuesday, 1, 0); cell != "'2020-W2'!E2" {
+		t.Errorf("CellAt() got = %v, want '2020-W2'!E2", cell)
+	}
+}
+
+func TestLayoutFromEnv_Legacy(t *testing.T) {
+	m := newTestRoster(t)
+	// Ranges as written before layouts: a wider weekday range and a roles range not starting at A1
+	os.Setenv("WEEKDAY_RANGE", "Config!A1:C10")
+	os.Setenv("ROLES_RANGE", "Ruoli!B2:D4")
+	defer os.Setenv("ROLES_RANGE", "Ruoli!A1:C3")
+
+	l := LayoutFromEnv()
+	if err := l.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	s := Service{}
+	s.NewWithBackend(m, "roster")
+	dayCoord := DayCoord{}
+	if err := dayCoord.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	day, err := s.ReadDay(dayCoord, time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ReadDay() error = %v", err)
+	}
+
+	// Only sheet name is taken from ROLES_RANGE, roles are still read from A1
+	got, err := s.GetOperatorRoles(day, "rossi")
+	if err != nil {
+		t.Fatalf("GetOperatorRoles() error = %v", err)
+	}
+	if want := "Sede|Mattino|MSB1|Soccorritore"; got != want {
+		t.Errorf("GetOperatorRoles() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,235 @@
+package gsuite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend is a Backend keeping whole spreadsheets in memory
+//
+// Behave like Google Sheets for what the service need: values are stored and returned as strings,
+// trailing empty cells and rows are trimmed from read results.
+// Safe for concurrent use.
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	sheets map[string]map[string][][]string // spreadsheet ID -> tab name -> rows
+}
+
+// NewMemoryBackend return an empty in memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{sheets: map[string]map[string][][]string{}}
+}
+
+// LoadMemoryBackend create an in memory backend from a JSON workbook file
+//
+// File format: {"spreadsheetId": {"tabName": [["A1", "B1"], ["A2", "B2"]]}}
+func LoadMemoryBackend(path string) (*MemoryBackend, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error reading workbook file: %v\n", err))
+	}
+
+	m := NewMemoryBackend()
+	if err = json.Unmarshal(content, &m.sheets); err != nil {
+		return nil, errors.New(fmt.Sprintf("error parsing workbook file: %v\n", err))
+	}
+	return m, nil
+}
+
+// SetRange write (values) starting from the top left cell of range (r), creating spreadsheet and tab if needed
+func (m *MemoryBackend) SetRange(sheetId string, r string, values [][]string) error {
+	rng, err := parseMemRange(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for rowIndex, row := range values {
+		for colIndex, value := range row {
+			m.set(sheetId, rng.Sheet, rng.Start.Row+rowIndex, rng.Start.Col+colIndex, value)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rng, err := parseMemRange(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	grid, err := m.tab(sheetId, rng.Sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	var res [][]interface{}
+	lastRow := len(grid)
+	if rng.End.Row != 0 && rng.End.Row < lastRow {
+		lastRow = rng.End.Row
+	}
+	for rowIndex := rng.Start.Row; rowIndex <= lastRow; rowIndex++ {
+		row := grid[rowIndex-1]
+		lastCol := len(row)
+		if rng.End.Col != 0 && rng.End.Col < lastCol {
+			lastCol = rng.End.Col
+		}
+
+		var values []interface{}
+		for colIndex := rng.Start.Col; colIndex <= lastCol; colIndex++ {
+			values = append(values, row[colIndex-1])
+		}
+		// Trim trailing empty cells like Google does
+		for len(values) > 0 && values[len(values)-1] == "" {
+			values = values[:len(values)-1]
+		}
+		res = append(res, values)
+	}
+
+	// Trim trailing empty rows
+	for len(res) > 0 && len(res[len(res)-1]) == 0 {
+		res = res[:len(res)-1]
+	}
+	return res, nil
+}
+
+func (m *MemoryBackend) BatchGet(ctx context.Context, sheetId string, ranges []string) ([][][]interface{}, error) {
+	var res [][][]interface{}
+	for _, r := range ranges {
+		values, err := m.Get(ctx, sheetId, r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, values)
+	}
+	return res, nil
+}
+
+func (m *MemoryBackend) Append(ctx context.Context, sheetId string, r string, data [][]interface{}) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	rng, err := parseMemRange(r)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Find the first empty row after the table starting at range
+	row := rng.Start.Row
+	if grid, err := m.tab(sheetId, rng.Sheet); err == nil {
+		for index := len(grid); index >= rng.Start.Row; index-- {
+			if !emptyRow(grid[index-1], rng.Start.Col) {
+				row = index + 1
+				break
+			}
+		}
+	}
+
+	for rowIndex, values := range data {
+		for colIndex, value := range values {
+			m.set(sheetId, rng.Sheet, row+rowIndex, rng.Start.Col+colIndex, cellString(value))
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func (m *MemoryBackend) BatchUpdate(ctx context.Context, sheetId string, d []CellToUpdate) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Parse everything before writing so a bad range doesn't leave a partial update
+	ranges := make([]Range, len(d))
+	for i, cellRef := range d {
+		rng, err := parseMemRange(cellRef.Range)
+		if err != nil {
+			return err
+		}
+		ranges[i] = rng
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, rng := range ranges {
+		m.set(sheetId, rng.Sheet, rng.Start.Row, rng.Start.Col, d[i].Value)
+	}
+	return nil
+}
+
+// tab return the rows of the requested tab, caller must hold the lock
+func (m *MemoryBackend) tab(sheetId string, tab string) ([][]string, error) {
+	spreadsheet, ok := m.sheets[sheetId]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("spreadsheet %s not found", sheetId))
+	}
+	grid, ok := spreadsheet[tab]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unable to parse range: %s not found", tab))
+	}
+	return grid, nil
+}
+
+// set write a single cell growing the grid as needed, caller must hold the write lock
+func (m *MemoryBackend) set(sheetId string, tab string, row int, col int, value string) {
+	if m.sheets[sheetId] == nil {
+		m.sheets[sheetId] = map[string][][]string{}
+	}
+	grid := m.sheets[sheetId][tab]
+	for len(grid) < row {
+		grid = append(grid, nil)
+	}
+	for len(grid[row-1]) < col {
+		grid[row-1] = append(grid[row-1], "")
+	}
+	grid[row-1][col-1] = value
+	m.sheets[sheetId][tab] = grid
+}
+
+// emptyRow check if every cell from column (from) on is empty
+func emptyRow(row []string, from int) bool {
+	for colIndex := from; colIndex <= len(row); colIndex++ {
+		if row[colIndex-1] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// cellString convert an appended value to the string Google Sheets would show
+func cellString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(value))
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// parseMemRange parse a range in the !A1 format, sheet name is required
+func parseMemRange(r string) (Range, error) {
+	rng, err := ParseRange(r)
+	if err != nil {
+		return rng, err
+	}
+	if rng.Sheet == "" {
+		return rng, errors.New(fmt.Sprintf("range %s must contain sheet name", r))
+	}
+	return rng, nil
+}
@@ -0,0 +1,227 @@
+package gsuite
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestRoster return a memory backend holding a roster spreadsheet with week 2 of 2020 populated
+//
+// Every day block is 3x3 cells wide, laid out horizontally with a blank column in between
+func newTestRoster(t *testing.T) *MemoryBackend {
+	os.Setenv("WEEKDAY_RANGE", "Config!A1:B7")
+	os.Setenv("ROLES_RANGE", "Ruoli!A1:C3")
+
+	m := NewMemoryBackend()
+	fixtures := []struct {
+		r      string
+		values [][]string
+	}{
+		{"Config!A1", [][]string{
+			{"A1", "C3"},
+			{"E1", "G3"},
+			{"I1", "K3"},
+			{"M1", "O3"},
+			{"Q1", "S3"},
+			{"U1", "W3"},
+			{"Y1", "AA3"},
+		}},
+		{"Ruoli!A1", [][]string{
+			{"Sede|Mattino|MSB1|Autista", "Sede|Mattino|MSB1|Soccorritore", "Sede|Mattino|MSB1|Capo"},
+			{"Sede|Pomeriggio|MSB1|Autista", "Sede|Pomeriggio|MSB1|Soccorritore", ""},
+			{"Sede|Notte|MSB2|Autista", "", ""},
+		}},
+		// Monday 2020-01-06
+		{"2!A1", [][]string{
+			{"ROSSI", "BIANCHI", "VERDI"},
+			{"NERI", "GIALLI"},
+			{"BLU"},
+		}},
+		// Tuesday 2020-01-07
+		{"2!E1", [][]string{
+			{"GIALLI", "ROSSI"},
+			{"VERDI"},
+		}},
+		// Sunday 2020-01-12, block spans columns beyond Z
+		{"2!Y1", [][]string{
+			{"BLU", "NERI", "BIANCHI"},
+		}},
+	}
+	for _, f := range fixtures {
+		if err := m.SetRange("roster", f.r, f.values); err != nil {
+			t.Fatalf("error populating test roster: %v", err)
+		}
+	}
+	return m
+}
+
+func TestMemoryBackend_Get(t *testing.T) {
+	m := newTestRoster(t)
+
+	tests := []struct {
+		name    string
+		r       string
+		want    [][]interface{}
+		wantErr bool
+	}{
+		{
+			name: "Single cell",
+			r:    "2!B1",
+			want: [][]interface{}{{"BIANCHI"}},
+		},
+		{
+			name: "Trailing empty cells trimmed",
+			r:    "2!A1:C3",
+			want: [][]interface{}{{"ROSSI", "BIANCHI", "VERDI"}, {"NERI", "GIALLI"}, {"BLU"}},
+		},
+		{
+			name: "Open ended range",
+			r:    "2!E1:F",
+			want: [][]interface{}{{"GIALLI", "ROSSI"}, {"VERDI"}},
+		},
+		{
+			name:    "Missing tab",
+			r:       "3!A1:C3",
+			wantErr: true,
+		},
+		{
+			name:    "Missing sheet name",
+			r:       "A1:C3",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.Get(context.Background(), "roster", tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryBackend_Append(t *testing.T) {
+	m := NewMemoryBackend()
+	s := Service{}
+	s.NewWithBackend(m, "requests")
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Append("Ferie!A4", [][]interface{}{{"ROSSI MARIO", true, 3}})
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	got, err := s.ReadRange("Ferie!A4:C")
+	if err != nil {
+		t.Fatalf("ReadRange() error = %v", err)
+	}
+	want := [][]interface{}{{"ROSSI MARIO", "TRUE", "3"}, {"ROSSI MARIO", "TRUE", "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Append() got = %v, want %v", got, want)
+	}
+}
+
+func TestService_GetOperatorRoles(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+
+	dayCoord := DayCoord{}
+	if err := dayCoord.Load(s); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	day, err := s.ReadDay(dayCoord, time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ReadDay() error = %v", err)
+	}
+
+	got, err := s.GetOperatorRoles(day, "rossi")
+	if err != nil {
+		t.Fatalf("GetOperatorRoles() error = %v", err)
+	}
+	if want := "Sede|Mattino|MSB1|Soccorritore"; got != want {
+		t.Errorf("GetOperatorRoles() got = %v, want %v", got, want)
+	}
+
+	if _, err = s.GetOperatorRoles(day, "BLU"); err == nil {
+		t.Errorf("GetOperatorRoles() expected error for operator not in shift")
+	}
+}
+
+func TestService_GetOperatorRoles_rolesRange(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+	day := [][]interface{}{{"ROSSI", "BIANCHI"}, {"NERI", "GIALLI"}}
+
+	tests := []struct {
+		name     string
+		roles    string
+		operator string
+		want     string
+		wantErr  bool
+	}{
+		{"legacy whole sheet", "Ruoli!A:ZZZ", "GIALLI", "Sede|Pomeriggio|MSB1|Soccorritore", false},
+		{"from A1", "Ruoli!A1:C3", "BIANCHI", "Sede|Mattino|MSB1|Soccorritore", false},
+		{"offset", "Ruoli!B2:Z200", "GIALLI", "Sede|Pomeriggio|MSB1|Soccorritore", false},
+		{"offset row only", "Ruoli!A2:Z200", "NERI", "Sede|Pomeriggio|MSB1|Autista", false},
+		{"before range start", "Ruoli!B2:Z200", "BIANCHI", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := *LayoutFromEnv()
+			layout.Roles = tt.roles
+			CurrentLayout = &layout
+			defer func() { CurrentLayout = nil }()
+
+			got, err := s.GetOperatorRoles(day, tt.operator)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOperatorRoles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetOperatorRoles() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_FindOperator(t *testing.T) {
+	day := [][]interface{}{
+		{"ROSSI", "DE LUCA", "VERDI"},
+		{"NERI", "Rossi M."},
+		{"BLU", "verdi"},
+	}
+	tests := []struct {
+		name      string
+		labels    []string
+		wantCell  string
+		wantLabel string
+		wantErr   bool
+	}{
+		{name: "Compound surname", labels: []string{"De Luca"}, wantCell: "B1", wantLabel: "DE LUCA"},
+		{name: "Found by alias", labels: []string{"NERI LUCA", "neri"}, wantCell: "A2", wantLabel: "NERI"},
+		{name: "Alias tells apart", labels: []string{"ROSSI M."}, wantCell: "B2", wantLabel: "Rossi M."},
+		{name: "Found twice", labels: []string{"VERDI"}, wantErr: true},
+		{name: "Aliases in two cells", labels: []string{"NERI", "BLU"}, wantErr: true},
+		{name: "Not found", labels: []string{"GIALLI"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell, label, err := Service{}.FindOperator(day, tt.labels...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindOperator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cell != tt.wantCell || label != tt.wantLabel {
+				t.Errorf("FindOperator() got = %v %v, want %v %v", cell, label, tt.wantCell, tt.wantLabel)
+			}
+		})
+	}
+}
@@ -4,79 +4,144 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
-	"google.golang.org/api/sheets/v4"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
+// ErrOperatorNotFound is returned when none of the operator labels is written on the searched day
+var ErrOperatorNotFound = errors.New("no roles found for passed operator")
+
 type Service struct {
-	srv     *sheets.Service
+	backend Backend
 	sheetId string
+	cache   *RosterCache // Roster reads cache, nil to always read from backend
+	fresh   bool         // Bypass cache on reads, writes still invalidate it
+	ctx     context.Context
 }
 
 type CellToUpdate struct {
-	Range string //A1 range notation
-	Value string //Content to put in the cell
+	Range   string //A1 range notation
+	Value   string //Content to put in the cell
+	SheetId string //Spreadsheet holding the cell, empty for service spreadsheet
 }
 
 // Represent Google API service with auth and sheetId ID read from env
 // GOOGLE_API is the auth secret
 // SHEETS_ID is the sheetId to read from
+//
+// If DefaultBackend is set it will be used instead of Google Sheets.
+// Google client and roster cache are shared by every service of the process
 func (s *Service) New(sheetId string) error {
-	secret := os.Getenv("GOOGLE_API")
-	if secret == "" {
-		panic("Can't read secret from env.")
+	s.sheetId = sheetId
+	s.cache = defaultCache()
+	if DefaultBackend != nil {
+		s.backend = DefaultBackend
+		return nil
 	}
-	conf, err := google.JWTConfigFromJSON([]byte(secret), sheets.SpreadsheetsScope)
-	CheckErrorAndPanic(err)
 
-	srv, err := sheets.NewService(context.TODO(), option.WithHTTPClient(conf.Client(context.TODO())))
-	CheckErrorAndPanic(err)
+	backend, err := sharedGoogleBackend()
+	if err != nil {
+		return err
+	}
+	s.backend = backend
+	return nil
+}
 
-	s.srv = srv
+// NewWithOptions create a service with its own Google Sheets client, configured with (opts)
+//
+// Client is not shared and reads are not cached. Used to talk to another Sheets API server:
+//
+//	s.NewWithOptions(sheetId, option.WithEndpoint(fake.URL+"/"))
+func (s *Service) NewWithOptions(sheetId string, opts ...option.ClientOption) error {
+	backend, err := newGoogleBackend(opts...)
+	if err != nil {
+		return err
+	}
+	s.backend = backend
 	s.sheetId = sheetId
 	return nil
 }
 
+// NewWithBackend create a service reading and writing (sheetId) on passed backend (b)
+func (s *Service) NewWithBackend(b Backend, sheetId string) {
+	s.backend = b
+	s.sheetId = sheetId
+}
+
+// SheetId return the spreadsheet ID service read and write
+func (s Service) SheetId() string {
+	return s.sheetId
+}
+
+// WithSheet return a copy of the service working on (sheetId) spreadsheet through the same backend
+func (s Service) WithSheet(sheetId string) Service {
+	if sheetId != "" {
+		s.sheetId = sheetId
+	}
+	return s
+}
+
+// WithContext return a copy of the service whose calls are cancelled with (ctx)
+func (s Service) WithContext(ctx context.Context) Service {
+	s.ctx = ctx
+	return s
+}
+
+// Context return the context calls are cancelled with
+func (s Service) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// WithCache return a copy of the service caching roster reads in (c), nil disable caching
+func (s Service) WithCache(c *RosterCache) Service {
+	s.cache = c
+	return s
+}
+
+// Uncached return a copy of the service always reading from backend, for callers that can't tolerate stale reads
+//
+// Writes still invalidate the cache, so other services don't serve what this one overwrote
+func (s Service) Uncached() Service {
+	s.fresh = true
+	return s
+}
+
+// ForDay return a copy of the service working on the roster spreadsheet holding day (t)
+func (s Service) ForDay(c DayCoord, t time.Time) Service {
+	return s.WithSheet(c.Spreadsheet(t))
+}
+
 // Append data after selected range and return the result
 // r string: the range after witch append data in the !A1 format (Sheet!A1:B2)
 // data [][]interface{}: 2D array with data to append
 // Return int with return code (HTTPStatusCode)
 func (s Service) Append(r string, data [][]interface{}) (int, error) {
-	var values = sheets.ValueRange{
-		Values: data,
-	}
-
-	res, err := s.srv.Spreadsheets.Values.Append(s.sheetId, r, &values).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return 0, err
-	}
-	return res.HTTPStatusCode, nil
+	s.invalidate(s.sheetId, r)
+	return s.backend.Append(s.Context(), s.sheetId, r, data)
 }
 
 // ReadRange read data from selected range and return it
 // r string: Range to search in !A1 format
 // Return [][]interface{}: retrieved data
 func (s Service) ReadRange(r string) ([][]interface{}, error) {
-	res, err := s.srv.Spreadsheets.Values.Get(s.sheetId, r).Do()
-	if err != nil {
-		return nil, err
-	}
-	return res.Values, nil
+	return s.backend.Get(s.Context(), s.sheetId, r)
 }
 
 // Read a single cell, if passed a bigger range discard all but single cell and return it
 func (s Service) ReadCell(r string) (string, error) {
-	res, err := s.srv.Spreadsheets.Values.Get(s.sheetId, r).Do()
+	res, err := s.backend.Get(s.Context(), s.sheetId, r)
 	if err != nil {
 		return "", err
 	}
 
-	cell := res.Values[0][0].(string)
+	if len(res) == 0 || len(res[0]) == 0 {
+		return "", errors.New("no cell found")
+	}
+	cell := res[0][0].(string)
 	if cell == "" {
 		return "", errors.New("no cell found")
 	}
@@ -85,45 +150,39 @@ func (s Service) ReadCell(r string) (string, error) {
 
 // UpdateCell update passed (r) cell in A1 notation with passed (v) string
 func (s Service) UpdateCell(r string, v string) error {
-	// Prepare sheets.ValueRange
-	data := [][]interface{}{
-		{strings.ToUpper(v)},
-	}
-	var values = sheets.ValueRange{
-		Values: data,
-	}
-
-	_, err := s.srv.Spreadsheets.Values.Update(s.sheetId, r, &values).ValueInputOption("USER_ENTERED").Do()
-	if err != nil {
-		return err
-	}
-	return nil
+	return s.BatchUpdateCells([]CellToUpdate{{Range: r, Value: v}})
 }
 
 // BatchUpdateCells update passed array of cells
+//
+// Cells are grouped by spreadsheet, every spreadsheet is updated with a single batch.
+// Batches are not atomic across spreadsheets: if one fails the previous ones are kept
 func (s Service) BatchUpdateCells(d []CellToUpdate) error {
-	// Prepare sheets.ValueRange array
-	var data []*sheets.ValueRange
-	// Cycle through d and populate request array
+	var (
+		sheetIds []string
+		data     = map[string][]CellToUpdate{}
+	)
 	for _, cellRef := range d {
-		value := &sheets.ValueRange{
-			Range:  cellRef.Range,
-			Values: [][]interface{}{{strings.ToUpper(cellRef.Value)}},
+		sheetId := cellRef.SheetId
+		if sheetId == "" {
+			sheetId = s.sheetId
 		}
-		data = append(data, value)
-	}
-
-	// Prepare batch request
-	rb := &sheets.BatchUpdateValuesRequest{
-		ValueInputOption: "USER_ENTERED",
-		Data:             data,
+		if _, ok := data[sheetId]; !ok {
+			sheetIds = append(sheetIds, sheetId)
+		}
+		// Values are always written uppercase
+		data[sheetId] = append(data[sheetId], CellToUpdate{Range: cellRef.Range, Value: strings.ToUpper(cellRef.Value)})
 	}
 
-	_, err := s.srv.Spreadsheets.Values.BatchUpdate(s.sheetId, rb).Do()
-	if err != nil {
-		return err
+	for _, sheetId := range sheetIds {
+		// Drop cached reads of written tabs, even if the write fail it may be partially applied
+		for _, cellRef := range data[sheetId] {
+			s.invalidate(sheetId, cellRef.Range)
+		}
+		if err := s.backend.BatchUpdate(s.Context(), sheetId, data[sheetId]); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
@@ -131,109 +190,148 @@ func (s Service) BatchUpdateCells(d []CellToUpdate) error {
 // c DayCoord: Gsheet search ranges
 // t Time: Day to retrieve
 // Return [][]interface{}: requested day data
+//
+// When caching, the whole week is read at once so following days of the same week are served from cache
 func (s Service) ReadDay(c DayCoord, t time.Time) ([][]interface{}, error) {
-	_, week := t.ISOWeek() // Read weekday from passed time (will be sgheet tab reference)
-	day := t.Weekday()     // Read week day from passed time (will be matched vs DayCoord for interval)
-	var searchRange string
+	srv := s.ForDay(c, t)
 
-	// Switch week day and set search range
-	switch day {
-	case 0:
-		searchRange = c.sunday
-	case 1:
-		searchRange = c.monday
-	case 2:
-		searchRange = c.tuesday
-	case 3:
-		searchRange = c.wednesday
-	case 4:
-		searchRange = c.thursday
-	case 5:
-		searchRange = c.friday
-	case 6:
-		searchRange = c.saturday
-	}
-
-	// Actually retrieve data from gsheet and return
-	query := fmt.Sprintf("%s!%s", strconv.Itoa(week), searchRange)
-	res, err := s.srv.Spreadsheets.Values.Get(s.sheetId, query).Do()
+	// Day block range of every week day, on the weekly tab holding (t)
+	var ranges []string
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		r := c.Day(weekday)
+		r.Sheet = c.Tab(t)
+		ranges = append(ranges, r.String())
+	}
+	searchRange := ranges[t.Weekday()]
+
+	if srv.cache == nil || srv.fresh {
+		return srv.ReadRange(searchRange)
+	}
+	if res, ok := srv.cache.get(srv.sheetId, searchRange); ok {
+		return res, nil
+	}
+
+	// Actually retrieve the whole week from gsheet and cache it
+	week, err := srv.batchGet(ranges)
 	if err != nil {
 		return nil, err
 	}
-
-	return res.Values, nil
+	for index, r := range ranges {
+		srv.cache.put(srv.sheetId, r, week[index])
+	}
+	return week[t.Weekday()], nil
 }
 
-// GetOperatorRoles search for name (n) in 2D array (d) and return assigned roles for that day
+// GetOperatorRoles search for operator in 2D array (d) and return assigned roles for that day
 // d [][]interface{}: Shift day matrix
-// n string: Operator name to search for
+// labels ...string: Names operator may be written with on spreadsheet
 // Return string: Pipe separated values representing operator's assigned roles
-func (s Service) GetOperatorRoles(d [][]interface{}, n string) (string, error) {
-	cellRange, err := s.GetCellRange(d, n)
+func (s Service) GetOperatorRoles(d [][]interface{}, labels ...string) (string, error) {
+	cellRange, err := s.GetCellRange(d, labels...)
+	if err != nil {
+		return "", err
+	}
+
+	// Roles mirror sheet has the same layout of day blocks: roles are at the same coordinates relative to A1,
+	// the layout range only bound what's read
+	rolesRange, err := ParseRange(ActiveLayout().Roles)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("malformed roles range: %v", err))
+	}
+	cell, err := ParseCell(cellRange)
 	if err != nil {
 		return "", err
 	}
 
-	// Fetch roles string from gsheet and return if found
-	query := fmt.Sprintf("%s%s", strings.SplitAfter(os.Getenv("ROLES_RANGE"), "!")[0], cellRange)
-	res, err := s.ReadCell(query)
+	// Fetch whole roles sheet (cached) and return roles string if found
+	roles, err := s.readCached(rolesRange.String())
 	if err != nil {
 		return "", err
 	}
+	// Values are returned from range start on
+	row, col := cell.Row-rolesRange.Start.Row, cell.Col-rolesRange.Start.Col
+	var res string
+	if row >= 0 && col >= 0 && row < len(roles) && col < len(roles[row]) {
+		res = fmt.Sprint(roles[row][col])
+	}
+	if res == "" {
+		return "", errors.New("no cell found")
+	}
 
 	return res, nil
 }
 
-// GetCellRange search for name (n) in 2D array (d) and return a string representing gsheets cell coordinate
+// GetCellRange search for operator in 2D array (d) and return a string representing gsheets cell coordinate
 // Cell coordinate are supposed starting from A1 cell, so do the necessary math if offset
-func (s Service) GetCellRange(d [][]interface{}, n string) (string, error) {
-	// Convert operator name to lowercase for comparison
-	nLowcase := strings.ToLower(n)
-	// GSheet coordinate of assigned day roles
-	var rolesCell string
+//
+// labels ...string: Names operator may be written with, see FindOperator
+func (s Service) GetCellRange(d [][]interface{}, labels ...string) (string, error) {
+	cell, _, err := s.FindOperator(d, labels...)
+	return cell, err
+}
+
+// FindOperator search 2D array (d) for the cell holding any of operator (labels), case insensitive
+//
+// Return cell coordinate relative to A1 and the label as found.
+// An operator found in more than one cell is reported as ambiguous, as cells can't be told apart
+func (s Service) FindOperator(d [][]interface{}, labels ...string) (string, string, error) {
+	cells, found := findCells(d, labels...)
+	switch len(cells) {
+	case 0:
+		return "", "", ErrOperatorNotFound
+	case 1:
+		return cells[0], found[0], nil
+	default:
+		return "", "", errors.New(fmt.Sprintf("ambiguous operator %v, found in cells %v", labels, strings.Join(cells, ", ")))
+	}
+}
+
+// findCells return coordinates relative to A1 of every cell of (d) holding any of (labels), with the label as found
+func findCells(d [][]interface{}, labels ...string) ([]string, []string) {
+	// Operator labels, uppercase for comparison
+	wanted := map[string]bool{}
+	for _, label := range labels {
+		if label = strings.ToUpper(strings.TrimSpace(label)); label != "" {
+			wanted[label] = true
+		}
+	}
 
-	// Cycle through day matrix and search for operator name, if found return gsheet coordinate
+	// Cycle through day matrix and search for operator labels, remembering every match
+	var cells, found []string
 	for rowIndex, row := range d {
-		//fmt.Println(rowIndex)
-		//fmt.Println(row)
 		for colIndex, cell := range row {
-			//fmt.Println(rowIndex, colIndex)
-			//fmt.Println(cell)
-			if strings.ToLower(cell.(string)) == nLowcase {
-				//fmt.Printf("----Found match with %s, index: %d:%d----\n", cell, rowIndex, colIndex)
-				sheetRow := strconv.Itoa(rowIndex + 1)
-				sheetCol := string('A' + colIndex)
-				//fmt.Printf("---Sheet range %s:%s---\n", sheetCol, sheetRow)
-				rolesCell = fmt.Sprintf("%s%s", sheetCol, sheetRow)
+			value := strings.TrimSpace(fmt.Sprint(cell))
+			if wanted[strings.ToUpper(value)] {
+				cells = append(cells, Cell{Col: colIndex + 1, Row: rowIndex + 1}.String())
+				found = append(found, value)
 			}
 		}
 	}
-
-	// If no operator found return the error
-	if rolesCell == "" {
-		return "", errors.New("no roles found for passed operator")
-	}
-
-	return rolesCell, nil
+	return cells, found
 }
 
-// GetOperatorPostedShifts retrieve all posted shift based on passed in operator name (n)
+// GetOperatorPostedShifts retrieve all posted shift based on passed in operator names (names)
 //
-// n string: Operator name to retrieve posted shifts
+// names ...string: Names operator posted shifts may be recorded with, case insensitive
 //
 // return []interface{}: 1D array containing all posted shifts date.
-func (s Service) GetOperatorPostedShifts(n string) ([]interface{}, error) {
+func (s Service) GetOperatorPostedShifts(names ...string) ([]interface{}, error) {
 	// Get all posted shift from gsheet
-	query := "Cartellini!B4:C"
+	query := ActiveLayout().PostedShifts
 	res, err := s.ReadRange(query)
 	if err != nil {
 		return nil, err
 	}
 
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[strings.ToUpper(strings.TrimSpace(n))] = true
+	}
+
 	var response []interface{}
 	// Filter data returning only matching operator
 	for _, i := range res {
-		if i[0] == n {
+		if len(i) > 1 && wanted[strings.ToUpper(strings.TrimSpace(fmt.Sprint(i[0])))] {
 			response = append(response, i[1])
 		}
 	}
@@ -244,3 +342,44 @@ func (s Service) GetOperatorPostedShifts(n string) ([]interface{}, error) {
 	}
 	return response, nil
 }
+
+// readCached read range (r) through the roster cache, if any
+func (s Service) readCached(r string) ([][]interface{}, error) {
+	if s.cache == nil || s.fresh {
+		return s.ReadRange(r)
+	}
+	if res, ok := s.cache.get(s.sheetId, r); ok {
+		return res, nil
+	}
+
+	res, err := s.ReadRange(r)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(s.sheetId, r, res)
+	return res, nil
+}
+
+// batchGet read all (ranges) with a single call if backend support it
+func (s Service) batchGet(ranges []string) ([][][]interface{}, error) {
+	if b, ok := s.backend.(BatchGetter); ok {
+		return b.BatchGet(s.Context(), s.sheetId, ranges)
+	}
+
+	var res [][][]interface{}
+	for _, r := range ranges {
+		values, err := s.ReadRange(r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, values)
+	}
+	return res, nil
+}
+
+// invalidate drop cached reads of the tab range (r) belong to
+func (s Service) invalidate(sheetId string, r string) {
+	if s.cache != nil {
+		s.cache.invalidateRange(sheetId, r)
+	}
+}
@@ -0,0 +1,226 @@
+// Package sheetstest provide a fake Google Sheets API server for tests
+//
+// Server speak the subset of Sheets v4 REST API the gsuite package use (values get, batchGet,
+// append, update and batchUpdate) on top of an in memory workbook, so the real Google client
+// can be exercised end to end without network.
+package sheetstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"shift-manager/gsuite"
+	"strings"
+)
+
+// Server is a running fake Sheets API server, workbook can be seeded and inspected while it's running
+type Server struct {
+	*httptest.Server
+	Workbook *gsuite.MemoryBackend
+}
+
+// NewServer start a fake Sheets API server serving (workbook), nil for an empty one
+//
+// Caller should Close the server when done
+func NewServer(workbook *gsuite.MemoryBackend) *Server {
+	if workbook == nil {
+		workbook = gsuite.NewMemoryBackend()
+	}
+	s := &Server{Workbook: workbook}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint return the API base URL, for option.WithEndpoint or SHEETS_ENDPOINT env
+func (s *Server) Endpoint() string {
+	return s.URL + "/"
+}
+
+// Service return a gsuite service reading and writing (sheetId) on this server through the Google client
+func (s *Server) Service(sheetId string) (gsuite.Service, error) {
+	var srv gsuite.Service
+	err := srv.NewWithOptions(sheetId, option.WithEndpoint(s.Endpoint()))
+	return srv, err
+}
+
+// apiError is raised to the client in Google JSON error format
+type apiError struct {
+	code    int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	res, err := s.route(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Printf("Error encoding fake sheets response: %v\n", err)
+	}
+}
+
+// route dispatch request to the matching API method
+//
+// Paths are /v4/spreadsheets/{spreadsheetId}/values/{range}[:append] and
+// /v4/spreadsheets/{spreadsheetId}/values:{batchGet|batchUpdate}, range is path escaped
+func (s *Server) route(r *http.Request) (interface{}, error) {
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/v4/spreadsheets/")
+	if path == r.URL.EscapedPath() {
+		return nil, apiError{http.StatusNotFound, fmt.Sprintf("unknown path %s", r.URL.Path)}
+	}
+	parts := strings.SplitN(path, "/", 2)
+	sheetId, err := url.PathUnescape(parts[0])
+	if err != nil || len(parts) < 2 {
+		return nil, apiError{http.StatusNotFound, fmt.Sprintf("unknown path %s", r.URL.Path)}
+	}
+	method := parts[1]
+
+	switch {
+	case method == "values:batchGet" && r.Method == http.MethodGet:
+		return s.batchGet(r.Context(), sheetId, r.URL.Query()["ranges"])
+	case method == "values:batchUpdate" && r.Method == http.MethodPost:
+		return s.batchUpdate(r, sheetId)
+	case strings.HasPrefix(method, "values/"):
+		escaped := strings.TrimPrefix(method, "values/")
+		appending := strings.HasSuffix(escaped, ":append")
+		rng, err := url.PathUnescape(strings.TrimSuffix(escaped, ":append"))
+		if err != nil {
+			return nil, apiError{http.StatusBadRequest, fmt.Sprintf("malformed range %s", escaped)}
+		}
+
+		switch {
+		case appending && r.Method == http.MethodPost:
+			return s.append(r, sheetId, rng)
+		case !appending && r.Method == http.MethodGet:
+			return s.get(r.Context(), sheetId, rng)
+		case !appending && r.Method == http.MethodPut:
+			return s.update(r, sheetId, rng)
+		}
+	}
+	return nil, apiError{http.StatusNotFound, fmt.Sprintf("unknown method %s %s", r.Method, r.URL.Path)}
+}
+
+func (s *Server) get(ctx context.Context, sheetId string, r string) (*sheets.ValueRange, error) {
+	values, err := s.Workbook.Get(ctx, sheetId, r)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	return &sheets.ValueRange{Range: r, MajorDimension: "ROWS", Values: values}, nil
+}
+
+func (s *Server) batchGet(ctx context.Context, sheetId string, ranges []string) (*sheets.BatchGetValuesResponse, error) {
+	res := &sheets.BatchGetValuesResponse{SpreadsheetId: sheetId}
+	for _, r := range ranges {
+		valueRange, err := s.get(ctx, sheetId, r)
+		if err != nil {
+			return nil, err
+		}
+		res.ValueRanges = append(res.ValueRanges, valueRange)
+	}
+	return res, nil
+}
+
+func (s *Server) append(r *http.Request, sheetId string, rng string) (*sheets.AppendValuesResponse, error) {
+	var body sheets.ValueRange
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, apiError{http.StatusBadRequest, fmt.Sprintf("malformed request body: %v", err)}
+	}
+
+	if _, err := s.Workbook.Append(r.Context(), sheetId, rng, body.Values); err != nil {
+		return nil, badRequest(err)
+	}
+	return &sheets.AppendValuesResponse{
+		SpreadsheetId: sheetId,
+		Updates:       &sheets.UpdateValuesResponse{SpreadsheetId: sheetId, UpdatedRows: int64(len(body.Values))},
+	}, nil
+}
+
+func (s *Server) update(r *http.Request, sheetId string, rng string) (*sheets.UpdateValuesResponse, error) {
+	var body sheets.ValueRange
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, apiError{http.StatusBadRequest, fmt.Sprintf("malformed request body: %v", err)}
+	}
+
+	cells, err := toCells(rng, body.Values)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Workbook.BatchUpdate(r.Context(), sheetId, cells); err != nil {
+		return nil, badRequest(err)
+	}
+	return &sheets.UpdateValuesResponse{SpreadsheetId: sheetId, UpdatedRange: rng, UpdatedCells: int64(len(cells))}, nil
+}
+
+func (s *Server) batchUpdate(r *http.Request, sheetId string) (*sheets.BatchUpdateValuesResponse, error) {
+	var body sheets.BatchUpdateValuesRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, apiError{http.StatusBadRequest, fmt.Sprintf("malformed request body: %v", err)}
+	}
+
+	// Whole batch is applied in a single backend write, like Google does
+	var cells []gsuite.CellToUpdate
+	for _, data := range body.Data {
+		rangeCells, err := toCells(data.Range, data.Values)
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, rangeCells...)
+	}
+	if err := s.Workbook.BatchUpdate(r.Context(), sheetId, cells); err != nil {
+		return nil, badRequest(err)
+	}
+	return &sheets.BatchUpdateValuesResponse{SpreadsheetId: sheetId, TotalUpdatedCells: int64(len(cells))}, nil
+}
+
+// toCells split (values) written from the top left cell of range (r) into single cell updates
+func toCells(r string, values [][]interface{}) ([]gsuite.CellToUpdate, error) {
+	rng, err := gsuite.ParseRange(r)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+
+	var cells []gsuite.CellToUpdate
+	for rowIndex, row := range values {
+		for colIndex, value := range row {
+			cells = append(cells, gsuite.CellToUpdate{Range: rng.CellAt(rowIndex, colIndex).String(), Value: fmt.Sprint(value)})
+		}
+	}
+	return cells, nil
+}
+
+// badRequest wrap a workbook error as the 400 Google answer with
+func badRequest(err error) error {
+	return apiError{http.StatusBadRequest, err.Error()}
+}
+
+// writeError answer with (err) in Google JSON error format, understood by googleapi.CheckResponse
+func writeError(w http.ResponseWriter, err error) {
+	var e apiError
+	if !errors.As(err, &e) {
+		e = apiError{http.StatusInternalServerError, err.Error()}
+	}
+
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    e.code,
+			"message": e.message,
+			"status":  http.StatusText(e.code),
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.code)
+	json.NewEncoder(w).Encode(body)
+}
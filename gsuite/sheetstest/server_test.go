@@ -0,0 +1,283 @@
+package sheetstest
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+	"net/http"
+	"os"
+	"reflect"
+	"shift-manager/gsuite"
+	"testing"
+	"time"
+)
+
+// newRoster start a server holding a roster spreadsheet with week 2 of 2020 populated and some posted shifts
+//
+// Same roster as the gsuite package tests: 3x3 day blocks laid out horizontally with a blank column in between.
+// Caller should Close the server
+func newRoster(t *testing.T) *Server {
+	os.Setenv("WEEKDAY_RANGE", "Config!A1:B7")
+	os.Setenv("ROLES_RANGE", "Ruoli!A1:C3")
+	gsuite.CurrentLayout = nil
+
+	m := gsuite.NewMemoryBackend()
+	fixtures := []struct {
+		r      string
+		values [][]string
+	}{
+		{"Config!A1", [][]string{
+			{"A1", "C3"},
+			{"E1", "G3"},
+			{"I1", "K3"},
+			{"M1", "O3"},
+			{"Q1", "S3"},
+			{"U1", "W3"},
+			{"Y1", "AA3"},
+		}},
+		{"Ruoli!A1", [][]string{
+			{"Sede|Mattino|MSB1|Autista", "Sede|Mattino|MSB1|Soccorritore", "Sede|Mattino|MSB1|Capo"},
+			{"Sede|Pomeriggio|MSB1|Autista", "Sede|Pomeriggio|MSB1|Soccorritore", ""},
+			{"Sede|Notte|MSB2|Autista", "", ""},
+		}},
+		// Monday 2020-01-06
+		{"2!A1", [][]string{
+			{"ROSSI", "BIANCHI", "VERDI"},
+			{"NERI", "GIALLI"},
+			{"BLU"},
+		}},
+		// Tuesday 2020-01-07
+		{"2!E1", [][]string{
+			{"GIALLI", "ROSSI"},
+			{"VERDI"},
+		}},
+		{"Cartellini!A4", [][]string{
+			{"06-01-2020", "ROSSI MARIO", "06-01-2020"},
+			{"07-01-2020", "NERI LUCA", "07-01-2020"},
+			{"08-01-2020", "ROSSI MARIO", "08-01-2020"},
+		}},
+	}
+	for _, f := range fixtures {
+		if err := m.SetRange("roster", f.r, f.values); err != nil {
+			t.Fatalf("error populating test roster: %v", err)
+		}
+	}
+
+	return NewServer(m)
+}
+
+// newService return a service talking to (s) through the Google client
+func newService(t *testing.T, s *Server) gsuite.Service {
+	srv, err := s.Service("roster")
+	if err != nil {
+		t.Fatalf("Service() error = %v", err)
+	}
+	return srv
+}
+
+func TestServer_DayCoordNew(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	// Shared client is created once per process, this is the only test going through env
+	os.Setenv("SHEETS_ENDPOINT", s.Endpoint())
+	os.Setenv("SHIFT_ID", "roster")
+	defer os.Unsetenv("SHEETS_ENDPOINT")
+
+	c := gsuite.DayCoord{}
+	if err := c.New(); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := c.Day(time.Tuesday).String(); got != "E1:G3" {
+		t.Errorf("Day(Tuesday) got = %v, want E1:G3", got)
+	}
+
+	srv := gsuite.Service{}
+	if err := srv.New("roster"); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got, err := srv.ReadDay(c, time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ReadDay() error = %v", err)
+	}
+	want := [][]interface{}{{"GIALLI", "ROSSI"}, {"VERDI"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDay() got = %v, want %v", got, want)
+	}
+}
+
+func TestServer_ReadDayCached(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	srv := newService(t, s).WithCache(gsuite.NewRosterCache(time.Minute))
+
+	c := gsuite.DayCoord{}
+	if err := c.Load(srv); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// First read fetch the whole week with a batchGet call
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	for _, d := range []time.Time{monday, monday.AddDate(0, 0, 1)} {
+		got, err := srv.ReadDay(c, d)
+		if err != nil {
+			t.Fatalf("ReadDay() error = %v", err)
+		}
+		if len(got) == 0 {
+			t.Errorf("ReadDay(%v) got no rows", d)
+		}
+	}
+
+	roles, err := srv.GetOperatorRoles([][]interface{}{{"ROSSI", "BIANCHI"}}, "Bianchi")
+	if err != nil {
+		t.Fatalf("GetOperatorRoles() error = %v", err)
+	}
+	if roles != "Sede|Mattino|MSB1|Soccorritore" {
+		t.Errorf("GetOperatorRoles() got = %v", roles)
+	}
+}
+
+func TestServer_SwitchShifts(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	srv := newService(t, s)
+
+	sc := gsuite.ShiftsToSwitch{}
+	if err := sc.New(srv); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Neri"
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Rossi"
+	sc.SecondDate = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err != nil {
+		t.Fatalf("SwitchShifts() error = %v", err)
+	}
+
+	checks := []struct {
+		r    string
+		want string
+	}{
+		{"2!A2", "ROSSI"}, // Monday, was NERI
+		{"2!F1", "NERI"},  // Tuesday, was ROSSI
+		{"2!A1", "ROSSI"}, // Untouched
+	}
+	for _, c := range checks {
+		got, err := s.Workbook.Get(context.Background(), "roster", c.r)
+		if err != nil {
+			t.Fatalf("Get(%v) error = %v", c.r, err)
+		}
+		if len(got) == 0 || got[0][0] != c.want {
+			t.Errorf("cell %v got = %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestServer_GetOperatorPostedShifts(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	srv := newService(t, s)
+
+	got, err := srv.GetOperatorPostedShifts("ROSSI MARIO")
+	if err != nil {
+		t.Fatalf("GetOperatorPostedShifts() error = %v", err)
+	}
+	want := []interface{}{"06-01-2020", "08-01-2020"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetOperatorPostedShifts() got = %v, want %v", got, want)
+	}
+
+	if _, err = srv.GetOperatorPostedShifts("BLU"); err == nil {
+		t.Errorf("GetOperatorPostedShifts() error = nil, want no shift found")
+	}
+}
+
+func TestServer_Append(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	srv := newService(t, s)
+
+	status, err := srv.Append("Cartellini!A4", [][]interface{}{{"09-01-2020", "VERDI ANNA", "09-01-2020"}})
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("Append() status = %v, want %v", status, http.StatusOK)
+	}
+
+	got, err := s.Workbook.Get(context.Background(), "roster", "Cartellini!A7:C7")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := [][]interface{}{{"09-01-2020", "VERDI ANNA", "09-01-2020"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appended row got = %v, want %v", got, want)
+	}
+}
+
+func TestServer_Update(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	client, err := sheets.NewService(context.Background(), option.WithEndpoint(s.Endpoint()), option.WithHTTPClient(s.Client()))
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	values := &sheets.ValueRange{Values: [][]interface{}{{"A", "B"}, {"C"}}}
+	res, err := client.Spreadsheets.Values.Update("roster", "'2'!E2", values).ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if res.UpdatedCells != 3 {
+		t.Errorf("Update() updated %d cells, want 3", res.UpdatedCells)
+	}
+
+	got, err := s.Workbook.Get(context.Background(), "roster", "2!E2:F3")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := [][]interface{}{{"A", "B"}, {"C"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("updated range got = %v, want %v", got, want)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	s := newRoster(t)
+	defer s.Close()
+	srv := newService(t, s)
+
+	tests := []struct {
+		name     string
+		read     func() error
+		wantCode int
+	}{
+		{
+			name:     "Unknown tab",
+			read:     func() error { _, err := srv.ReadRange("53!A1:C3"); return err },
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Unknown spreadsheet",
+			read:     func() error { _, err := srv.WithSheet("missing").ReadRange("2!A1"); return err },
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.read()
+			var apiErr *googleapi.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("read error = %v, want a googleapi.Error", err)
+			}
+			if apiErr.Code != tt.wantCode {
+				t.Errorf("read error code = %v, want %v", apiErr.Code, tt.wantCode)
+			}
+			if gsuite.IsQuotaError(err) {
+				t.Errorf("IsQuotaError() = true, want false")
+			}
+		})
+	}
+}
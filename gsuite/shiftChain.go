@@ -0,0 +1,103 @@
+package gsuite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChainLeg is an operator shift taking part in a shift chain
+type ChainLeg struct {
+	Name    string    `json:"name"` //Operator name (as on gsheet)
+	Aliases []string  `json:"-"`    //Other names operator may be written with
+	Date    time.Time `json:"date"` //Date of the shift operator give away
+	coord   string    //Operator coordinates in gsheet !A1 format
+	found   string    //Operator name as found in gsheet, may be an alias
+}
+
+// ShiftChain rotate shifts among more than two operators, the multi party version of ShiftsToSwitch
+//
+// Every leg operator take the shift of the next leg, the last one take the shift of the first
+type ShiftChain struct {
+	service  Service    //gsheet service
+	dayCoord DayCoord   //gsheet day coordinates for lookups
+	Legs     []ChainLeg `json:"legs"`
+}
+
+// New - instantiate new shift chain, reading day coordinates through (service)
+func (s *ShiftChain) New(service Service) error {
+	s.service = service
+	err := s.dayCoord.Load(service)
+	if err != nil {
+		return fmt.Errorf("error retrieving day coordinates: %w", err)
+	}
+	return nil
+}
+
+// getCoordinates search every leg operator on its day and populate leg coordinates
+//
+// All legs must be on the same spreadsheet, so the rotation can be written with a single atomic batch
+func (s *ShiftChain) getCoordinates() error {
+	if len(s.Legs) < 2 {
+		return errors.New("a shift chain need at least two legs")
+	}
+
+	sheetId := s.dayCoord.Spreadsheet(s.Legs[0].Date)
+	for i := range s.Legs {
+		leg := &s.Legs[i]
+		if leg.Name == "" || leg.Date.IsZero() {
+			return errors.New(fmt.Sprintf("Not all required fields supplied: leg %d: %v %v", i, leg.Name, leg.Date))
+		}
+		if s.dayCoord.Spreadsheet(leg.Date) != sheetId {
+			return errors.New(fmt.Sprintf("leg %d is on a different spreadsheet, chains can't span roster years", i))
+		}
+
+		day, err := s.service.ReadDay(s.dayCoord, leg.Date)
+		if err != nil {
+			return fmt.Errorf("cannot retrieve leg %d workday: %w", i, err)
+		}
+		leg.coord, leg.found, err = s.service.FindOperator(day, append([]string{leg.Name}, leg.Aliases...)...)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error retrieveing leg %d operator coordinates: %v\n", i, err))
+		}
+		leg.coord = offsetCoordinates(s.dayCoord, leg.Date, leg.coord)
+	}
+	return nil
+}
+
+// Validate check every leg operator is on shift on its date, without writing anything
+func (s *ShiftChain) Validate() error {
+	return s.getCoordinates()
+}
+
+// Rotate - actually rotate shifts, writing every leg cell in a single batch update
+func (s *ShiftChain) Rotate() error {
+	err := s.getCoordinates()
+	if err != nil {
+		return fmt.Errorf("error getting coordinates: %w", err)
+	}
+
+	// Make sure nobody edited the cells since they were read, drop stale cached days if so
+	for _, leg := range s.Legs {
+		err = s.service.verifyCell(s.dayCoord, leg.coord, leg.found, leg.Date)
+		if err != nil {
+			for _, l := range s.Legs {
+				s.service.invalidate(s.dayCoord.Spreadsheet(l.Date), l.coord)
+			}
+			return err
+		}
+	}
+
+	// Leg i cell goes to the previous leg operator
+	var data []CellToUpdate
+	for i, leg := range s.Legs {
+		previous := s.Legs[(i+len(s.Legs)-1)%len(s.Legs)]
+		data = append(data, CellToUpdate{Range: leg.coord, Value: previous.Name, SheetId: s.dayCoord.Spreadsheet(leg.Date)})
+	}
+
+	err = s.service.BatchUpdateCells(data)
+	if err != nil {
+		return fmt.Errorf("error rotating shifts: %w", err)
+	}
+	return nil
+}
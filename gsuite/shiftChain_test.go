@@ -0,0 +1,71 @@
+package gsuite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShiftChain_Rotate(t *testing.T) {
+	b := &countingBackend{MemoryBackend: newTestRoster(t)}
+	s := Service{}
+	s.NewWithBackend(b, "roster")
+
+	sc := ShiftChain{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.Legs = []ChainLeg{
+		{Name: "Rossi", Date: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{Name: "Gialli", Date: time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{Name: "Blu", Date: time.Date(2020, 1, 12, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if err := sc.Rotate(); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if b.batchUpdates != 1 {
+		t.Errorf("Rotate() sent %v batch updates, want 1", b.batchUpdates)
+	}
+
+	checks := []struct {
+		r    string
+		want string
+	}{
+		{"2!E1", "ROSSI"},  // Tuesday, was GIALLI
+		{"2!Y1", "GIALLI"}, // Sunday, was BLU
+		{"2!A1", "BLU"},    // Monday, was ROSSI
+		{"2!B2", "GIALLI"}, // Untouched
+	}
+	for _, c := range checks {
+		got, err := s.ReadCell(c.r)
+		if err != nil {
+			t.Fatalf("ReadCell(%v) error = %v", c.r, err)
+		}
+		if got != c.want {
+			t.Errorf("cell %v got = %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestShiftChain_RotateMissingOperator(t *testing.T) {
+	b := &countingBackend{MemoryBackend: newTestRoster(t)}
+	s := Service{}
+	s.NewWithBackend(b, "roster")
+
+	sc := ShiftChain{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.Legs = []ChainLeg{
+		{Name: "Rossi", Date: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{Name: "Gialli", Date: time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{Name: "Verdi", Date: time.Date(2020, 1, 12, 0, 0, 0, 0, time.UTC)}, // Not on shift
+	}
+
+	if err := sc.Rotate(); err == nil {
+		t.Errorf("Rotate() expected error, operator is not on shift")
+	}
+	if b.batchUpdates != 0 {
+		t.Errorf("Rotate() sent %v batch updates, want none", b.batchUpdates)
+	}
+}
@@ -0,0 +1,70 @@
+package gsuite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ShiftCover hand applicant shift to a covering operator free that day, nothing is swapped back
+type ShiftCover struct {
+	service          Service   //gsheet service
+	dayCoord         DayCoord  //gsheet day coordinates for lookups
+	ApplicantName    string    `json:"applicant_name"` //Applicant operator name (as on gsheet)
+	ApplicantAliases []string  `json:"-"`              //Other names applicant may be written with
+	Date             time.Time `json:"date"`           //Date of the shift to cover
+	CoveringName     string    `json:"covering_name"`  //Covering operator name (as on gsheet)
+	CoveringAliases  []string  `json:"-"`              //Other names covering operator may be written with
+}
+
+// New - instantiate new shift cover, reading day coordinates through (service)
+func (s *ShiftCover) New(service Service) error {
+	s.service = service
+	err := s.dayCoord.Load(service)
+	if err != nil {
+		return fmt.Errorf("error retrieving day coordinates: %w", err)
+	}
+	return nil
+}
+
+// Cover - actually write covering operator name in applicant cell
+//
+// Covering operator must not be on shift that day, or it would end up on two cells
+func (s *ShiftCover) Cover() error {
+	if s.ApplicantName == "" || s.Date.IsZero() || s.CoveringName == "" {
+		return errors.New(fmt.Sprintf(
+			"Not all required fields supplied: applicant: %v %v - covering: %v",
+			s.ApplicantName,
+			s.Date,
+			s.CoveringName,
+		))
+	}
+
+	day, err := s.service.ReadDay(s.dayCoord, s.Date)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve applicant workday: %w", err)
+	}
+
+	coord, found, err := s.service.FindOperator(day, append([]string{s.ApplicantName}, s.ApplicantAliases...)...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error retrieveing applicant coordinates: %v\n", err))
+	}
+	if cells, _ := findCells(day, append([]string{s.CoveringName}, s.CoveringAliases...)...); len(cells) > 0 {
+		return errors.New(fmt.Sprintf("covering operator %v is already on shift on %v", s.CoveringName, s.Date.Format("2006-01-02")))
+	}
+	coord = offsetCoordinates(s.dayCoord, s.Date, coord)
+
+	// Make sure nobody edited the cell since it was read, drop stale cached day if so
+	sheetId := s.dayCoord.Spreadsheet(s.Date)
+	err = s.service.verifyCell(s.dayCoord, coord, found, s.Date)
+	if err != nil {
+		s.service.invalidate(sheetId, coord)
+		return err
+	}
+
+	err = s.service.BatchUpdateCells([]CellToUpdate{{Range: coord, Value: s.CoveringName, SheetId: sheetId}})
+	if err != nil {
+		return fmt.Errorf("error covering shift: %w", err)
+	}
+	return nil
+}
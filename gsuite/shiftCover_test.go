@@ -0,0 +1,44 @@
+package gsuite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShiftCover_Cover(t *testing.T) {
+	monday := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		covering string
+		wantErr  bool
+		want     string // 2!A2 after cover
+	}{
+		{"Operator on shift", "Gialli", true, "NERI"},
+		{"Free operator", "Viola", false, "VIOLA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Service{}
+			s.NewWithBackend(newTestRoster(t), "roster")
+
+			sc := ShiftCover{}
+			if err := sc.New(s); err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			sc.ApplicantName = "Neri"
+			sc.Date = monday
+			sc.CoveringName = tt.covering
+
+			if err := sc.Cover(); (err != nil) != tt.wantErr {
+				t.Fatalf("Cover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := s.ReadCell("2!A2")
+			if err != nil {
+				t.Fatalf("ReadCell() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cell 2!A2 got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,33 +3,48 @@ package gsuite
 import (
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 )
 
 type ShiftsToSwitch struct {
-	service     Service         //gsheet service
-	dayCoord    DayCoord        //gsheet day coordinates for lookups
-	FirstName   string          `json:"first_name"` //1st operator name (as on gsheet)
-	FirstDate   time.Time       `json:"first_date"` //1st operator date to change
-	firstDay    [][]interface{} //Day readed from gsheet to search for coordinates
-	firstCoord  string          //1st operator coordinates in gsheet !A1 format
-	SecondName  string          `json:"second_name"` //2nd operator name (as on gsheet)
-	SecondDate  time.Time       `json:"second_date"` //2nd operator date to change
-	secondDay   [][]interface{} //Day readed from gsheet to search for coordinates
-	secondCoord string          //1st operator coordinates in gsheet !A1 format
+	service       Service         //gsheet service
+	dayCoord      DayCoord        //gsheet day coordinates for lookups
+	FirstName     string          `json:"first_name"` //1st operator name (as on gsheet)
+	FirstAliases  []string        `json:"-"`          //Other names 1st operator may be written with
+	FirstDate     time.Time       `json:"first_date"` //1st operator date to change
+	firstDay      [][]interface{} //Day readed from gsheet to search for coordinates
+	firstCoord    string          //1st operator coordinates in gsheet !A1 format
+	firstFound    string          //1st operator name as found in gsheet, may be an alias
+	SecondName    string          `json:"second_name"` //2nd operator name (as on gsheet)
+	SecondAliases []string        `json:"-"`           //Other names 2nd operator may be written with
+	SecondDate    time.Time       `json:"second_date"` //2nd operator date to change
+	secondDay     [][]interface{} //Day readed from gsheet to search for coordinates
+	secondCoord   string          //1st operator coordinates in gsheet !A1 format
+	secondFound   string          //2nd operator name as found in gsheet, may be an alias
+}
+
+// ConflictError is returned when a roster cell doesn't hold the expected operator anymore at write time,
+// usually because someone edited the sheet after the shift was read
+type ConflictError struct {
+	Range    string // Cell coordinates in !A1 format
+	Expected string // Operator name read before the switch
+	Found    string // Operator name found at write time
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("cell %s changed since it was read: expected %q, found %q", e.Range, e.Expected, e.Found)
 }
 
 // New - instantiate new shifts to switch
 //
 // Placeholder for future initiator logic, actually only ser struct service field and initialize dayCoord
+// reading coordinates through the same service
 func (s *ShiftsToSwitch) New(service Service) error {
 	s.service = service
-	err := s.dayCoord.New()
+	err := s.dayCoord.Load(service)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error retrieving day coordinates: %v\n", err))
+		return fmt.Errorf("error retrieving day coordinates: %w", err)
 	}
 	return nil
 }
@@ -54,13 +69,13 @@ func (s *ShiftsToSwitch) getDays() error {
 	// Get 1st operator workday from gsheet
 	s.firstDay, err = s.service.ReadDay(s.dayCoord, s.FirstDate)
 	if err != nil {
-		return errors.New(fmt.Sprintf("cannot retrieve 1st operator workday: %v\n", err))
+		return fmt.Errorf("cannot retrieve 1st operator workday: %w", err)
 	}
 
 	// Get 2nd operator workday from gsheet
 	s.secondDay, err = s.service.ReadDay(s.dayCoord, s.SecondDate)
 	if err != nil {
-		return errors.New(fmt.Sprintf("cannot retrieve 2nd operator workday: %v\n", err))
+		return fmt.Errorf("cannot retrieve 2nd operator workday: %w", err)
 	}
 
 	return nil
@@ -68,6 +83,8 @@ func (s *ShiftsToSwitch) getDays() error {
 
 // getCoordinates search for shift coordinate and populate 1st and 2nd operator fields, getting shifts ready to be switched.
 // Method will do necessary matrix math to adapt coordinates based on DayCoord offset.
+//
+// Operators are searched by name and aliases, switched cells are then written with names so aliases get replaced
 func (s *ShiftsToSwitch) getCoordinates() error {
 	var err error
 
@@ -83,7 +100,7 @@ func (s *ShiftsToSwitch) getCoordinates() error {
 	}
 
 	// Retrieve gsheet coordinates for 1st operator
-	s.firstCoord, err = s.service.GetCellRange(s.firstDay, s.FirstName)
+	s.firstCoord, s.firstFound, err = s.service.FindOperator(s.firstDay, append([]string{s.FirstName}, s.FirstAliases...)...)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error retrieveing 1st operator coordinates: %v\n", err))
 	}
@@ -91,7 +108,7 @@ func (s *ShiftsToSwitch) getCoordinates() error {
 	s.firstCoord = offsetCoordinates(s.dayCoord, s.FirstDate, s.firstCoord)
 
 	// Retrieve gsheet coordinates for 2nd operator
-	s.secondCoord, err = s.service.GetCellRange(s.secondDay, s.SecondName)
+	s.secondCoord, s.secondFound, err = s.service.FindOperator(s.secondDay, append([]string{s.SecondName}, s.SecondAliases...)...)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error retrieveing 2nd operator coordinates: %v\n", err))
 	}
@@ -109,7 +126,7 @@ func (s *ShiftsToSwitch) SwitchShifts() error {
 	// Retrieve days and populate struct
 	err = s.getDays()
 	if err != nil {
-		return errors.New(fmt.Sprintf("error getting working days: %v\n", err))
+		return fmt.Errorf("error getting working days: %w", err)
 	}
 
 	// Retrieve coordinates and populate struct
@@ -118,25 +135,62 @@ func (s *ShiftsToSwitch) SwitchShifts() error {
 		return errors.New(fmt.Sprintf("error getting coordinates: %v\n", err))
 	}
 
+	// Make sure nobody edited the cells since they were read, drop stale cached days if so
+	err = s.verifyCoordinates()
+	if err != nil {
+		s.service.invalidate(s.dayCoord.Spreadsheet(s.FirstDate), s.firstCoord)
+		s.service.invalidate(s.dayCoord.Spreadsheet(s.SecondDate), s.secondCoord)
+		return err
+	}
+
 	// -------------------
 	// Actually switch selected shifts
 	// -------------------
 
 	// data represent the modified cells
 	data := []CellToUpdate{
-		{Range: s.firstCoord, Value: s.SecondName},
-		{Range: s.secondCoord, Value: s.FirstName},
+		{Range: s.firstCoord, Value: s.SecondName, SheetId: s.dayCoord.Spreadsheet(s.FirstDate)},
+		{Range: s.secondCoord, Value: s.FirstName, SheetId: s.dayCoord.Spreadsheet(s.SecondDate)},
 	}
 
 	// Call method to actually update gsheet
 	err = s.service.BatchUpdateCells(data)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error swirching shifts: %v\n", err))
+		return fmt.Errorf("error swirching shifts: %w", err)
 	}
 
 	return nil
 }
 
+// verifyCoordinates re-read 1st and 2nd operator cells and check they still hold the operators names
+//
+// Return a *ConflictError on mismatch
+func (s *ShiftsToSwitch) verifyCoordinates() error {
+	if err := s.service.verifyCell(s.dayCoord, s.firstCoord, s.firstFound, s.FirstDate); err != nil {
+		return err
+	}
+	return s.service.verifyCell(s.dayCoord, s.secondCoord, s.secondFound, s.SecondDate)
+}
+
+// verifyCell re-read roster cell (coord) of day (date) and check it still hold operator (name)
+//
+// Return a *ConflictError on mismatch
+func (s Service) verifyCell(c DayCoord, coord string, name string, date time.Time) error {
+	res, err := s.ForDay(c, date).ReadRange(coord)
+	if err != nil {
+		return fmt.Errorf("error verifying cell %s: %w", coord, err)
+	}
+
+	var found string
+	if len(res) > 0 && len(res[0]) > 0 {
+		found = fmt.Sprint(res[0][0])
+	}
+	if !strings.EqualFold(strings.TrimSpace(found), name) {
+		return &ConflictError{Range: coord, Expected: name, Found: found}
+	}
+	return nil
+}
+
 // offsetCoordinates offset dayCoord with passed coordinate
 //
 // c DayCoord: Day coordinates to offset
@@ -145,40 +199,15 @@ func (s *ShiftsToSwitch) SwitchShifts() error {
 //
 // s string: Coordinates to offset
 func offsetCoordinates(c DayCoord, d time.Time, s string) string {
-	// Etract week from passed time (will be used to compose ghseet cell coordiantes)
-	_, week := d.ISOWeek()
-	// Extract day number from passed time
-	day := d.Weekday()
-
-	var startIndex string
-	// Switch week day and set start index (A9:F12 -> A9)
-	switch day {
-	case 0:
-		startIndex = strings.Split(c.sunday, ":")[0]
-	case 1:
-		startIndex = strings.Split(c.monday, ":")[0]
-	case 2:
-		startIndex = strings.Split(c.tuesday, ":")[0]
-	case 3:
-		startIndex = strings.Split(c.wednesday, ":")[0]
-	case 4:
-		startIndex = strings.Split(c.thursday, ":")[0]
-	case 5:
-		startIndex = strings.Split(c.friday, ":")[0]
-	case 6:
-		startIndex = strings.Split(c.saturday, ":")[0]
-	}
-
-	// Extract row and column index
-	rowReg := regexp.MustCompile("[0-9]+")
-	colReg := regexp.MustCompile("[A-Z]+")
-	row, _ := strconv.Atoi(rowReg.FindString(startIndex))
-	offsetRow, _ := strconv.Atoi(rowReg.FindString(s))
-	col := colReg.FindString(startIndex)
-	offsetCol := colReg.FindString(s)
-
-	resRow := row + offsetRow - 1
-	resCol := string([]rune(col)[0] + []rune(offsetCol)[0] - 'A')
-
-	return fmt.Sprintf("%v!%v%v", week, resCol, resRow)
+	// Day block of passed time on its weekly tab, (A9:F12 -> A9 is the start)
+	block := c.Day(d.Weekday())
+	block.Sheet = c.Tab(d)
+
+	// Offset is relative to block start, A1 mean no offset
+	offset, err := ParseCell(s)
+	if err != nil {
+		return ""
+	}
+
+	return block.CellAt(offset.Row-1, offset.Col-1).String()
 }
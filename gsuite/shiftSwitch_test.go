@@ -0,0 +1,237 @@
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShiftsToSwitch_SwitchShifts(t *testing.T) {
+	m := newTestRoster(t)
+	s := Service{}
+	s.NewWithBackend(m, "roster")
+
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Neri"
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Rossi"
+	sc.SecondDate = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err != nil {
+		t.Fatalf("SwitchShifts() error = %v", err)
+	}
+
+	checks := []struct {
+		r    string
+		want string
+	}{
+		{"2!A2", "ROSSI"}, // Monday, was NERI
+		{"2!F1", "NERI"},  // Tuesday, was ROSSI
+		{"2!A1", "ROSSI"}, // Untouched
+	}
+	for _, c := range checks {
+		got, err := s.ReadCell(c.r)
+		if err != nil {
+			t.Fatalf("ReadCell(%v) error = %v", c.r, err)
+		}
+		if got != c.want {
+			t.Errorf("cell %v got = %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestShiftsToSwitch_SwitchShiftsBeyondZ(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Rossi"
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Bianchi"
+	sc.SecondDate = time.Date(2020, 1, 12, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err != nil {
+		t.Fatalf("SwitchShifts() error = %v", err)
+	}
+
+	checks := []struct {
+		r    string
+		want string
+	}{
+		{"2!A1", "BIANCHI"}, // Monday, was ROSSI
+		{"2!AA1", "ROSSI"},  // Sunday, was BIANCHI
+		{"2!Z1", "NERI"},    // Untouched
+	}
+	for _, c := range checks {
+		got, err := s.ReadCell(c.r)
+		if err != nil {
+			t.Fatalf("ReadCell(%v) error = %v", c.r, err)
+		}
+		if got != c.want {
+			t.Errorf("cell %v got = %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestShiftsToSwitch_SwitchShiftsAliases(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// Operators written with older labels on the roster
+	sc.FirstName = "Neri Luca"
+	sc.FirstAliases = []string{"Neri"}
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Rossi Mario"
+	sc.SecondAliases = []string{"Rossi"}
+	sc.SecondDate = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err != nil {
+		t.Fatalf("SwitchShifts() error = %v", err)
+	}
+
+	// Switched cells are written with main labels
+	checks := []struct {
+		r    string
+		want string
+	}{
+		{"2!A2", "ROSSI MARIO"}, // Monday, was NERI
+		{"2!F1", "NERI LUCA"},   // Tuesday, was ROSSI
+	}
+	for _, c := range checks {
+		got, err := s.ReadCell(c.r)
+		if err != nil {
+			t.Fatalf("ReadCell(%v) error = %v", c.r, err)
+		}
+		if got != c.want {
+			t.Errorf("cell %v got = %v, want %v", c.r, got, c.want)
+		}
+	}
+}
+
+func TestShiftsToSwitch_SwitchShiftsAcrossNewYear(t *testing.T) {
+	m := NewMemoryBackend()
+	CurrentLayout = testLayout()
+	CurrentLayout.WeekTab = "{week}"
+	CurrentLayout.Years = map[int]YearLayout{
+		2020: {SpreadsheetId: "roster-2020"},
+		2021: {SpreadsheetId: "roster-2021"},
+	}
+	defer func() { CurrentLayout = nil }()
+
+	// Friday 2021-01-01 is in ISO week 53 of 2020, Monday 2021-01-04 in week 1 of 2021
+	if err := m.SetRange("roster-2020", "53!Q1", [][]string{{"ROSSI", "BIANCHI"}}); err != nil {
+		t.Fatalf("SetRange() error = %v", err)
+	}
+	if err := m.SetRange("roster-2021", "1!A1", [][]string{{"VERDI", "NERI"}}); err != nil {
+		t.Fatalf("SetRange() error = %v", err)
+	}
+
+	s := Service{}
+	s.NewWithBackend(m, "roster")
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Bianchi"
+	sc.FirstDate = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Verdi"
+	sc.SecondDate = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err != nil {
+		t.Fatalf("SwitchShifts() error = %v", err)
+	}
+
+	checks := []struct {
+		sheetId string
+		r       string
+		want    string
+	}{
+		{"roster-2020", "53!R1", "VERDI"},
+		{"roster-2021", "1!A1", "BIANCHI"},
+		{"roster-2021", "1!B1", "NERI"},
+	}
+	for _, c := range checks {
+		got, err := s.WithSheet(c.sheetId).ReadCell(c.r)
+		if err != nil {
+			t.Fatalf("ReadCell(%v) error = %v", c.r, err)
+		}
+		if got != c.want {
+			t.Errorf("cell %v %v got = %v, want %v", c.sheetId, c.r, got, c.want)
+		}
+	}
+}
+
+func TestShiftsToSwitch_SwitchShiftsMissingOperator(t *testing.T) {
+	s := Service{}
+	s.NewWithBackend(newTestRoster(t), "roster")
+
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Neri"
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Blu"
+	sc.SecondDate = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	if err := sc.SwitchShifts(); err == nil {
+		t.Errorf("SwitchShifts() expected error, operator is not on shift")
+	}
+}
+
+// editingBackend simulate a concurrent sheet edit, writing (value) to (cell) right after (trigger) range is read
+type editingBackend struct {
+	*MemoryBackend
+	trigger string
+	cell    string
+	value   string
+}
+
+func (b *editingBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	res, err := b.MemoryBackend.Get(ctx, sheetId, r)
+	if r == b.trigger {
+		b.trigger = ""
+		b.MemoryBackend.SetRange(sheetId, b.cell, [][]string{{b.value}})
+	}
+	return res, err
+}
+
+func TestShiftsToSwitch_SwitchShiftsConcurrentEdit(t *testing.T) {
+	m := newTestRoster(t)
+	s := Service{}
+	s.NewWithBackend(&editingBackend{MemoryBackend: m, trigger: "2!E1:G3", cell: "2!F1", value: "VERDI"}, "roster")
+
+	sc := ShiftsToSwitch{}
+	if err := sc.New(s); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sc.FirstName = "Neri"
+	sc.FirstDate = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sc.SecondName = "Rossi"
+	sc.SecondDate = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	err := sc.SwitchShifts()
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("SwitchShifts() error = %v, want *ConflictError", err)
+	}
+	if conflict.Range != "2!F1" || conflict.Found != "VERDI" {
+		t.Errorf("SwitchShifts() conflict = %+v, want 2!F1 holding VERDI", conflict)
+	}
+
+	// Nothing must have been written
+	if got, _ := m.Get(context.Background(), "roster", "2!A2"); got[0][0] != "NERI" {
+		t.Errorf("cell 2!A2 got = %v, want NERI", got[0][0])
+	}
+}
@@ -0,0 +1,286 @@
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default Sheets quota handling, Google allow 60 requests per minute per user
+const (
+	defaultQuotaPerMinute = 60
+	defaultMaxRetries     = 5
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 32 * time.Second
+)
+
+// QuotaTransport is an http.RoundTripper keeping Sheets API calls within quota
+//
+// Requests wait for a token of a per minute budget before being sent, 429 and 5xx responses
+// are retried with jittered exponential backoff, honouring Retry-After when Google send it. Appends are
+// retried on 429 only, see retryableRequest.
+// Requests whose body can't be replayed are never retried, waits end early when request context is cancelled.
+type QuotaTransport struct {
+	Base       http.RoundTripper
+	PerMinute  int           // Requests budget, 0 for unlimited
+	MaxRetries int           // Retries after the first attempt
+	BaseDelay  time.Duration // Delay before first retry, doubled every attempt
+	MaxDelay   time.Duration // Delay cap
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// NewQuotaTransport wrap (base) with default quota handling
+//
+// SHEETS_QUOTA_PER_MINUTE override the requests budget
+func NewQuotaTransport(base http.RoundTripper) *QuotaTransport {
+	perMinute := defaultQuotaPerMinute
+	if env := os.Getenv("SHEETS_QUOTA_PER_MINUTE"); env != "" {
+		if parsed, err := strconv.Atoi(env); err == nil && parsed >= 0 {
+			perMinute = parsed
+		} else {
+			fmt.Printf("Malformed SHEETS_QUOTA_PER_MINUTE %v, using %d\n", env, defaultQuotaPerMinute)
+		}
+	}
+
+	return &QuotaTransport{
+		Base:       base,
+		PerMinute:  perMinute,
+		MaxRetries: defaultMaxRetries,
+		BaseDelay:  defaultRetryBaseDelay,
+		MaxDelay:   defaultRetryMaxDelay,
+	}
+}
+
+func (t *QuotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		// Every attempt need a fresh body, caller's request is never modified
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.Body != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		res, err := base.RoundTrip(attemptReq)
+		if err != nil || !retryableRequest(req, res.StatusCode) || attempt >= t.MaxRetries {
+			return res, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return res, nil
+		}
+
+		delay := t.retryDelay(attempt, res)
+		res.Body.Close()
+		fmt.Printf("Sheets API returned %d, retrying in %v\n", res.StatusCode, delay)
+		if err = t.pause(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait block until a request token is available or (ctx) is cancelled
+func (t *QuotaTransport) wait(ctx context.Context) error {
+	if t.PerMinute <= 0 {
+		return nil
+	}
+	rate := float64(t.PerMinute) / float64(time.Minute)
+
+	t.mu.Lock()
+	now := t.clock()
+	if t.last.IsZero() {
+		// Start with a full budget
+		t.tokens = float64(t.PerMinute)
+	} else {
+		t.tokens += float64(now.Sub(t.last)) * rate
+		if t.tokens > float64(t.PerMinute) {
+			t.tokens = float64(t.PerMinute)
+		}
+	}
+	t.last = now
+
+	// Take the token now, callers queue up behind each other
+	t.tokens--
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / rate)
+	}
+	t.mu.Unlock()
+
+	if delay > 0 {
+		return t.pause(ctx, delay)
+	}
+	return nil
+}
+
+// retryDelay return how long to wait before retry (attempt), zero based
+func (t *QuotaTransport) retryDelay(attempt int, res *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	delay := t.BaseDelay << uint(attempt)
+	if delay > t.MaxDelay || delay <= 0 {
+		delay = t.MaxDelay
+	}
+	// Wait between half and full delay so concurrent callers don't retry in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (t *QuotaTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// pause wait (d) or until (ctx) is cancelled
+func (t *QuotaTransport) pause(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		t.sleep(d)
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryable tell if a response status is worth retrying
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryableRequest tell if (req) is worth retrying after a response with (status)
+//
+// Appends are only retried when rate limited: after a server error the row may have been written anyway,
+// and appending it again would duplicate it
+func retryableRequest(req *http.Request, status int) bool {
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, ":append") {
+		return status == http.StatusTooManyRequests
+	}
+	return retryable(status)
+}
+
+// IsQuotaError tell if (err) is a Sheets API error caused by quota or a temporary Google failure
+//
+// Callers should report it as temporary (503) instead of a bad request
+func IsQuotaError(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && retryable(apiErr.Code)
+}
+
+// coalescingBackend share a single in flight read among concurrent identical reads
+type coalescingBackend struct {
+	Backend
+	mu       sync.Mutex
+	inflight map[string]*inflightRead
+}
+
+// inflightRead is a read being executed, waiters block on done
+type inflightRead struct {
+	done   chan struct{}
+	values [][][]interface{}
+	err    error
+}
+
+// newCoalescingBackend wrap (b) coalescing concurrent Get and BatchGet calls
+func newCoalescingBackend(b Backend) *coalescingBackend {
+	return &coalescingBackend{Backend: b, inflight: map[string]*inflightRead{}}
+}
+
+func (b *coalescingBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	values, err := b.do(ctx, sheetId+"\x00"+r, func() ([][][]interface{}, error) {
+		res, err := b.Backend.Get(ctx, sheetId, r)
+		return [][][]interface{}{res}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func (b *coalescingBackend) BatchGet(ctx context.Context, sheetId string, ranges []string) ([][][]interface{}, error) {
+	key := sheetId
+	for _, r := range ranges {
+		key += "\x00" + r
+	}
+
+	return b.do(ctx, key, func() ([][][]interface{}, error) {
+		if batch, ok := b.Backend.(BatchGetter); ok {
+			return batch.BatchGet(ctx, sheetId, ranges)
+		}
+		var res [][][]interface{}
+		for _, r := range ranges {
+			values, err := b.Backend.Get(ctx, sheetId, r)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, values)
+		}
+		return res, nil
+	})
+}
+
+// do run (read) unless an identical read (key) is already in flight, in that case wait for its result
+//
+// The shared read run under the context of who started it: if that one is cancelled waiters
+// still alive retry on their own instead of failing with someone else's cancellation
+func (b *coalescingBackend) do(ctx context.Context, key string, read func() ([][][]interface{}, error)) ([][][]interface{}, error) {
+	b.mu.Lock()
+	if call, ok := b.inflight[key]; ok {
+		b.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil && ctx.Err() == nil &&
+			(errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			return b.do(ctx, key, read)
+		}
+		return call.values, call.err
+	}
+	call := &inflightRead{done: make(chan struct{})}
+	b.inflight[key] = call
+	b.mu.Unlock()
+
+	call.values, call.err = read()
+
+	b.mu.Lock()
+	delete(b.inflight, key)
+	b.mu.Unlock()
+	close(call.done)
+	return call.values, call.err
+}
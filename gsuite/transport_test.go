@@ -0,0 +1,255 @@
+package gsuite
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuotaTransport_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		statuses  []int // Status returned by successive calls, last one repeated
+		wantCode  int
+		wantCalls int
+	}{
+		{
+			name:      "Rate limited then ok",
+			statuses:  []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			wantCode:  http.StatusOK,
+			wantCalls: 3,
+		},
+		{
+			name:      "Server error then ok",
+			statuses:  []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+		},
+		{
+			name:      "Bad request not retried",
+			statuses:  []int{http.StatusBadRequest},
+			wantCode:  http.StatusBadRequest,
+			wantCalls: 1,
+		},
+		{
+			name:      "Retries exhausted",
+			statuses:  []int{http.StatusTooManyRequests},
+			wantCode:  http.StatusTooManyRequests,
+			wantCalls: 4,
+		},
+		{
+			name:      "Append rate limited then ok",
+			path:      "/v4/spreadsheets/roster/values/Ferie!A4:append",
+			statuses:  []int{http.StatusTooManyRequests, http.StatusOK},
+			wantCode:  http.StatusOK,
+			wantCalls: 2,
+		},
+		{
+			name:      "Append server error not retried",
+			path:      "/v4/spreadsheets/roster/values/Ferie!A4:append",
+			statuses:  []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantCode:  http.StatusServiceUnavailable,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls  int32
+				bodies []string
+				mu     sync.Mutex
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := int(atomic.AddInt32(&calls, 1)) - 1
+				body, _ := ioutil.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+				if call >= len(tt.statuses) {
+					call = len(tt.statuses) - 1
+				}
+				w.WriteHeader(tt.statuses[call])
+			}))
+			defer server.Close()
+
+			var slept []time.Duration
+			transport := &QuotaTransport{
+				MaxRetries: 3,
+				BaseDelay:  time.Second,
+				MaxDelay:   4 * time.Second,
+				sleep:      func(d time.Duration) { slept = append(slept, d) },
+			}
+			client := http.Client{Transport: transport}
+
+			res, err := client.Post(server.URL+tt.path, "application/json", strings.NewReader(`{"values":[["ROSSI"]]}`))
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantCode {
+				t.Errorf("status = %v, want %v", res.StatusCode, tt.wantCode)
+			}
+			if int(calls) != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			for _, body := range bodies {
+				if body != `{"values":[["ROSSI"]]}` {
+					t.Errorf("retried request body = %q", body)
+				}
+			}
+			for attempt, d := range slept {
+				max := time.Second << uint(attempt)
+				if max > 4*time.Second {
+					max = 4 * time.Second
+				}
+				if d < max/2 || d > max {
+					t.Errorf("retry %d delay = %v, want between %v and %v", attempt, d, max/2, max)
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaTransport_RetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var slept []time.Duration
+	transport := &QuotaTransport{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: time.Minute,
+		sleep: func(d time.Duration) { slept = append(slept, d) }}
+	client := http.Client{Transport: transport}
+
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	res.Body.Close()
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Errorf("slept = %v, want [7s]", slept)
+	}
+}
+
+func TestQuotaTransport_Budget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	now := time.Date(2020, 1, 6, 8, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	transport := &QuotaTransport{
+		PerMinute: 60,
+		now:       func() time.Time { return now },
+		sleep:     func(d time.Duration) { slept += d; now = now.Add(d) },
+	}
+	client := http.Client{Transport: transport}
+
+	// Full budget is spent without waiting, then one request per second
+	for i := 0; i < 62; i++ {
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		res.Body.Close()
+	}
+	if slept != 2*time.Second {
+		t.Errorf("waited %v over budget, want 2s", slept)
+	}
+}
+
+// blockingBackend hold every Get until release is closed
+type blockingBackend struct {
+	*MemoryBackend
+	release chan struct{}
+	gets    int32
+}
+
+func (b *blockingBackend) Get(ctx context.Context, sheetId string, r string) ([][]interface{}, error) {
+	atomic.AddInt32(&b.gets, 1)
+	<-b.release
+	return b.MemoryBackend.Get(ctx, sheetId, r)
+}
+
+func TestCoalescingBackend_Get(t *testing.T) {
+	inner := &blockingBackend{MemoryBackend: newTestRoster(t), release: make(chan struct{})}
+	b := newCoalescingBackend(inner)
+
+	var (
+		wg      sync.WaitGroup
+		results = make([][][]interface{}, 5)
+	)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := b.Get(context.Background(), "roster", "2!A1:C3")
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+			results[i] = res
+		}(i)
+	}
+
+	// Wait for the first read to reach backend, give the others time to queue behind it
+	for atomic.LoadInt32(&inner.gets) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if inner.gets != 1 {
+		t.Errorf("backend reads = %v, want 1", inner.gets)
+	}
+	for _, res := range results {
+		if len(res) != 3 || res[0][0] != "ROSSI" {
+			t.Errorf("Get() got = %v", res)
+		}
+	}
+
+	// Reads after completion hit backend again
+	if _, err := b.Get(context.Background(), "roster", "2!A1:C3"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if inner.gets != 2 {
+		t.Errorf("backend reads = %v, want 2", inner.gets)
+	}
+}
+
+func TestQuotaTransport_Cancelled(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := &QuotaTransport{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: time.Minute,
+		sleep: func(d time.Duration) { cancel() }}
+	client := http.Client{Transport: transport}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req.WithContext(ctx))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %v, want 1", calls)
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultTimeout is used when SMTP_TIMEOUT is not set
+const defaultTimeout = 30 * time.Second
+
+// Config is the SMTP server notifications are sent through
+//
+// Without a username no authentication is attempted, which is what local catchers like MailHog expect
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string        // Sender address
+	Timeout  time.Duration // Bound a whole email delivery, connection included
+}
+
+// ConfigFromEnv read configuration from SMTP_HOST, SMTP_PORT (default 25), SMTP_USERNAME, SMTP_PASSWORD,
+// SMTP_FROM and SMTP_TIMEOUT (Go duration, default 30s) env variables
+//
+// For MailHog set SMTP_HOST=localhost and SMTP_PORT=1025
+func ConfigFromEnv() Config {
+	c := Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if c.Port == "" {
+		c.Port = "25"
+	}
+	if c.From == "" {
+		c.From = "shift-manager@localhost"
+	}
+	c.Timeout = defaultTimeout
+	if env := os.Getenv("SMTP_TIMEOUT"); env != "" {
+		parsed, err := time.ParseDuration(env)
+		if err != nil || parsed <= 0 {
+			fmt.Printf("Malformed SMTP_TIMEOUT %v, using %v: %v\n", env, defaultTimeout, err)
+		} else {
+			c.Timeout = parsed
+		}
+	}
+	return c
+}
+
+// Enabled tell if an SMTP server is configured, notifications are not sent otherwise
+func (c Config) Enabled() bool {
+	return c.Host != ""
+}
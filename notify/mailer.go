@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer deliver a plain text email
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// SMTPMailer deliver emails through the configured SMTP server
+type SMTPMailer struct {
+	Config Config
+}
+
+// Send deliver the email like smtp.SendMail, upgrading to TLS when the server offer it, but bounded by
+// Config.Timeout: an unresponsive server fail the delivery instead of hanging it
+func (m SMTPMailer) Send(to string, subject string, body string) error {
+	address, err := mail.ParseAddress(to)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid recipient address %q: %v", to, err))
+	}
+	if err = m.send(address.Address, message(m.Config.From, address.String(), subject, body)); err != nil {
+		return errors.New(fmt.Sprintf("error sending email to %s: %v", address.Address, err))
+	}
+	return nil
+}
+
+// send deliver (msg) to (to) address over a single connection
+func (m SMTPMailer) send(to string, msg []byte) error {
+	timeout := m.Config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.Config.Host, m.Config.Port), timeout)
+	if err != nil {
+		return err
+	}
+	// Deadline cover every command, DATA included
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: m.Config.Host}); err != nil {
+			return err
+		}
+	}
+	if m.Config.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err = c.Auth(smtp.PlainAuth("", m.Config.Username, m.Config.Password, m.Config.Host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(m.Config.From); err != nil {
+		return err
+	}
+	if err = c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message build the RFC 5322 email, subject is encoded if not plain ASCII so it can't inject headers
+func message(from string, to string, subject string, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(strings.Replace(body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return []byte(b.String())
+}
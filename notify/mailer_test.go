@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpMessage is an email received by fakeSMTP
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP listen on a local port speaking just enough SMTP for net/smtp, like MailHog does.
+// Received emails are sent on the returned channel
+func fakeSMTP(t *testing.T) (string, string, <-chan smtpMessage) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	received := make(chan smtpMessage, 1)
+
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		c := textproto.NewConn(conn)
+		defer c.Close()
+
+		var m smtpMessage
+		c.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				c.PrintfLine("250 fake")
+			case "MAIL":
+				m.from = line[len("MAIL FROM:"):]
+				c.PrintfLine("250 OK")
+			case "RCPT":
+				m.to = append(m.to, line[len("RCPT TO:"):])
+				c.PrintfLine("250 OK")
+			case "DATA":
+				c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				lines, err := c.ReadDotLines()
+				if err != nil {
+					return
+				}
+				m.data = strings.Join(lines, "\n")
+				received <- m
+				c.PrintfLine("250 OK")
+			case "QUIT":
+				c.PrintfLine("221 Bye")
+				return
+			default:
+				c.PrintfLine("250 OK")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	return host, port, received
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	mailer := SMTPMailer{Config: Config{Host: host, Port: port, From: "shift-manager@localhost"}}
+
+	err := mailer.Send("Mario Rossi <rossi@example.com>", "Turno approvato", "Ciao Mario,\nil cambio è stato approvato.\n")
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case m := <-received:
+		if m.from != "<shift-manager@localhost>" {
+			t.Errorf("Send() envelope from = %v", m.from)
+		}
+		if len(m.to) != 1 || m.to[0] != "<rossi@example.com>" {
+			t.Errorf("Send() envelope to = %v", m.to)
+		}
+		for _, want := range []string{
+			"From: shift-manager@localhost",
+			`To: "Mario Rossi" <rossi@example.com>`,
+			"Subject: Turno approvato",
+			"Content-Type: text/plain; charset=utf-8",
+			"il cambio è stato approvato.",
+		} {
+			if !strings.Contains(m.data, want) {
+				t.Errorf("Send() message missing %q:\n%s", want, m.data)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Send() no message received")
+	}
+}
+
+func TestSMTPMailer_SendTimeout(t *testing.T) {
+	// Server accepting connections but never greeting
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(5 * time.Second)
+	}()
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	mailer := SMTPMailer{Config: Config{Host: host, Port: port, From: "shift-manager@localhost", Timeout: 100 * time.Millisecond}}
+	start := time.Now()
+	if err := mailer.Send("rossi@example.com", "Subject", "Body"); err == nil {
+		t.Errorf("Send() expected error, server never answered")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %v, want it bound by timeout", elapsed)
+	}
+}
+
+func TestSMTPMailer_SendInvalidAddress(t *testing.T) {
+	mailer := SMTPMailer{Config: Config{Host: "127.0.0.1", Port: "1"}}
+	if err := mailer.Send("not an address", "Subject", "Body"); err == nil {
+		t.Errorf("Send() expected error for invalid address")
+	}
+}
+
+func TestMessage_SubjectInjection(t *testing.T) {
+	m := string(message("from@localhost", "to@localhost", "Hi\r\nBcc: victim@example.com", "Body"))
+	if strings.Contains(m, "\r\nBcc:") {
+		t.Errorf("message() subject injected a header:\n%s", m)
+	}
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"strings"
+	"time"
+)
+
+// Recipients resolve who notifications are sent to
+type Recipients interface {
+	// Recipient retrieve user (id), with operator name and mail
+	Recipient(id string) (db.User, error)
+	// OptedOut tell if user (id) asked not to receive (event) notifications
+	OptedOut(id string, event string) (bool, error)
+}
+
+// dbRecipients read recipients and their opt-outs from DB
+type dbRecipients struct {
+	service db.Service
+}
+
+func (r dbRecipients) Recipient(id string) (db.User, error) {
+	u := db.User{}
+	u.New(r.service)
+	err := u.GetById(id)
+	return u, err
+}
+
+func (r dbRecipients) OptedOut(id string, event string) (bool, error) {
+	optOut := db.NotificationOptOut{}
+	optOut.New(r.service)
+	return optOut.OptedOut(id, event)
+}
+
+// Message is what notification templates are executed with
+type Message struct {
+	Recipient db.User
+	Data      interface{}
+}
+
+// Change is the data of change request notifications
+type Change struct {
+	Applicant     db.User
+	ApplicantDate time.Time
+	With          db.User
+	WithDate      time.Time
+}
+
+// Notifier send event notifications by email, a nil Mailer disable them
+type Notifier struct {
+	Mailer     Mailer
+	Recipients Recipients
+}
+
+// New return a notifier sending through the SMTP server configured by env variables, see ConfigFromEnv
+func New(s db.Service) Notifier {
+	n := Notifier{Recipients: dbRecipients{service: s}}
+	if config := ConfigFromEnv(); config.Enabled() {
+		n.Mailer = SMTPMailer{Config: config}
+	}
+	return n
+}
+
+// Notify send (event) notification to user (to), executing event template with (data)
+//
+// Users opted out of (event), or without a mail, are skipped
+func (n Notifier) Notify(event string, to string, data interface{}) error {
+	if n.Mailer == nil {
+		return nil
+	}
+	t, ok := templates[event]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown notification event %q", event))
+	}
+
+	optedOut, err := n.Recipients.OptedOut(to, event)
+	if err != nil {
+		return err
+	}
+	if optedOut {
+		return nil
+	}
+	recipient, err := n.Recipients.Recipient(to)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving %s notification recipient %s: %v", event, to, err))
+	}
+	if recipient.Mail == "" {
+		return nil
+	}
+
+	var subject, body bytes.Buffer
+	message := Message{Recipient: recipient, Data: data}
+	if err = t.ExecuteTemplate(&subject, "subject", message); err != nil {
+		return errors.New(fmt.Sprintf("error rendering %s notification: %v", event, err))
+	}
+	if err = t.ExecuteTemplate(&body, "body", message); err != nil {
+		return errors.New(fmt.Sprintf("error rendering %s notification: %v", event, err))
+	}
+	return n.Mailer.Send(recipient.Mail, strings.TrimSpace(subject.String()), body.String())
+}
+
+// NotifyChange send (event) notification about change request (change) to users (to)
+//
+// change must hold applicant and counterpart user UUIDs, as retrieved by GetById
+func (n Notifier) NotifyChange(event string, change db.ShiftChange, to ...string) error {
+	if n.Mailer == nil {
+		return nil
+	}
+
+	data := Change{ApplicantDate: change.ApplicantDate, WithDate: change.WithDate}
+	var err error
+	if data.Applicant, err = n.Recipients.Recipient(change.ApplicantName); err != nil {
+		return errors.New(fmt.Sprintf("error retrieving change request applicant: %v", err))
+	}
+	if data.With, err = n.Recipients.Recipient(change.WithName); err != nil {
+		return errors.New(fmt.Sprintf("error retrieving change request counterpart: %v", err))
+	}
+
+	// A failed recipient doesn't stop the others
+	var failed []string
+	for _, id := range to {
+		if err = n.Notify(event, id, data); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
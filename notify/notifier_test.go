@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"errors"
+	"shift-manager/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRecipients map user UUID -> user, opted out users map to the events they opted out of
+type fakeRecipients struct {
+	users    map[string]db.User
+	optedOut map[string][]string
+}
+
+func (f fakeRecipients) Recipient(id string) (db.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return db.User{}, errors.New("no row where retrieved")
+	}
+	return u, nil
+}
+
+func (f fakeRecipients) OptedOut(id string, event string) (bool, error) {
+	for _, e := range f.optedOut[id] {
+		if e == event {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sent is an email recorded by recordingMailer
+type sent struct {
+	to, subject, body string
+}
+
+type recordingMailer struct {
+	sent *[]sent
+}
+
+func (m recordingMailer) Send(to string, subject string, body string) error {
+	*m.sent = append(*m.sent, sent{to, subject, body})
+	return nil
+}
+
+func testNotifier(emails *[]sent) Notifier {
+	return Notifier{
+		Mailer: recordingMailer{sent: emails},
+		Recipients: fakeRecipients{
+			users: map[string]db.User{
+				"rossi":   {Id: "rossi", Name: "Mario", Surname: "Rossi", Mail: "rossi@example.com"},
+				"bianchi": {Id: "bianchi", Name: "Anna", Surname: "Bianchi", Mail: "bianchi@example.com"},
+				"verdi":   {Id: "verdi", Name: "Luca", Surname: "Verdi"}, // No mail
+			},
+			optedOut: map[string][]string{"bianchi": {EventChangeExpired}},
+		},
+	}
+}
+
+func TestNotifier_NotifyChange(t *testing.T) {
+	change := db.ShiftChange{
+		ApplicantName: "rossi",
+		ApplicantDate: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+		WithName:      "bianchi",
+		WithDate:      time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		event  string
+		to     []string
+		wantTo []string
+		want   string // Expected in every body
+	}{
+		{"Created", EventChangeCreated, []string{"bianchi"}, []string{"bianchi@example.com"}, "Rossi Mario asked to swap their shift on 06-01-2020 with yours on 08-01-2020"},
+		{"Approved", EventChangeApproved, []string{"rossi", "bianchi"}, []string{"rossi@example.com", "bianchi@example.com"}, "has been approved"},
+		{"Opted out", EventChangeExpired, []string{"rossi", "bianchi"}, []string{"rossi@example.com"}, "expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var emails []sent
+			if err := testNotifier(&emails).NotifyChange(tt.event, change, tt.to...); err != nil {
+				t.Fatalf("NotifyChange() error = %v", err)
+			}
+			if len(emails) != len(tt.wantTo) {
+				t.Fatalf("NotifyChange() sent %+v, want to %v", emails, tt.wantTo)
+			}
+			for i, email := range emails {
+				if email.to != tt.wantTo[i] {
+					t.Errorf("NotifyChange() email %d to = %v, want %v", i, email.to, tt.wantTo[i])
+				}
+				if !strings.Contains(email.body, tt.want) {
+					t.Errorf("NotifyChange() email %d body = %q, want it to contain %q", i, email.body, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	from := time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 1, 6, 11, 30, 0, 0, time.UTC)
+	permission := struct{ Date, From, To time.Time }{from, from, to}
+
+	var emails []sent
+	n := testNotifier(&emails)
+	if err := n.Notify(EventPermissionRecorded, "rossi", permission); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(emails) != 1 || emails[0].subject != "Permission request recorded" {
+		t.Fatalf("Notify() sent %+v, want a permission notification", emails)
+	}
+	if want := "on 06-01-2020 from 09:00 to 11:30"; !strings.Contains(emails[0].body, want) {
+		t.Errorf("Notify() body = %q, want it to contain %q", emails[0].body, want)
+	}
+
+	// Operators without mail are skipped
+	if err := n.Notify(EventPermissionRecorded, "verdi", permission); err != nil || len(emails) != 1 {
+		t.Errorf("Notify() error = %v, sent %d emails, want operator without mail skipped", err, len(emails))
+	}
+	if err := n.Notify("unknown", "rossi", nil); err == nil {
+		t.Errorf("Notify() expected error for unknown event")
+	}
+}
+
+func TestNotifier_Disabled(t *testing.T) {
+	n := Notifier{Recipients: fakeRecipients{}}
+	if err := n.Notify(EventIllnessRecorded, "rossi", nil); err != nil {
+		t.Errorf("Notify() error = %v, want notifications disabled without a mailer", err)
+	}
+}
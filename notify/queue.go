@@ -0,0 +1,37 @@
+package notify
+
+import "fmt"
+
+// Queue send notifications in background through a fixed number of workers, so a slow SMTP server can't
+// pile up a goroutine per event. Failures are only logged
+type Queue struct {
+	notifier Notifier
+	jobs     chan func(n Notifier) error
+}
+
+// NewQueue start (workers) workers sending through (n), up to (size) notifications wait for a free worker
+func NewQueue(n Notifier, workers int, size int) *Queue {
+	q := &Queue{notifier: n, jobs: make(chan func(n Notifier) error, size)}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Submit queue (send) without waiting, return false if the queue is full and the notification was dropped
+func (q *Queue) Submit(send func(n Notifier) error) bool {
+	select {
+	case q.jobs <- send:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *Queue) work() {
+	for send := range q.jobs {
+		if err := send(q.notifier); err != nil {
+			fmt.Printf("Error sending notification: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueue_Submit(t *testing.T) {
+	q := NewQueue(Notifier{}, 1, 1)
+	sent := make(chan bool)
+	if !q.Submit(func(n Notifier) error {
+		sent <- true
+		return nil
+	}) {
+		t.Fatalf("Submit() dropped notification on an empty queue")
+	}
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Submit() notification never sent")
+	}
+}
+
+func TestQueue_SubmitFull(t *testing.T) {
+	// No workers, so the first notification fill the queue
+	q := NewQueue(Notifier{}, 0, 1)
+	send := func(n Notifier) error { return nil }
+	if !q.Submit(send) {
+		t.Errorf("Submit() dropped notification on an empty queue")
+	}
+	if q.Submit(send) {
+		t.Errorf("Submit() queued notification on a full queue, want it dropped")
+	}
+}
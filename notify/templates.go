@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"text/template"
+	"time"
+)
+
+// Notification events, operators may opt out of each of them
+const (
+	EventChangeCreated      = "change_created"      // Sent to the counterpart, asked to accept a swap
+	EventChangeApproved     = "change_approved"     // Sent to both operators once the swap is on the roster
+	EventChangeRejected     = "change_rejected"     // Sent to both operators, or the applicant if the counterpart declined
+	EventChangeExpired      = "change_expired"      // Sent to both operators
+	EventLicenseRecorded    = "license_recorded"    // Sent to the submitting operator
+	EventPermissionRecorded = "permission_recorded" // Sent to the submitting operator
+	EventIllnessRecorded    = "illness_recorded"    // Sent to the submitting operator
+)
+
+// Events list every notification event
+var Events = []string{
+	EventChangeCreated,
+	EventChangeApproved,
+	EventChangeRejected,
+	EventChangeExpired,
+	EventLicenseRecorded,
+	EventPermissionRecorded,
+	EventIllnessRecorded,
+}
+
+// IsEvent tell if (event) is a known notification event
+func IsEvent(event string) bool {
+	_, ok := templates[event]
+	return ok
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("02-01-2006") },
+	"hour": func(t time.Time) string { return t.Format("15:04") },
+}
+
+// Every template define a subject and a body, executed with a Message
+var templates = map[string]*template.Template{
+	EventChangeCreated: parse(EventChangeCreated, `
+{{define "subject"}}Shift swap request from {{.Data.Applicant.Surname}} {{.Data.Applicant.Name}}{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+{{.Data.Applicant.Surname}} {{.Data.Applicant.Name}} asked to swap their shift on {{date .Data.ApplicantDate}} with yours on {{date .Data.WithDate}}.
+Accept or decline the request from the incoming changes page, the manager will review it once you accept.
+{{end}}`),
+	EventChangeApproved: parse(EventChangeApproved, `
+{{define "subject"}}Shift swap approved{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+the swap between {{.Data.Applicant.Surname}} {{.Data.Applicant.Name}} on {{date .Data.ApplicantDate}} and {{.Data.With.Surname}} {{.Data.With.Name}} on {{date .Data.WithDate}} has been approved and is now on the roster.
+{{end}}`),
+	EventChangeRejected: parse(EventChangeRejected, `
+{{define "subject"}}Shift swap rejected{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+the swap between {{.Data.Applicant.Surname}} {{.Data.Applicant.Name}} on {{date .Data.ApplicantDate}} and {{.Data.With.Surname}} {{.Data.With.Name}} on {{date .Data.WithDate}} has been rejected, the roster is unchanged.
+{{end}}`),
+	EventChangeExpired: parse(EventChangeExpired, `
+{{define "subject"}}Shift swap request expired{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+the swap between {{.Data.Applicant.Surname}} {{.Data.Applicant.Name}} on {{date .Data.ApplicantDate}} and {{.Data.With.Surname}} {{.Data.With.Name}} on {{date .Data.WithDate}} expired before being applied, the roster is unchanged.
+{{end}}`),
+	EventLicenseRecorded: parse(EventLicenseRecorded, `
+{{define "subject"}}Leave request recorded{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+your leave request from {{date .Data.From}} to {{date .Data.To}} has been recorded.
+{{end}}`),
+	EventPermissionRecorded: parse(EventPermissionRecorded, `
+{{define "subject"}}Permission request recorded{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+your permission request on {{date .Data.Date}} from {{hour .Data.From}} to {{hour .Data.To}} has been recorded.
+{{end}}`),
+	EventIllnessRecorded: parse(EventIllnessRecorded, `
+{{define "subject"}}Illness recorded{{end}}
+{{define "body"}}Hi {{.Recipient.Name}},
+
+your illness from {{date .Data.From}} to {{date .Data.To}} has been recorded{{with .Data.ProtocolNumber}}, certificate protocol number {{.}}{{end}}.
+{{end}}`),
+}
+
+func parse(name string, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(funcs).Parse(text))
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"time"
+)
+
+// Worker deliver outbox entries to Google Sheets, retrying failures with exponential backoff
+//
+// Entries still failing after MaxAttempts are dead lettered, waiting for an admin to replay them
+type Worker struct {
+	service     db.Service
+	Every       time.Duration // Polling interval
+	Batch       int           // Max entries delivered per poll
+	MaxAttempts int           // Attempts before dead lettering
+	BaseDelay   time.Duration // Delay after first failure, doubled at every attempt
+	MaxDelay    time.Duration // Upper bound for delay between attempts
+	Timeout     time.Duration // Deadline of a single delivery
+}
+
+// New create a worker with default settings
+func (w *Worker) New(s db.Service) {
+	w.service = s
+	w.Every = 10 * time.Second
+	w.Batch = 20
+	w.MaxAttempts = 10
+	w.BaseDelay = 30 * time.Second
+	w.MaxDelay = time.Hour
+	w.Timeout = 30 * time.Second
+}
+
+// Start poll outbox every w.Every delivering due entries, never return
+func (w Worker) Start() {
+	ticker := time.NewTicker(w.Every)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := w.DeliverDue(); err != nil {
+			fmt.Printf("Error delivering outbox: %v\n", err)
+		}
+	}
+}
+
+// DeliverDue claim due entries and try to deliver each of them
+func (w Worker) DeliverDue() error {
+	var (
+		entry   db.OutboxEntry
+		entries []db.OutboxEntry
+	)
+
+	// Lease entries long enough to try delivering all of them, every delivery is bounded by w.Timeout
+	// so a stalled one can't outlive the lease and get delivered twice by another worker
+	entry.New(w.service)
+	err := entry.ClaimDue(w.Batch, w.lease(), &entries)
+	if err != nil {
+		return err
+	}
+
+	for index := range entries {
+		e := &entries[index]
+		err = deliver(e, w.Timeout)
+		if err == nil {
+			if err = e.MarkDelivered(); err != nil {
+				fmt.Printf("Outbox entry %s delivered but not marked: %v\n", e.Id, err)
+			}
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		dead := attempts >= w.MaxAttempts
+		next := time.Now().Add(Backoff(attempts, w.BaseDelay, w.MaxDelay))
+		fmt.Printf("Outbox entry %s delivery attempt %d failed: %v\n", e.Id, attempts, err)
+		if err = e.MarkFailed(err, next, dead); err != nil {
+			fmt.Printf("Error updating outbox entry %s: %v\n", e.Id, err)
+		}
+	}
+	return nil
+}
+
+// lease return how long claimed entries are leased: a batch of deliveries, each bounded by w.Timeout,
+// plus a minute to record their outcome
+func (w Worker) lease() time.Duration {
+	return time.Duration(w.Batch)*w.Timeout + time.Minute
+}
+
+// deliver append entry payload to its spreadsheet, giving up after (timeout)
+func deliver(e *db.OutboxEntry, timeout time.Duration) error {
+	sheetService := gsuite.Service{}
+	err := sheetService.New(e.SheetId)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = sheetService.WithContext(ctx).Append(e.Range, e.Payload)
+	return err
+}
+
+// Backoff return delay before next attempt after (attempts) failed ones, doubling (base) up to (max)
+func Backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// Enqueue store (data) to be appended after range (r) of spreadsheet (sheetId)
+func Enqueue(s db.Service, sheetId string, r string, data [][]interface{}) error {
+	entry := db.OutboxEntry{
+		SheetId: sheetId,
+		Range:   r,
+		Payload: data,
+	}
+	entry.New(s)
+	return entry.Enqueue()
+}
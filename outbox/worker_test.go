@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/gsuite/sheetstest"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 30 * time.Second
+	max := time.Hour
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{8, 64 * time.Minute},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if want > max {
+			want = max
+		}
+		if got := Backoff(tt.attempts, base, max); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	m := gsuite.NewMemoryBackend()
+	gsuite.DefaultBackend = m
+	defer func() { gsuite.DefaultBackend = nil }()
+
+	entry := db.OutboxEntry{
+		SheetId: "requests",
+		Range:   "Malattie!A4",
+		Payload: [][]interface{}{{"01-01-2020", "ROSSI MARIO", "02-01-2020", "03-01-2020", "12345A"}},
+	}
+	if err := deliver(&entry, time.Minute); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	got, err := m.Get(context.Background(), "requests", "Malattie!A4:E")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	want := [][]interface{}{{"01-01-2020", "ROSSI MARIO", "02-01-2020", "03-01-2020", "12345A"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deliver() appended %v, want %v", got, want)
+	}
+}
+
+func TestDeliver_GoogleClient(t *testing.T) {
+	server := sheetstest.NewServer(nil)
+	defer server.Close()
+	// Shared client is created once per process, this is the only test going through Google client
+	os.Setenv("SHEETS_ENDPOINT", server.Endpoint())
+	defer os.Unsetenv("SHEETS_ENDPOINT")
+
+	// Requests posted by the four request handlers, delivered to their layout range
+	requests := []string{gsuite.RequestShifts, gsuite.RequestLicense, gsuite.RequestPermissions, gsuite.RequestIllness}
+	for _, kind := range requests {
+		t.Run(kind, func(t *testing.T) {
+			r := gsuite.ActiveLayout().Request(kind)
+			entry := db.OutboxEntry{
+				SheetId: "requests",
+				Range:   r,
+				Payload: [][]interface{}{{"01-01-2020", "ROSSI MARIO", kind}},
+			}
+			if err := deliver(&entry, time.Minute); err != nil {
+				t.Fatalf("deliver() error = %v", err)
+			}
+
+			row, err := gsuite.ParseRange(r)
+			if err != nil {
+				t.Fatalf("ParseRange() error = %v", err)
+			}
+			row.End = row.Start.Offset(0, 2)
+			got, err := server.Workbook.Get(context.Background(), "requests", row.String())
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			want := [][]interface{}{{"01-01-2020", "ROSSI MARIO", kind}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("deliver() appended %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// stalledBackend never complete appends, until the call is cancelled
+type stalledBackend struct {
+	gsuite.Backend
+}
+
+func (stalledBackend) Append(ctx context.Context, sheetId string, r string, data [][]interface{}) (int, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestDeliver_timeout(t *testing.T) {
+	gsuite.DefaultBackend = stalledBackend{gsuite.NewMemoryBackend()}
+	defer func() { gsuite.DefaultBackend = nil }()
+
+	entry := db.OutboxEntry{
+		SheetId: "requests",
+		Range:   "Malattie!A4",
+		Payload: [][]interface{}{{"01-01-2020", "ROSSI MARIO", "02-01-2020", "03-01-2020", "12345A"}},
+	}
+	err := deliver(&entry, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deliver() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWorker_lease(t *testing.T) {
+	var w Worker
+	w.New(db.Service{})
+	// Every delivery of a batch must end before its entries can be claimed again
+	if got := w.lease(); got <= time.Duration(w.Batch)*w.Timeout {
+		t.Errorf("lease() = %v, shorter than %d deliveries of %v", got, w.Batch, w.Timeout)
+	}
+}
@@ -0,0 +1,109 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shift-manager/db"
+)
+
+// ValidateChain check every leg operator is assigned on its date on (source)
+func ValidateChain(source Source, legs []Leg) error {
+	if len(legs) < 2 {
+		return errors.New("a swap chain need at least two legs")
+	}
+	for i, leg := range legs {
+		if _, err := source.Assignment(leg.Operator, leg.Date); err != nil {
+			return fmt.Errorf("leg %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ApplyChain apply an accepted swap chain to the roster, then commit its status to DB, as a saga
+//
+// The whole rotation is applied at once, if the DB update fails it's reverted by the reverse rotation.
+// Like a change request saga, intent is recorded first so a crash halfway is recovered.
+//
+// (chain) must have Id and Manager populated, (legs) are chain legs with operators resolved.
+// (override) of broken eligibility rules, nil if none, is recorded along with the chain status.
+// Return db.ErrSagaInFlight if the chain is already being applied.
+//
+// Like ApplyChange, (s) context is only checked before starting
+func ApplyChain(s *db.Service, chain db.SwapChain, legs []Leg, override *db.RuleOverride) error {
+	if err := s.Context().Err(); err != nil {
+		return err
+	}
+	s = s.WithContext(context.Background())
+
+	sourceName := SourceName()
+	source, cancel, err := sagaSource(s, sourceName)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	saga := db.RosterSaga{
+		Kind:     db.SagaChain,
+		Target:   chain.Id,
+		Source:   sourceName,
+		Manager:  chain.Manager,
+		Legs:     sagaLegs(legs),
+		Override: override,
+	}
+	saga.New(*s)
+	return runSaga(&saga, chainWrite(source, legs))
+}
+
+// chainWrite is the roster write of a swap chain: every leg operator take the next leg assignment
+func chainWrite(source Source, legs []Leg) rosterWrite {
+	return rosterWrite{
+		apply: func() error {
+			return source.Rotate(legs)
+		},
+		revert: func() error {
+			return source.Rotate(ReverseLegs(legs))
+		},
+		applied: func() (bool, error) {
+			// 1st leg operator moved away from 1st leg date and last leg operator took it
+			first, last := legs[0], legs[len(legs)-1]
+			for _, leg := range legs[1:] {
+				if leg.Date.Equal(first.Date) {
+					return false, errors.New("legs on the same day, cannot tell if applied, verify roster manually")
+				}
+			}
+			return movedTo(source, first.Operator, last.Operator, first.Date)
+		},
+	}
+}
+
+// sagaLegs convert (legs) to the legs recorded by a roster saga
+func sagaLegs(legs []Leg) []db.SagaLeg {
+	var res []db.SagaLeg
+	for _, leg := range legs {
+		res = append(res, db.SagaLeg{Operator: leg.Operator.Id, Label: leg.Operator.Label, Date: leg.Date})
+	}
+	return res
+}
+
+// rosterLegs convert legs recorded by a roster saga back to roster legs, operators are found by main label only
+func rosterLegs(legs []db.SagaLeg) []Leg {
+	var res []Leg
+	for _, leg := range legs {
+		res = append(res, Leg{Operator: Operator{Id: leg.Operator, Label: leg.Label}, Date: leg.Date})
+	}
+	return res
+}
+
+// ReverseLegs return the legs of the rotation undoing (legs) rotation
+//
+// After rotating, leg i date is held by leg i-1 operator. Walking legs backwards, each one paired with
+// the date of the leg that follow it, give every date back to its original operator
+func ReverseLegs(legs []Leg) []Leg {
+	n := len(legs)
+	reversed := make([]Leg, n)
+	for k := range legs {
+		reversed[k] = Leg{Operator: legs[(2*n-k-1)%n].Operator, Date: legs[(n-k)%n].Date}
+	}
+	return reversed
+}
@@ -0,0 +1,76 @@
+package roster
+
+import (
+	"testing"
+	"time"
+)
+
+// rotate return who holds every date of (holders) after rotating (legs), mirroring Source.Rotate
+func rotate(holders map[time.Time]string, legs []Leg) map[time.Time]string {
+	res := map[time.Time]string{}
+	for date, operator := range holders {
+		res[date] = operator
+	}
+	for i, leg := range legs {
+		res[leg.Date] = legs[(i+len(legs)-1)%len(legs)].Operator.Id
+	}
+	return res
+}
+
+func TestReverseLegs(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		legs []Leg
+	}{
+		{"Two legs", []Leg{
+			{Operator{Id: "a"}, day(6)},
+			{Operator{Id: "b"}, day(7)},
+		}},
+		{"Three legs", []Leg{
+			{Operator{Id: "a"}, day(6)},
+			{Operator{Id: "b"}, day(7)},
+			{Operator{Id: "c"}, day(8)},
+		}},
+		{"Five legs", []Leg{
+			{Operator{Id: "a"}, day(6)},
+			{Operator{Id: "b"}, day(7)},
+			{Operator{Id: "c"}, day(8)},
+			{Operator{Id: "d"}, day(9)},
+			{Operator{Id: "e"}, day(10)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holders := map[time.Time]string{}
+			for _, leg := range tt.legs {
+				holders[leg.Date] = leg.Operator.Id
+			}
+
+			rotated := rotate(holders, tt.legs)
+			for i, leg := range tt.legs {
+				// Every operator must hold the next leg date
+				next := tt.legs[(i+1)%len(tt.legs)]
+				if rotated[next.Date] != leg.Operator.Id {
+					t.Errorf("after rotate %v held by %v, want %v", next.Date, rotated[next.Date], leg.Operator.Id)
+				}
+			}
+
+			// Reverse legs must only name operators holding the date when reverting
+			reversed := ReverseLegs(tt.legs)
+			for _, leg := range reversed {
+				if rotated[leg.Date] != leg.Operator.Id {
+					t.Errorf("reverse leg %v %v, date held by %v", leg.Operator.Id, leg.Date, rotated[leg.Date])
+				}
+			}
+
+			restored := rotate(rotated, reversed)
+			for date, operator := range holders {
+				if restored[date] != operator {
+					t.Errorf("after revert %v held by %v, want %v", date, restored[date], operator)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package roster
+
+import (
+	"context"
+	"shift-manager/db"
+	"time"
+)
+
+// ApplyCover apply an accepted cover request to the roster, then commit its status to DB, as a saga
+//
+// If the DB update fails the shift is handed back to the applicant.
+//
+// (cover) must have Id and Manager populated, (override) of broken eligibility rules is nil if none.
+// Return db.ErrSagaInFlight if the request is already being applied.
+//
+// Like ApplyChange, (s) context is only checked before starting
+func ApplyCover(s *db.Service, cover db.CoverRequest, applicant Operator, covering Operator, override *db.RuleOverride) error {
+	saga := db.RosterSaga{Kind: db.SagaCover, Target: cover.Id, Manager: cover.Manager, Override: override}
+	return applyCoverSaga(s, saga, applicant, cover.ApplicantDate, covering)
+}
+
+// AwardOffer hand open (offer) shift to (winner) bidder on the roster, then record the award to DB
+//
+// Works like ApplyCover: if the award can't be recorded the shift is handed back to the applicant
+func AwardOffer(s *db.Service, offer db.ShiftOffer, applicant Operator, winner Operator, manager string, override *db.RuleOverride) error {
+	saga := db.RosterSaga{Kind: db.SagaOffer, Target: offer.Id, Manager: manager, Override: override}
+	return applyCoverSaga(s, saga, applicant, offer.Date, winner)
+}
+
+// applyCoverSaga run (saga), handing (applicant) assignment on (date) to (covering) operator
+func applyCoverSaga(s *db.Service, saga db.RosterSaga, applicant Operator, date time.Time, covering Operator) error {
+	if err := s.Context().Err(); err != nil {
+		return err
+	}
+	s = s.WithContext(context.Background())
+
+	sourceName := SourceName()
+	source, cancel, err := sagaSource(s, sourceName)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	saga.Source = sourceName
+	saga.Legs = sagaLegs([]Leg{{Operator: applicant, Date: date}, {Operator: covering, Date: date}})
+	saga.New(*s)
+	return runSaga(&saga, coverWrite(source, applicant, date, covering))
+}
+
+// coverWrite is the roster write of a cover: (applicant) assignment on (date) handed to (covering) operator
+func coverWrite(source Source, applicant Operator, date time.Time, covering Operator) rosterWrite {
+	return rosterWrite{
+		apply: func() error {
+			return source.Cover(applicant, date, covering)
+		},
+		revert: func() error {
+			// Applicant is now free that day, so the same write hand the shift back
+			return source.Cover(covering, date, applicant)
+		},
+		applied: func() (bool, error) {
+			// Applicant moved away from date and covering operator took it
+			return movedTo(source, applicant, covering, date)
+		},
+	}
+}
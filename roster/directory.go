@@ -0,0 +1,129 @@
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"sort"
+	"strings"
+)
+
+// AmbiguousLabelError is returned when a spreadsheet label belong to more than one operator
+type AmbiguousLabelError struct {
+	Label     string   `json:"label"`
+	Operators []string `json:"operators"` // User UUIDs sharing the label
+}
+
+func (e *AmbiguousLabelError) Error() string {
+	return fmt.Sprintf("label %q is ambiguous, shared by operators %s", e.Label, strings.Join(e.Operators, ", "))
+}
+
+// Directory map operators to the labels they're written with on spreadsheet, built from operator aliases
+type Directory struct {
+	labels    map[string][]string // User UUID -> labels, oldest first
+	operators map[string][]string // Label -> user UUIDs
+}
+
+// LoadDirectory read every operator alias from DB
+func LoadDirectory(s db.Service) (Directory, error) {
+	var (
+		alias   db.OperatorAlias
+		aliases []db.OperatorAlias
+	)
+	alias.New(s)
+	if err := alias.GetAll(&aliases); err != nil {
+		return Directory{}, err
+	}
+	return NewDirectory(aliases), nil
+}
+
+// NewDirectory build a directory from (aliases), the first alias of every operator is its main label
+func NewDirectory(aliases []db.OperatorAlias) Directory {
+	d := Directory{labels: map[string][]string{}, operators: map[string][]string{}}
+	for _, a := range aliases {
+		label := normalizeLabel(a.Label)
+		if label == "" {
+			continue
+		}
+		d.labels[a.Operator] = append(d.labels[a.Operator], label)
+		d.operators[label] = append(d.operators[label], a.Operator)
+	}
+	return d
+}
+
+// Operator return operator (id) with the labels it can be found by on spreadsheet
+//
+// Labels shared with other operators are left out as cells holding them can't be attributed.
+// If every operator label is shared an *AmbiguousLabelError is returned
+func (d Directory) Operator(id string) (Operator, error) {
+	labels := d.labels[id]
+	if len(labels) == 0 {
+		return Operator{}, errors.New(fmt.Sprintf("operator %s has no spreadsheet alias", id))
+	}
+
+	var unique []string
+	for _, label := range labels {
+		if len(d.operators[label]) == 1 {
+			unique = append(unique, label)
+		}
+	}
+	if len(unique) == 0 {
+		return Operator{}, &AmbiguousLabelError{Label: labels[0], Operators: d.operators[labels[0]]}
+	}
+
+	return Operator{Id: id, Label: unique[0], Aliases: unique[1:]}, nil
+}
+
+// Label return the main label operator (id) is written with, empty if it has none
+//
+// Main label is the oldest one not shared with other operators, the oldest one if all are shared
+func (d Directory) Label(id string) string {
+	operator, err := d.Operator(id)
+	if err == nil {
+		return operator.Label
+	}
+	if labels := d.labels[id]; len(labels) > 0 {
+		return labels[0]
+	}
+	return ""
+}
+
+// Canonical return the main label of the operator written as (label), or (label) normalized if it
+// doesn't identify a single operator
+//
+// Used to compare cells holding different aliases of the same operator
+func (d Directory) Canonical(label string) string {
+	id, err := d.Resolve(label)
+	if err != nil {
+		return normalizeLabel(label)
+	}
+	return d.Label(id)
+}
+
+// Resolve return the user UUID of the operator written as (label) on spreadsheet, case insensitive
+//
+// Return an *AmbiguousLabelError if label belong to more than one operator
+func (d Directory) Resolve(label string) (string, error) {
+	label = normalizeLabel(label)
+	operators := d.operators[label]
+	switch len(operators) {
+	case 0:
+		return "", errors.New(fmt.Sprintf("no operator with label %q", label))
+	case 1:
+		return operators[0], nil
+	default:
+		return "", &AmbiguousLabelError{Label: label, Operators: operators}
+	}
+}
+
+// Ambiguous return every label shared by more than one operator, sorted by label
+func (d Directory) Ambiguous() []AmbiguousLabelError {
+	var res []AmbiguousLabelError
+	for label, operators := range d.operators {
+		if len(operators) > 1 {
+			res = append(res, AmbiguousLabelError{Label: label, Operators: operators})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Label < res[j].Label })
+	return res
+}
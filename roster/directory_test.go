@@ -0,0 +1,109 @@
+package roster
+
+import (
+	"errors"
+	"reflect"
+	"shift-manager/db"
+	"testing"
+)
+
+func testDirectory() Directory {
+	return NewDirectory([]db.OperatorAlias{
+		{Operator: "1", Label: "ROSSI"},
+		{Operator: "1", Label: "ROSSI M."},
+		{Operator: "2", Label: "rossi"},
+		{Operator: "2", Label: "ROSSI L."},
+		{Operator: "3", Label: "De Luca"},
+		{Operator: "3", Label: "DELUCA"},
+		{Operator: "4", Label: "BIANCHI"},
+		{Operator: "5", Label: "BIANCHI"},
+	})
+}
+
+func TestDirectory_Operator(t *testing.T) {
+	tests := []struct {
+		name          string
+		id            string
+		want          Operator
+		wantErr       bool
+		wantAmbiguous bool
+	}{
+		{name: "Shared label left out", id: "1", want: Operator{Id: "1", Label: "ROSSI M.", Aliases: []string{}}},
+		{name: "Compound surname with alias", id: "3", want: Operator{Id: "3", Label: "DE LUCA", Aliases: []string{"DELUCA"}}},
+		{name: "Only shared labels", id: "4", wantErr: true, wantAmbiguous: true},
+		{name: "No alias", id: "9", wantErr: true},
+	}
+	d := testDirectory()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Operator(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Operator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var ambiguous *AmbiguousLabelError
+			if errors.As(err, &ambiguous) != tt.wantAmbiguous {
+				t.Errorf("Operator() error = %v, wantAmbiguous %v", err, tt.wantAmbiguous)
+			}
+			if err == nil && (got.Id != tt.want.Id || got.Label != tt.want.Label || len(got.Aliases) != len(tt.want.Aliases)) {
+				t.Errorf("Operator() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectory_Resolve(t *testing.T) {
+	tests := []struct {
+		label         string
+		want          string
+		wantErr       bool
+		wantAmbiguous bool
+	}{
+		{label: "rossi m.", want: "1"},
+		{label: " DeLuca ", want: "3"},
+		{label: "ROSSI", wantErr: true, wantAmbiguous: true},
+		{label: "VERDI", wantErr: true},
+	}
+	d := testDirectory()
+	for _, tt := range tests {
+		got, err := d.Resolve(tt.label)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Resolve(%q) error = %v, wantErr %v", tt.label, err, tt.wantErr)
+		}
+		var ambiguous *AmbiguousLabelError
+		if errors.As(err, &ambiguous) != tt.wantAmbiguous {
+			t.Errorf("Resolve(%q) error = %v, wantAmbiguous %v", tt.label, err, tt.wantAmbiguous)
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) got = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestDirectory_Ambiguous(t *testing.T) {
+	want := []AmbiguousLabelError{
+		{Label: "BIANCHI", Operators: []string{"4", "5"}},
+		{Label: "ROSSI", Operators: []string{"1", "2"}},
+	}
+	if got := testDirectory().Ambiguous(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Ambiguous() got = %v, want %v", got, want)
+	}
+}
+
+func TestDirectory_Canonical(t *testing.T) {
+	d := testDirectory()
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"DELUCA", "DE LUCA"},
+		{"rossi l.", "ROSSI L."},
+		{"rossi", "ROSSI"},
+		{" sconosciuto", "SCONOSCIUTO"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := d.Canonical(tt.label); got != tt.want {
+			t.Errorf("Canonical(%q) got = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
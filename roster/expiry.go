@@ -0,0 +1,47 @@
+package roster
+
+import (
+	"fmt"
+	"shift-manager/db"
+	"shift-manager/notify"
+	"time"
+)
+
+// StartChangeExpiry expire change requests whose dates are past (every) interval, never return
+//
+// Requests are checked once on start too, so a restart doesn't leave them open for a whole interval
+func StartChangeExpiry(s *db.Service, every time.Duration) {
+	expireChanges(s)
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expireChanges(s)
+	}
+}
+
+// expireChanges expire change requests whose dates are past, notifying both operators
+func expireChanges(s *db.Service) {
+	var (
+		change  db.ShiftChange
+		expired []db.ShiftChange
+	)
+	change.New(*s)
+
+	if err := change.Expire(time.Now(), &expired); err != nil {
+		fmt.Printf("Error expiring change requests: %v\n", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+	fmt.Printf("Expired %d change requests\n", len(expired))
+
+	notifier := notify.New(*s)
+	for _, e := range expired {
+		if err := notifier.NotifyChange(notify.EventChangeExpired, e, e.ApplicantName, e.WithName); err != nil {
+			fmt.Printf("Error notifying change request %s expiry: %v\n", e.Id, err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"strings"
+	"time"
+)
+
+// Import issue kinds
+const (
+	IssueUnknownOperator   = "unknown_operator"
+	IssueAmbiguousOperator = "ambiguous_operator"
+	IssueUnknownLocation   = "unknown_location"
+	IssueUnknownShift      = "unknown_shift"
+	IssueUnknownVehicle    = "unknown_vehicle"
+	IssueUnknownRole       = "unknown_role"
+	IssueMalformedRoles    = "malformed_roles"
+	IssueSaveError         = "save_error"
+)
+
+// ImportIssue is a roster cell the importer couldn't turn into an assignment
+type ImportIssue struct {
+	Kind  string    `json:"kind"`
+	Date  time.Time `json:"date"`
+	Cell  string    `json:"cell"`  // Roster coordinate in week!A1 format
+	Value string    `json:"value"` // Offending value
+}
+
+// ImportReport summarize an import run
+type ImportReport struct {
+	Year         int           `json:"year"`
+	Weeks        []int         `json:"weeks"`         // Weeks successfully read
+	MissingWeeks []int         `json:"missing_weeks"` // Weeks whose tab couldn't be read
+	Imported     int           `json:"imported"`      // Assignments written to DB
+	Issues       []ImportIssue `json:"issues"`
+}
+
+// Importer backfill the weekly roster tabs of SHIFT_ID spreadsheet into the DB roster
+//
+// Every run upsert assignments, so it can be repeated safely
+type Importer struct {
+	service   db.Service
+	sheet     gsuite.Service
+	dayCoord  gsuite.DayCoord
+	roles     map[string][][]interface{} // Spreadsheet ID -> roles mirror sheet, pipe encoded location|shift|vehicle|role by day block position
+	directory Directory                  // Operators by spreadsheet label
+	locations map[string]string          // Lowercase name -> DB name, same for following catalogs
+	shifts    map[string]string
+	vehicles  map[string]string
+	opRoles   map[string]string
+}
+
+// New prepare the importer loading day coordinates, roles mirror sheet and DB catalogs
+func (i *Importer) New(s db.Service, sheet gsuite.Service) error {
+	i.service = s
+	// Imports and syncs compare against DB, never work on cached reads
+	i.sheet = sheet.Uncached()
+
+	err := i.dayCoord.Load(i.sheet)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving day coordinates: %v\n", err))
+	}
+
+	i.roles = map[string][][]interface{}{}
+	if err = i.loadRoles(time.Now()); err != nil {
+		return err
+	}
+
+	return i.loadCatalogs()
+}
+
+// loadRoles read the roles mirror sheet of the roster spreadsheet holding (date), if not read yet
+func (i *Importer) loadRoles(date time.Time) error {
+	sheetId := i.dayCoord.Spreadsheet(date)
+	if _, ok := i.roles[sheetId]; ok {
+		return nil
+	}
+
+	roles, err := i.sheet.WithSheet(sheetId).ReadRange(gsuite.ActiveLayout().Roles)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error retrieving roles sheet: %v\n", err))
+	}
+	i.roles[sheetId] = roles
+	return nil
+}
+
+// loadCatalogs read operators and catalog tables used to validate roster cells
+func (i *Importer) loadCatalogs() error {
+	var (
+		err       error
+		location  db.Location
+		locations []db.Location
+		shift     db.Shift
+		shifts    []db.Shift
+		vehicle   db.Vehicle
+		vehicles  []db.Vehicle
+		role      db.OperatorRole
+		roles     []db.OperatorRole
+	)
+
+	if i.directory, err = LoadDirectory(i.service); err != nil {
+		return err
+	}
+
+	location.New(i.service)
+	if err := location.GetAll(&locations); err != nil {
+		return err
+	}
+	i.locations = map[string]string{}
+	for _, l := range locations {
+		i.locations[strings.ToLower(l.Name)] = l.Name
+	}
+
+	shift.New(i.service)
+	if err := shift.GetAll(&shifts); err != nil {
+		return err
+	}
+	i.shifts = map[string]string{}
+	for _, s := range shifts {
+		i.shifts[strings.ToLower(s.Name)] = s.Name
+	}
+
+	vehicle.New(i.service)
+	if err := vehicle.GetAll(&vehicles); err != nil {
+		return err
+	}
+	i.vehicles = map[string]string{}
+	for _, v := range vehicles {
+		i.vehicles[strings.ToLower(v.Name)] = v.Name
+	}
+
+	role.New(i.service)
+	if err := role.GetAll(&roles); err != nil {
+		return err
+	}
+	i.opRoles = map[string]string{}
+	for _, r := range roles {
+		i.opRoles[strings.ToLower(r.Name)] = r.Name
+	}
+
+	return nil
+}
+
+// Import read every week tab from (fromWeek) to (toWeek) of ISO (year) and write found assignments
+//
+// Unreadable tabs are reported as missing weeks, cells that can't be imported are reported as issues
+func (i *Importer) Import(year int, fromWeek int, toWeek int) (ImportReport, error) {
+	report := ImportReport{Year: year}
+	if fromWeek < 1 || toWeek > WeeksInYear(year) || fromWeek > toWeek {
+		return report, errors.New(fmt.Sprintf("invalid week interval %d-%d for year %d", fromWeek, toWeek, year))
+	}
+
+	for week := fromWeek; week <= toWeek; week++ {
+		monday := isoWeekStart(year, week)
+		var weekAssignments []db.RosterAssignment
+		missing := false
+
+		// Every day of an ISO week belong to the same ISO year spreadsheet
+		if err := i.loadRoles(monday); err != nil {
+			return report, err
+		}
+
+		for dayIndex := 0; dayIndex < 7; dayIndex++ {
+			date := monday.AddDate(0, 0, dayIndex)
+			day, err := i.sheet.ReadDay(i.dayCoord, date)
+			if err != nil {
+				fmt.Printf("Cannot read roster week %d: %v\n", week, err)
+				missing = true
+				break
+			}
+
+			assignments, issues := i.parseDay(date, day)
+			weekAssignments = append(weekAssignments, assignments...)
+			report.Issues = append(report.Issues, issues...)
+		}
+
+		if missing {
+			report.MissingWeeks = append(report.MissingWeeks, week)
+			continue
+		}
+		report.Weeks = append(report.Weeks, week)
+
+		for _, assignment := range weekAssignments {
+			assignment.New(i.service)
+			if err := assignment.Save(); err != nil {
+				report.Issues = append(report.Issues, ImportIssue{
+					Kind:  IssueSaveError,
+					Date:  assignment.Date,
+					Value: err.Error(),
+				})
+				continue
+			}
+			report.Imported++
+		}
+	}
+
+	return report, nil
+}
+
+// parseDay turn a day matrix into assignments, using the roles sheet cell at the same position of each operator
+func (i *Importer) parseDay(date time.Time, day [][]interface{}) ([]db.RosterAssignment, []ImportIssue) {
+	var (
+		assignments []db.RosterAssignment
+		issues      []ImportIssue
+	)
+
+	for rowIndex, row := range day {
+		for colIndex, cell := range row {
+			name := strings.TrimSpace(fmt.Sprint(cell))
+			if name == "" {
+				continue
+			}
+
+			assignment, issue := i.assignmentAt(date, rowIndex, colIndex, name)
+			if issue != nil {
+				issues = append(issues, *issue)
+				continue
+			}
+			assignments = append(assignments, assignment)
+		}
+	}
+
+	return assignments, issues
+}
+
+// assignmentAt build the assignment of operator (name) found at zero based (rowIndex, colIndex) of (date) day block
+//
+// Return an issue if operator or any roles component can't be resolved
+func (i *Importer) assignmentAt(date time.Time, rowIndex int, colIndex int, name string) (db.RosterAssignment, *ImportIssue) {
+	coord := i.dayCoord.CellAt(date, rowIndex, colIndex)
+	issue := func(kind string, value string) *ImportIssue {
+		return &ImportIssue{Kind: kind, Date: date, Cell: coord, Value: value}
+	}
+
+	// Resolve operator by spreadsheet label
+	operator, err := i.directory.Resolve(name)
+	var ambiguous *AmbiguousLabelError
+	if errors.As(err, &ambiguous) {
+		return db.RosterAssignment{}, issue(IssueAmbiguousOperator, name)
+	}
+	if err != nil {
+		return db.RosterAssignment{}, issue(IssueUnknownOperator, name)
+	}
+
+	// Decode roles cell at same position
+	var rolesCell string
+	rolesSheet := i.roles[i.dayCoord.Spreadsheet(date)]
+	if rowIndex < len(rolesSheet) && colIndex < len(rolesSheet[rowIndex]) {
+		rolesCell = fmt.Sprint(rolesSheet[rowIndex][colIndex])
+	}
+	roles, err := ParseRoles(rolesCell)
+	if err != nil {
+		return db.RosterAssignment{}, issue(IssueMalformedRoles, rolesCell)
+	}
+
+	// Validate every component against DB catalogs
+	location, ok := i.locations[strings.ToLower(roles.Location)]
+	if !ok {
+		return db.RosterAssignment{}, issue(IssueUnknownLocation, roles.Location)
+	}
+	shift, ok := i.shifts[strings.ToLower(roles.Shift)]
+	if !ok {
+		return db.RosterAssignment{}, issue(IssueUnknownShift, roles.Shift)
+	}
+	vehicle, ok := i.vehicles[strings.ToLower(roles.Vehicle)]
+	if !ok && roles.Vehicle != "" {
+		return db.RosterAssignment{}, issue(IssueUnknownVehicle, roles.Vehicle)
+	}
+	role, ok := i.opRoles[strings.ToLower(roles.Role)]
+	if !ok {
+		return db.RosterAssignment{}, issue(IssueUnknownRole, roles.Role)
+	}
+
+	return db.RosterAssignment{
+		Date:     date,
+		Operator: operator,
+		Location: location,
+		Shift:    shift,
+		Vehicle:  vehicle,
+		Role:     role,
+		Cell:     coord,
+	}, nil
+}
+
+// isoWeekStart return the monday of ISO (week) of (year)
+func isoWeekStart(year int, week int) time.Time {
+	// 4th of January is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	return jan4.AddDate(0, 0, -offset+(week-1)*7)
+}
+
+// WeeksInYear return the number of ISO weeks of (year), 52 or 53
+func WeeksInYear(year int) int {
+	// 28th of December is always in the last week
+	_, week := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek()
+	return week
+}
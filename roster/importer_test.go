@@ -0,0 +1,94 @@
+package roster
+
+import (
+	"os"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"testing"
+	"time"
+)
+
+func TestImporter_parseDay(t *testing.T) {
+	os.Setenv("WEEKDAY_RANGE", "Config!A1:B7")
+
+	m := gsuite.NewMemoryBackend()
+	err := m.SetRange("roster", "Config!A1", [][]string{
+		{"A1", "B2"}, {"D1", "E2"}, {"G1", "H2"}, {"J1", "K2"}, {"M1", "N2"}, {"P1", "Q2"}, {"S1", "T2"},
+	})
+	if err != nil {
+		t.Fatalf("error populating test roster: %v", err)
+	}
+	sheet := gsuite.Service{}
+	sheet.NewWithBackend(m, "roster")
+
+	i := Importer{
+		roles: map[string][][]interface{}{"roster": {
+			{"Sede|Mattino|MSB1|Autista", "Sede|Mattino|MSB1"},
+			{"Altrove|Notte|MSB2|Autista", "Sede|Notte|Auto9|Soccorritore"},
+		}},
+		directory: NewDirectory([]db.OperatorAlias{
+			{Operator: "1", Label: "ROSSI"},
+			{Operator: "2", Label: "BIANCHI"},
+			{Operator: "3", Label: "BIANCHI"},
+			{Operator: "4", Label: "VERDI"},
+			{Operator: "5", Label: "NERI"},
+		}),
+		locations: map[string]string{"sede": "Sede"},
+		shifts:    map[string]string{"mattino": "Mattino", "notte": "Notte"},
+		vehicles:  map[string]string{"msb1": "MSB1", "msb2": "MSB2"},
+		opRoles:   map[string]string{"autista": "Autista", "soccorritore": "Soccorritore"},
+	}
+	if err = i.dayCoord.Load(sheet); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Tuesday of week 2, 2020
+	date := time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+	day := [][]interface{}{
+		{"Rossi", "Verdi"},
+		{"Neri", "Bianchi"},
+		{"", "Sconosciuto"},
+	}
+
+	assignments, issues := i.parseDay(date, day)
+
+	if len(assignments) != 1 || assignments[0].Operator != "1" || assignments[0].Role != "Autista" {
+		t.Errorf("parseDay() assignments = %v, want only Rossi as Autista", assignments)
+	}
+
+	wantIssues := []ImportIssue{
+		{Kind: IssueMalformedRoles, Date: date, Cell: "2!E1", Value: "Sede|Mattino|MSB1"},
+		{Kind: IssueUnknownLocation, Date: date, Cell: "2!D2", Value: "Altrove"},
+		{Kind: IssueAmbiguousOperator, Date: date, Cell: "2!E2", Value: "Bianchi"},
+		{Kind: IssueUnknownOperator, Date: date, Cell: "2!E3", Value: "Sconosciuto"},
+	}
+	if len(issues) != len(wantIssues) {
+		t.Fatalf("parseDay() issues = %v, want %v", issues, wantIssues)
+	}
+	for index, want := range wantIssues {
+		if issues[index] != want {
+			t.Errorf("parseDay() issue %d = %v, want %v", index, issues[index], want)
+		}
+	}
+}
+
+func TestIsoWeekStart(t *testing.T) {
+	tests := []struct {
+		year int
+		week int
+		want time.Time
+	}{
+		{2020, 1, time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC)},
+		{2020, 2, time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{2021, 1, time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := isoWeekStart(tt.year, tt.week); !got.Equal(tt.want) {
+			t.Errorf("isoWeekStart(%d, %d) = %v, want %v", tt.year, tt.week, got, tt.want)
+		}
+	}
+
+	if got := WeeksInYear(2020); got != 53 {
+		t.Errorf("WeeksInYear(2020) = %d, want 53", got)
+	}
+}
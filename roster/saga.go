@@ -0,0 +1,326 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"time"
+)
+
+const (
+	// sagaTimeout bound every roster write of a saga, Sheets quota retries included
+	sagaTimeout = 10 * time.Minute
+	// sagaRecoveryAge is how long a saga must be left untouched before recovery take it over,
+	// well beyond a roster write so a live saga waiting on Sheets is never taken for a crashed one
+	sagaRecoveryAge = 3 * sagaTimeout
+)
+
+// sagaRecord is the durable record of a saga, updated before and after every step
+type sagaRecord interface {
+	// Start record saga intent, before touching the roster
+	Start() error
+	// SetStatus move an unfinished saga to (status), recording (lastError) if any
+	SetStatus(status string, lastError string) error
+	// Claim take over a saga left untouched for more than (age), false if someone else did first
+	Claim(age time.Duration) (bool, error)
+	// Commit record saga outcome to DB and mark it committed, in a single transaction
+	Commit() error
+	// Committed tell if saga outcome is already recorded to DB, by this saga or another instance running it
+	Committed() (bool, error)
+}
+
+// rosterWrite is the roster side of a saga
+type rosterWrite struct {
+	apply   func() error         // Write the roster
+	revert  func() error         // Undo apply
+	applied func() (bool, error) // Tell if apply is found on the roster
+}
+
+// swapWrite is the roster write of a change request: (first) operator take (second) operator assignment
+// on (secondDate) and vice versa
+func swapWrite(source Source, first Operator, firstDate time.Time, second Operator, secondDate time.Time) rosterWrite {
+	return rosterWrite{
+		apply: func() error {
+			return source.Swap(first, firstDate, second, secondDate)
+		},
+		revert: func() error {
+			// After the swap 2nd operator hold 1st date and vice versa, swapping them again revert it
+			return source.Swap(second, firstDate, first, secondDate)
+		},
+		applied: func() (bool, error) {
+			// 1st operator moved away from 1st date and 2nd operator took it
+			if firstDate.Equal(secondDate) {
+				return false, errors.New("same day swap, cannot tell if applied, verify roster manually")
+			}
+			return movedTo(source, first, second, firstDate)
+		},
+	}
+}
+
+// movedTo tell if (from) operator is free on (date) and (to) operator on shift, a write moved the assignment
+//
+// A roster that can't be read is reported as error, never taken for a write not applied
+func movedTo(source Source, from Operator, to Operator, date time.Time) (bool, error) {
+	fromOnShift, err := OnShift(source, from, date)
+	if err != nil {
+		return false, err
+	}
+	toOnShift, err := OnShift(source, to, date)
+	if err != nil {
+		return false, err
+	}
+	return !fromOnShift && toOnShift, nil
+}
+
+// sagaSource return the source named (name), its writes bound to sagaTimeout
+//
+// Sagas run detached from the request that started them, cancel must be called once done
+func sagaSource(s *db.Service, name string) (Source, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sagaTimeout)
+	source, err := NewSourceByName(s.WithContext(ctx), name)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return source, cancel, nil
+}
+
+// ApplyChange apply an accepted change request to the roster and commit it to DB as a saga
+//
+// Intent is recorded first, then the roster swapped and finally the change request marked applied.
+// If the DB update fails the roster swap is reverted, so a retry doesn't swap it back.
+//
+// (change) must be already accepted, with Id and Manager populated. (override) of broken eligibility rules, nil
+// if none, is recorded along with the change request status.
+// Return db.ErrSagaInFlight if the change request is already being applied.
+//
+// (s) context is only checked before starting: once started the saga run to completion on its own,
+// a swap abandoned halfway would be left to crash recovery
+func ApplyChange(s *db.Service, change db.ShiftChange, first Operator, firstDate time.Time, second Operator, secondDate time.Time, override *db.RuleOverride) error {
+	if err := s.Context().Err(); err != nil {
+		return err
+	}
+	s = s.WithContext(context.Background())
+
+	sourceName := SourceName()
+	source, cancel, err := sagaSource(s, sourceName)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	saga := db.SwapSaga{
+		Change:         change.Id,
+		Source:         sourceName,
+		Manager:        change.Manager,
+		ChangeStatus:   db.ChangeApplied,
+		FirstOperator:  first.Id,
+		FirstLabel:     first.Label,
+		FirstDate:      firstDate,
+		SecondOperator: second.Id,
+		SecondLabel:    second.Label,
+		SecondDate:     secondDate,
+		Override:       override,
+	}
+	saga.New(*s)
+	return runSaga(&saga, swapWrite(source, first, firstDate, second, secondDate))
+}
+
+// runSaga record (record) intent, apply (write) to the roster and commit, reverting the roster if commit fails
+func runSaga(record sagaRecord, write rosterWrite) error {
+	// Record intent
+	if err := record.Start(); err != nil {
+		return err
+	}
+
+	// Apply roster change, nothing to revert on failure
+	if err := write.apply(); err != nil {
+		record.SetStatus(db.SagaFailed, err.Error())
+		return err
+	}
+	if err := record.SetStatus(db.SagaRosterApplied, ""); err != nil {
+		return compensate(record, write, err)
+	}
+
+	// Commit to DB, revert roster on failure
+	if err := record.Commit(); err != nil {
+		return compensate(record, write, err)
+	}
+	return nil
+}
+
+// compensate revert saga roster write after (cause) prevented the commit
+//
+// Nothing is reverted if the commit went through anyway, like when another instance recovered the saga
+// first or the connection dropped after committing. If that can't be told the saga is left unfinished,
+// for recovery to roll it forward or revert it later
+func compensate(record sagaRecord, write rosterWrite, cause error) error {
+	committed, err := record.Committed()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error committing saga: %v - roster left applied for recovery: %v\n", cause, err))
+	}
+	if committed {
+		// Already committed by another instance if this fails, nothing left to do
+		record.SetStatus(db.SagaCommitted, "")
+		return nil
+	}
+
+	if err = write.revert(); err != nil {
+		record.SetStatus(db.SagaFailed, fmt.Sprintf("commit failed: %v - revert failed: %v", cause, err))
+		return errors.New(fmt.Sprintf("error committing saga: %v - roster revert failed, manual intervention required: %v\n", cause, err))
+	}
+
+	record.SetStatus(db.SagaCompensated, cause.Error())
+	return errors.New(fmt.Sprintf("error committing saga, roster write reverted: %v\n", cause))
+}
+
+// recoverSaga claim (record), left in (status) for more than (age), and reconcile it
+//
+// Sagas in roster_applied status are rolled forward committing them, or reverted if commit fails.
+// Sagas in started status are checked against the roster: if the write is found applied they're rolled
+// forward too, otherwise they're marked failed and the request is left for the manager to retry.
+// Return false if saga was claimed by someone else
+func recoverSaga(record sagaRecord, write rosterWrite, status string, age time.Duration) (bool, error) {
+	claimed, err := record.Claim(age)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if status == db.SagaStarted {
+		applied, err := write.applied()
+		if err != nil {
+			record.SetStatus(db.SagaFailed, err.Error())
+			return true, err
+		}
+		if !applied {
+			record.SetStatus(db.SagaFailed, "roster not written, request left for the manager to retry")
+			return true, errors.New("roster not written, saga marked failed")
+		}
+	}
+
+	if err = record.Commit(); err != nil {
+		return true, compensate(record, write, err)
+	}
+	return true, nil
+}
+
+// RecoverSwapSagas reconcile swap sagas left unfinished for more than (age), usually by a crash, see recoverSaga
+//
+// Every saga is claimed first, so concurrent instances never recover the same one
+func RecoverSwapSagas(s *db.Service, age time.Duration) error {
+	var (
+		saga  db.SwapSaga
+		sagas []db.SwapSaga
+	)
+
+	saga.New(*s)
+	if err := saga.GetAllUnfinished(age, &sagas); err != nil {
+		return err
+	}
+
+	for index := range sagas {
+		unfinished := &sagas[index]
+		source, cancel, err := sagaSource(s, unfinished.Source)
+		if err != nil {
+			if claimed, _ := unfinished.Claim(age); claimed {
+				unfinished.SetStatus(db.SagaFailed, err.Error())
+			}
+			continue
+		}
+
+		first := Operator{Id: unfinished.FirstOperator, Label: unfinished.FirstLabel}
+		second := Operator{Id: unfinished.SecondOperator, Label: unfinished.SecondLabel}
+		write := swapWrite(source, first, unfinished.FirstDate, second, unfinished.SecondDate)
+		claimed, err := recoverSaga(unfinished, write, unfinished.Status, age)
+		cancel()
+
+		switch {
+		case err != nil:
+			fmt.Printf("Swap saga %s: %v\n", unfinished.Id, err)
+		case claimed:
+			fmt.Printf("Swap saga %s recovered and committed\n", unfinished.Id)
+		}
+	}
+	return nil
+}
+
+// RecoverRosterSagas reconcile roster sagas left unfinished for more than (age), like RecoverSwapSagas
+func RecoverRosterSagas(s *db.Service, age time.Duration) error {
+	var (
+		saga  db.RosterSaga
+		sagas []db.RosterSaga
+	)
+
+	saga.New(*s)
+	if err := saga.GetAllUnfinished(age, &sagas); err != nil {
+		return err
+	}
+
+	for index := range sagas {
+		unfinished := &sagas[index]
+		source, cancel, err := sagaSource(s, unfinished.Source)
+		if err != nil {
+			if claimed, _ := unfinished.Claim(age); claimed {
+				unfinished.SetStatus(db.SagaFailed, err.Error())
+			}
+			continue
+		}
+		write, err := rosterSagaWrite(source, unfinished)
+		if err != nil {
+			cancel()
+			if claimed, _ := unfinished.Claim(age); claimed {
+				unfinished.SetStatus(db.SagaFailed, err.Error())
+			}
+			continue
+		}
+
+		claimed, err := recoverSaga(unfinished, write, unfinished.Status, age)
+		cancel()
+
+		switch {
+		case err != nil:
+			fmt.Printf("Roster saga %s (%s %s): %v\n", unfinished.Id, unfinished.Kind, unfinished.Target, err)
+		case claimed:
+			fmt.Printf("Roster saga %s (%s %s) recovered and committed\n", unfinished.Id, unfinished.Kind, unfinished.Target)
+		}
+	}
+	return nil
+}
+
+// rosterSagaWrite return the roster write of (saga) kind
+func rosterSagaWrite(source Source, saga *db.RosterSaga) (rosterWrite, error) {
+	switch saga.Kind {
+	case db.SagaChain:
+		if len(saga.Legs) < 2 {
+			return rosterWrite{}, errors.New("a swap chain need at least two legs")
+		}
+		return chainWrite(source, rosterLegs(saga.Legs)), nil
+	case db.SagaCover, db.SagaOffer:
+		// 1st leg is the applicant, 2nd the operator covering on the same date
+		if len(saga.Legs) != 2 {
+			return rosterWrite{}, errors.New(fmt.Sprintf("a %s saga need two legs", saga.Kind))
+		}
+		legs := rosterLegs(saga.Legs)
+		return coverWrite(source, legs[0].Operator, legs[0].Date, legs[1].Operator), nil
+	default:
+		return rosterWrite{}, errors.New(fmt.Sprintf("unknown roster saga kind %q", saga.Kind))
+	}
+}
+
+// StartSagaRecovery run swap and roster saga recovery (every) interval, never return
+//
+// Only sagas untouched for sagaRecoveryAge are recovered, whatever the interval
+func StartSagaRecovery(s *db.Service, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := RecoverSwapSagas(s, sagaRecoveryAge); err != nil {
+			fmt.Printf("Error recovering swap sagas: %v\n", err)
+		}
+		if err := RecoverRosterSagas(s, sagaRecoveryAge); err != nil {
+			fmt.Printf("Error recovering roster sagas: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"testing"
+	"time"
+)
+
+// fakeSaga is a sagaRecord kept in memory, recording every status it's moved to
+type fakeSaga struct {
+	statuses  []string
+	commitErr error // Returned by Commit
+	committed bool  // Returned by Committed
+	unclaimed bool  // Claim report someone else took the saga
+}
+
+func (f *fakeSaga) Start() error {
+	f.statuses = append(f.statuses, db.SagaStarted)
+	return nil
+}
+
+func (f *fakeSaga) SetStatus(status string, lastError string) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func (f *fakeSaga) Claim(age time.Duration) (bool, error) {
+	return !f.unclaimed, nil
+}
+
+func (f *fakeSaga) Commit() error {
+	if f.commitErr != nil {
+		return f.commitErr
+	}
+	f.statuses = append(f.statuses, db.SagaCommitted)
+	return nil
+}
+
+func (f *fakeSaga) Committed() (bool, error) {
+	return f.committed, nil
+}
+
+var (
+	sagaMonday  = time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	sagaTuesday = time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+	sagaRossi   = Operator{Id: "1", Label: "ROSSI"}
+	sagaVerdi   = Operator{Id: "2", Label: "VERDI"}
+)
+
+// newSagaRoster point SheetSource to a memory roster with Monday and Tuesday of week 2, 2020 populated
+//
+// Rossi and Neri work Monday, Verdi and Bianchi Tuesday
+func newSagaRoster(t *testing.T) (*gsuite.MemoryBackend, Source) {
+	os.Setenv("SHIFT_ID", "roster")
+	os.Setenv("WEEKDAY_RANGE", "Config!A1:B7")
+	os.Setenv("ROLES_RANGE", "Ruoli!A1:G3")
+	os.Setenv("ROSTER_CACHE_TTL", "0")
+
+	m := gsuite.NewMemoryBackend()
+	fixtures := []struct {
+		r      string
+		values [][]string
+	}{
+		{"Config!A1", [][]string{{"A1", "C3"}, {"E1", "G3"}, {"I1", "K3"}, {"M1", "O3"}, {"Q1", "S3"}, {"U1", "W3"}, {"Y1", "AA3"}}},
+		{"Ruoli!A1", [][]string{
+			{"Sede|Mattino|MSB1|Autista", "", "", "", "Sede|Mattino|MSB1|Autista"},
+			{"Sede|Pomeriggio|MSB1|Autista", "", "", "", "Sede|Pomeriggio|MSB1|Autista"},
+		}},
+		{"2!A1", [][]string{{"ROSSI"}, {"NERI"}}},
+		{"2!E1", [][]string{{"VERDI"}, {"BIANCHI"}}},
+	}
+	for _, f := range fixtures {
+		if err := m.SetRange("roster", f.r, f.values); err != nil {
+			t.Fatalf("error populating test roster: %v", err)
+		}
+	}
+
+	gsuite.DefaultBackend = m
+	return m, SheetSource{ctx: context.Background()}
+}
+
+// assertCells check (cells) of the memory roster hold the wanted values
+func assertCells(t *testing.T, m *gsuite.MemoryBackend, cells map[string]string) {
+	t.Helper()
+	for r, want := range cells {
+		values, err := m.Get(context.Background(), "roster", r)
+		if err != nil {
+			t.Fatalf("Get(%v) error = %v", r, err)
+		}
+		got := ""
+		if len(values) > 0 && len(values[0]) > 0 {
+			got = values[0][0].(string)
+		}
+		if got != want {
+			t.Errorf("cell %v got = %v, want %v", r, got, want)
+		}
+	}
+}
+
+var (
+	sagaOriginal = map[string]string{"2!A1": "ROSSI", "2!E1": "VERDI"}
+	sagaSwapped  = map[string]string{"2!A1": "VERDI", "2!E1": "ROSSI"}
+)
+
+func TestRunSaga(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+
+	tests := []struct {
+		name         string
+		saga         *fakeSaga
+		second       Operator
+		wantErr      bool
+		wantStatuses []string
+		wantCells    map[string]string
+	}{
+		{
+			name:         "Committed",
+			saga:         &fakeSaga{},
+			second:       sagaVerdi,
+			wantStatuses: []string{db.SagaStarted, db.SagaRosterApplied, db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+		{
+			name:         "Commit failed, roster reverted",
+			saga:         &fakeSaga{commitErr: &db.TransitionError{Id: "change", From: db.ChangeCancelled, To: db.ChangeApplied}},
+			second:       sagaVerdi,
+			wantErr:      true,
+			wantStatuses: []string{db.SagaStarted, db.SagaRosterApplied, db.SagaCompensated},
+			wantCells:    sagaOriginal,
+		},
+		{
+			name:         "Commit failed but went through, roster kept",
+			saga:         &fakeSaga{commitErr: errors.New("connection reset"), committed: true},
+			second:       sagaVerdi,
+			wantStatuses: []string{db.SagaStarted, db.SagaRosterApplied, db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+		{
+			name:         "Swap failed, nothing to revert",
+			saga:         &fakeSaga{},
+			second:       Operator{Id: "3", Label: "BLU"},
+			wantErr:      true,
+			wantStatuses: []string{db.SagaStarted, db.SagaFailed},
+			wantCells:    sagaOriginal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, source := newSagaRoster(t)
+
+			err := runSaga(tt.saga, swapWrite(source, sagaRossi, sagaMonday, tt.second, sagaTuesday))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runSaga() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.saga.statuses, tt.wantStatuses) {
+				t.Errorf("runSaga() statuses = %v, want %v", tt.saga.statuses, tt.wantStatuses)
+			}
+			assertCells(t, m, tt.wantCells)
+		})
+	}
+}
+
+func TestCompensate(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+	cause := errors.New("connection reset")
+
+	tests := []struct {
+		name         string
+		saga         *fakeSaga
+		wantErr      bool
+		wantStatuses []string
+		wantCells    map[string]string
+	}{
+		{
+			name:         "Not committed, reverted",
+			saga:         &fakeSaga{},
+			wantErr:      true,
+			wantStatuses: []string{db.SagaCompensated},
+			wantCells:    sagaOriginal,
+		},
+		{
+			name:         "Already applied by another instance, never reverted",
+			saga:         &fakeSaga{committed: true},
+			wantStatuses: []string{db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, source := newSagaRoster(t)
+			write := swapWrite(source, sagaRossi, sagaMonday, sagaVerdi, sagaTuesday)
+			if err := write.apply(); err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+
+			err := compensate(tt.saga, write, cause)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("compensate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.saga.statuses, tt.wantStatuses) {
+				t.Errorf("compensate() statuses = %v, want %v", tt.saga.statuses, tt.wantStatuses)
+			}
+			assertCells(t, m, tt.wantCells)
+		})
+	}
+}
+
+func TestRecoverSaga(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+
+	tests := []struct {
+		name         string
+		saga         *fakeSaga
+		status       string // Status saga was left in
+		swapped      bool   // Roster swap applied before the crash
+		wantClaimed  bool
+		wantErr      bool
+		wantStatuses []string
+		wantCells    map[string]string
+	}{
+		{
+			name:         "Roster applied, rolled forward",
+			saga:         &fakeSaga{},
+			status:       db.SagaRosterApplied,
+			swapped:      true,
+			wantClaimed:  true,
+			wantStatuses: []string{db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+		{
+			name:         "Started and swap found on roster, rolled forward",
+			saga:         &fakeSaga{},
+			status:       db.SagaStarted,
+			swapped:      true,
+			wantClaimed:  true,
+			wantStatuses: []string{db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+		{
+			name:         "Started and swap not on roster, failed",
+			saga:         &fakeSaga{},
+			status:       db.SagaStarted,
+			wantClaimed:  true,
+			wantErr:      true,
+			wantStatuses: []string{db.SagaFailed},
+			wantCells:    sagaOriginal,
+		},
+		{
+			name:         "Roster applied and commit refused, reverted",
+			saga:         &fakeSaga{commitErr: &db.TransitionError{Id: "change", From: db.ChangeCancelled, To: db.ChangeApplied}},
+			status:       db.SagaRosterApplied,
+			swapped:      true,
+			wantClaimed:  true,
+			wantErr:      true,
+			wantStatuses: []string{db.SagaCompensated},
+			wantCells:    sagaOriginal,
+		},
+		{
+			name:         "Roster applied and committed by the live saga meanwhile, kept",
+			saga:         &fakeSaga{commitErr: &db.TransitionError{Id: "change", From: db.ChangeApplied, To: db.ChangeApplied}, committed: true},
+			status:       db.SagaRosterApplied,
+			swapped:      true,
+			wantClaimed:  true,
+			wantStatuses: []string{db.SagaCommitted},
+			wantCells:    sagaSwapped,
+		},
+		{
+			name:      "Claimed by another instance, left alone",
+			saga:      &fakeSaga{unclaimed: true},
+			status:    db.SagaRosterApplied,
+			swapped:   true,
+			wantCells: sagaSwapped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, source := newSagaRoster(t)
+			write := swapWrite(source, sagaRossi, sagaMonday, sagaVerdi, sagaTuesday)
+			if tt.swapped {
+				if err := write.apply(); err != nil {
+					t.Fatalf("apply() error = %v", err)
+				}
+			}
+
+			claimed, err := recoverSaga(tt.saga, write, tt.status, sagaRecoveryAge)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("recoverSaga() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if claimed != tt.wantClaimed {
+				t.Errorf("recoverSaga() claimed = %v, want %v", claimed, tt.wantClaimed)
+			}
+			if !reflect.DeepEqual(tt.saga.statuses, tt.wantStatuses) {
+				t.Errorf("recoverSaga() statuses = %v, want %v", tt.saga.statuses, tt.wantStatuses)
+			}
+			assertCells(t, m, tt.wantCells)
+		})
+	}
+}
+
+func TestChainWrite(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+	m, source := newSagaRoster(t)
+	sagaNeri := Operator{Id: "3", Label: "NERI"}
+
+	// Rossi take Verdi Tuesday, Verdi take Neri Monday afternoon, Neri take Rossi Monday morning
+	write := chainWrite(source, []Leg{{sagaRossi, sagaMonday}, {sagaVerdi, sagaTuesday}, {sagaNeri, sagaMonday}})
+	if _, err := write.applied(); err == nil {
+		t.Errorf("applied() expected error, legs share a day")
+	}
+
+	write = chainWrite(source, []Leg{{sagaRossi, sagaMonday}, {sagaVerdi, sagaTuesday}})
+	saga := &fakeSaga{commitErr: errors.New("connection reset")}
+	if err := runSaga(saga, write); err == nil {
+		t.Errorf("runSaga() expected error, commit failed")
+	}
+	assertCells(t, m, sagaOriginal)
+
+	if err := write.apply(); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	assertCells(t, m, sagaSwapped)
+	claimed, err := recoverSaga(&fakeSaga{}, write, db.SagaStarted, sagaRecoveryAge)
+	if err != nil || !claimed {
+		t.Errorf("recoverSaga() = %v, %v, want rotation found applied and committed", claimed, err)
+	}
+}
+
+func TestCoverWrite(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+	m, source := newSagaRoster(t)
+
+	// Verdi, free on Monday, cover Rossi
+	write := coverWrite(source, sagaRossi, sagaMonday, sagaVerdi)
+	saga := &fakeSaga{commitErr: errors.New("connection reset")}
+	if err := runSaga(saga, write); err == nil {
+		t.Errorf("runSaga() expected error, commit failed")
+	}
+	assertCells(t, m, map[string]string{"2!A1": "ROSSI"})
+
+	if err := write.apply(); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	assertCells(t, m, map[string]string{"2!A1": "VERDI"})
+	claimed, err := recoverSaga(&fakeSaga{}, write, db.SagaStarted, sagaRecoveryAge)
+	if err != nil || !claimed {
+		t.Errorf("recoverSaga() = %v, %v, want cover found applied and committed", claimed, err)
+	}
+}
@@ -0,0 +1,264 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"strings"
+	"time"
+)
+
+// ErrNotAssigned is returned by Source.Assignment when operator is free on the requested day.
+// Any other error means the roster couldn't be read, not that the operator is free
+var ErrNotAssigned = errors.New("operator not on shift on requested date")
+
+// Assignment is what an operator is assigned to on a given day
+type Assignment struct {
+	Location string
+	Shift    string
+	Vehicle  string
+	Role     string
+}
+
+// Operator identify an operator on both roster sources
+type Operator struct {
+	Id      string   // DB user UUID
+	Label   string   // Name as written on the spreadsheet
+	Aliases []string // Other names operator may be written with, see Directory
+}
+
+// Labels return every name operator may be written with on the spreadsheet, main label first
+func (o Operator) Labels() []string {
+	return append([]string{o.Label}, o.Aliases...)
+}
+
+// Source is where the authoritative roster is read from and swaps are applied to
+type Source interface {
+	// Assignment retrieve operator (o) assignment on (date), ErrNotAssigned if operator is free that day
+	Assignment(o Operator, date time.Time) (Assignment, error)
+	// Swap switch (first) operator assignment on (firstDate) with (second) operator assignment on (secondDate)
+	Swap(first Operator, firstDate time.Time, second Operator, secondDate time.Time) error
+	// Rotate move every leg assignment to the previous leg operator, all at once
+	Rotate(legs []Leg) error
+	// Cover hand (applicant) assignment on (date) to (covering) operator, who must be free that day
+	Cover(applicant Operator, date time.Time, covering Operator) error
+}
+
+// OnShift tell if operator (o) is assigned on (date) on (source) roster
+func OnShift(source Source, o Operator, date time.Time) (bool, error) {
+	_, err := source.Assignment(o, date)
+	if errors.Is(err, ErrNotAssigned) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Leg is an operator assignment taking part in a swap chain
+type Leg struct {
+	Operator Operator
+	Date     time.Time // Date of the assignment operator give away
+}
+
+// NewSource return the roster source selected by ROSTER_SOURCE env variable
+//
+// "sheets" (default) read and write the SHIFT_ID spreadsheet, "db" use the Postgres roster
+func NewSource(s *db.Service) (Source, error) {
+	return NewSourceByName(s, SourceName())
+}
+
+// SourceName return the roster source name selected by ROSTER_SOURCE env variable
+func SourceName() string {
+	if source := os.Getenv("ROSTER_SOURCE"); source != "" {
+		return source
+	}
+	return "sheets"
+}
+
+// NewSourceByName return the roster source called (name), one of "sheets" or "db"
+func NewSourceByName(s *db.Service, name string) (Source, error) {
+	switch source := name; source {
+	case "sheets":
+		return SheetSource{ctx: s.Context()}, nil
+	case "db":
+		return DBSource{service: *s}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown roster source: %v", source))
+	}
+}
+
+// SheetSource is the roster kept in the weekly tabs of SHIFT_ID spreadsheet
+type SheetSource struct {
+	ctx context.Context // Context spreadsheet calls are cancelled with
+}
+
+func (src SheetSource) Assignment(o Operator, date time.Time) (Assignment, error) {
+	// Gsheet service
+	srv := gsuite.Service{}
+	err := srv.New(os.Getenv("SHIFT_ID"))
+	if err != nil {
+		return Assignment{}, errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
+	}
+	srv = srv.WithContext(src.ctx)
+
+	// Retrieve day coordinates
+	dayCoord := gsuite.DayCoord{}
+	err = dayCoord.Load(srv)
+	if err != nil {
+		return Assignment{}, fmt.Errorf("error retrieving day coordinates: %w", err)
+	}
+
+	// Retrieve day shift
+	day, err := srv.ReadDay(dayCoord, date)
+	if err != nil {
+		return Assignment{}, fmt.Errorf("cannot retrieve requested shift, no shift found: %w", err)
+	}
+
+	// Retrieve operator roles
+	roles, err := srv.ForDay(dayCoord, date).GetOperatorRoles(day, o.Labels()...)
+	if errors.Is(err, gsuite.ErrOperatorNotFound) {
+		return Assignment{}, ErrNotAssigned
+	}
+	if err != nil {
+		return Assignment{}, fmt.Errorf("cannot retrieve requested roles, operator not found: %w", err)
+	}
+
+	return ParseRoles(roles)
+}
+
+func (src SheetSource) Swap(first Operator, firstDate time.Time, second Operator, secondDate time.Time) error {
+	var (
+		sheetService gsuite.Service
+		sc           gsuite.ShiftsToSwitch
+	)
+
+	err := sheetService.New(os.Getenv("SHIFT_ID"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
+	}
+	err = sc.New(sheetService.WithContext(src.ctx))
+	if err != nil {
+		return fmt.Errorf("error creating shift change service: %w", err)
+	}
+
+	sc.FirstName = first.Label
+	sc.FirstAliases = first.Aliases
+	sc.FirstDate = firstDate
+	sc.SecondName = second.Label
+	sc.SecondAliases = second.Aliases
+	sc.SecondDate = secondDate
+	return sc.SwitchShifts()
+}
+
+func (src SheetSource) Rotate(legs []Leg) error {
+	var (
+		sheetService gsuite.Service
+		sc           gsuite.ShiftChain
+	)
+
+	err := sheetService.New(os.Getenv("SHIFT_ID"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
+	}
+	err = sc.New(sheetService.WithContext(src.ctx))
+	if err != nil {
+		return fmt.Errorf("error creating shift chain service: %w", err)
+	}
+
+	for _, leg := range legs {
+		sc.Legs = append(sc.Legs, gsuite.ChainLeg{Name: leg.Operator.Label, Aliases: leg.Operator.Aliases, Date: leg.Date})
+	}
+	return sc.Rotate()
+}
+
+func (src SheetSource) Cover(applicant Operator, date time.Time, covering Operator) error {
+	var (
+		sheetService gsuite.Service
+		sc           gsuite.ShiftCover
+	)
+
+	err := sheetService.New(os.Getenv("SHIFT_ID"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error creating gsheet service: %v\n", err))
+	}
+	err = sc.New(sheetService.WithContext(src.ctx))
+	if err != nil {
+		return fmt.Errorf("error creating shift cover service: %w", err)
+	}
+
+	sc.ApplicantName = applicant.Label
+	sc.ApplicantAliases = applicant.Aliases
+	sc.Date = date
+	sc.CoveringName = covering.Label
+	sc.CoveringAliases = covering.Aliases
+	return sc.Cover()
+}
+
+// DBSource is the roster kept in Postgres roster_assignments table
+type DBSource struct {
+	service db.Service
+}
+
+func (d DBSource) Assignment(o Operator, date time.Time) (Assignment, error) {
+	a := db.RosterAssignment{}
+	a.New(d.service)
+	err := a.Get(o.Id, date)
+	if errors.Is(err, db.ErrNoAssignment) {
+		return Assignment{}, ErrNotAssigned
+	}
+	if err != nil {
+		return Assignment{}, errors.New(fmt.Sprintf("cannot retrieve requested shift, no shift found: %v\n", err))
+	}
+
+	return Assignment{
+		Location: a.Location,
+		Shift:    a.Shift,
+		Vehicle:  a.Vehicle,
+		Role:     a.Role,
+	}, nil
+}
+
+func (d DBSource) Swap(first Operator, firstDate time.Time, second Operator, secondDate time.Time) error {
+	s := db.RosterSwap{}
+	s.New(d.service)
+	s.FirstOperator = first.Id
+	s.FirstDate = firstDate
+	s.SecondOperator = second.Id
+	s.SecondDate = secondDate
+	return s.Apply()
+}
+
+func (d DBSource) Rotate(legs []Leg) error {
+	r := db.RosterRotation{}
+	r.New(d.service)
+	for _, leg := range legs {
+		r.Legs = append(r.Legs, db.SwapChainLeg{Operator: leg.Operator.Id, Date: leg.Date})
+	}
+	return r.Apply()
+}
+
+func (d DBSource) Cover(applicant Operator, date time.Time, covering Operator) error {
+	c := db.RosterCover{}
+	c.New(d.service)
+	c.Applicant = applicant.Id
+	c.Date = date
+	c.Covering = covering.Id
+	return c.Apply()
+}
+
+// ParseRoles split a spreadsheet roles cell (location|shift|vehicle|role) in its components
+func ParseRoles(cell string) (Assignment, error) {
+	split := strings.Split(cell, "|")
+	if len(split) != 4 {
+		return Assignment{}, errors.New(fmt.Sprintf("malformed roles cell: %q", cell))
+	}
+
+	return Assignment{
+		Location: split[0],
+		Shift:    split[1],
+		Vehicle:  split[2],
+		Role:     split[3],
+	}, nil
+}
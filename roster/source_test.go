@@ -0,0 +1,73 @@
+package roster
+
+import (
+	"reflect"
+	"shift-manager/gsuite"
+	"testing"
+	"time"
+)
+
+func TestParseRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		cell    string
+		want    Assignment
+		wantErr bool
+	}{
+		{
+			name: "Complete cell",
+			cell: "Sede|Mattino|MSB1|Autista",
+			want: Assignment{Location: "Sede", Shift: "Mattino", Vehicle: "MSB1", Role: "Autista"},
+		},
+		{
+			name: "Empty vehicle",
+			cell: "Sede|Notte||Centralino",
+			want: Assignment{Location: "Sede", Shift: "Notte", Role: "Centralino"},
+		},
+		{
+			name:    "Missing fields",
+			cell:    "Sede|Notte",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoles(tt.cell)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRoles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoles() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnShift(t *testing.T) {
+	defer func() { gsuite.DefaultBackend = nil }()
+	_, source := newSagaRoster(t)
+
+	tests := []struct {
+		name     string
+		operator Operator
+		date     time.Time
+		want     bool
+		wantErr  bool
+	}{
+		{name: "On shift", operator: sagaRossi, date: sagaMonday, want: true},
+		{name: "Free", operator: sagaVerdi, date: sagaMonday},
+		{name: "Roster unreadable", operator: sagaRossi, date: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OnShift(source, tt.operator, tt.date)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OnShift() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("OnShift() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
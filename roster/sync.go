@@ -0,0 +1,307 @@
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"shift-manager/db"
+	"shift-manager/gsuite"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sync actions decided for a single cell
+const (
+	syncNone     = iota // Both sides agree
+	syncToSheet         // DB changed, write DB value to spreadsheet
+	syncToDB            // Spreadsheet changed, apply spreadsheet value to DB
+	syncConflict        // Both sides changed, manager must choose
+)
+
+// SyncReport summarize a sync run
+type SyncReport struct {
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	ToSheet   int               `json:"to_sheet"` // Cells written to spreadsheet
+	ToDB      int               `json:"to_db"`    // Cells applied to DB roster
+	Conflicts []db.SyncConflict `json:"conflicts"`
+	Issues    []ImportIssue     `json:"issues"` // Spreadsheet edits that couldn't be applied to DB
+}
+
+// Syncer keep DB roster and SHIFT_ID spreadsheet in sync
+//
+// Every cell value is compared with the value it had at last sync:
+// a change on a single side is propagated to the other one, a change on both sides is recorded as conflict.
+type Syncer struct {
+	importer Importer // Catalogs, day coordinates and roles sheet used to turn spreadsheet cells into assignments
+}
+
+// sheetDay is a roster day as read from spreadsheet
+type sheetDay struct {
+	values    map[string]string // Roster coordinate -> normalized value
+	positions map[string][2]int // Roster coordinate -> zero based row and column inside day block
+}
+
+// New prepare the syncer loading day coordinates, roles mirror sheet and DB catalogs
+func (s *Syncer) New(service db.Service, sheet gsuite.Service) error {
+	return s.importer.New(service, sheet)
+}
+
+// Sync reconcile every day from (from) to (to) included
+func (s *Syncer) Sync(from time.Time, to time.Time) (SyncReport, error) {
+	report := SyncReport{From: from, To: to}
+	if to.Before(from) {
+		return report, errors.New("sync interval end is before start")
+	}
+
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if err := s.syncDay(date, &report); err != nil {
+			return report, errors.New(fmt.Sprintf("error syncing %s: %v\n", date.Format("2006-01-02"), err))
+		}
+	}
+	return report, nil
+}
+
+// syncDay reconcile a single day adding results to (report)
+func (s *Syncer) syncDay(date time.Time, report *SyncReport) error {
+	i := &s.importer
+
+	sheet, err := s.readSheetDay(date)
+	if err != nil {
+		return err
+	}
+	dbValues, err := s.readDBDay(date)
+	if err != nil {
+		return err
+	}
+
+	// Retrieve last synced values
+	var (
+		snapshot  db.SyncCell
+		snapshots []db.SyncCell
+	)
+	snapshot.New(i.service)
+	if err = snapshot.GetAllByDate(date, &snapshots); err != nil {
+		return err
+	}
+	base := map[string]string{}
+	for _, cell := range snapshots {
+		base[cell.Cell] = cell.Value
+	}
+
+	var (
+		toSheet []gsuite.CellToUpdate
+		synced  []db.SyncCell
+	)
+	for _, coord := range unionKeys(sheet.values, dbValues, base) {
+		sheetValue, dbValue := sheet.values[coord], dbValues[coord]
+		baseValue, hasBase := base[coord]
+
+		switch reconcile(baseValue, hasBase, sheetValue, dbValue) {
+		case syncNone:
+			if baseValue != sheetValue {
+				synced = append(synced, db.SyncCell{Date: date, Cell: coord, Value: sheetValue})
+			}
+		case syncToSheet:
+			toSheet = append(toSheet, gsuite.CellToUpdate{Range: coord, Value: dbValue, SheetId: i.dayCoord.Spreadsheet(date)})
+			synced = append(synced, db.SyncCell{Date: date, Cell: coord, Value: dbValue})
+		case syncToDB:
+			if issue := s.applyToDB(date, coord, sheetValue, sheet.positions[coord]); issue != nil {
+				report.Issues = append(report.Issues, *issue)
+				continue
+			}
+			synced = append(synced, db.SyncCell{Date: date, Cell: coord, Value: sheetValue})
+			report.ToDB++
+		case syncConflict:
+			conflict := db.SyncConflict{
+				Date:       date,
+				Cell:       coord,
+				BaseValue:  baseValue,
+				SheetValue: sheetValue,
+				DbValue:    dbValue,
+			}
+			conflict.New(i.service)
+			if err = conflict.Open(); err != nil {
+				return err
+			}
+			report.Conflicts = append(report.Conflicts, conflict)
+		}
+	}
+
+	// Write DB changes to spreadsheet in a single batch
+	if len(toSheet) > 0 {
+		if err = i.sheet.BatchUpdateCells(toSheet); err != nil {
+			return errors.New(fmt.Sprintf("error writing spreadsheet: %v\n", err))
+		}
+		report.ToSheet += len(toSheet)
+	}
+
+	// Remember synced values for next run
+	for _, cell := range synced {
+		cell.New(i.service)
+		if err = cell.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve settle conflict (conflictId) keeping current value of (keep) side, one of "sheet" or "db"
+//
+// (manager) is the user UUID of who resolved the conflict
+func (s *Syncer) Resolve(conflictId string, keep string, manager string) error {
+	i := &s.importer
+
+	conflict := db.SyncConflict{}
+	conflict.New(i.service)
+	if err := conflict.GetById(conflictId); err != nil {
+		return err
+	}
+	if conflict.Status != "open" {
+		return errors.New("conflict already resolved")
+	}
+
+	var value string
+	switch keep {
+	case "sheet":
+		sheet, err := s.readSheetDay(conflict.Date)
+		if err != nil {
+			return err
+		}
+		value = sheet.values[conflict.Cell]
+		if issue := s.applyToDB(conflict.Date, conflict.Cell, value, sheet.positions[conflict.Cell]); issue != nil {
+			return errors.New(fmt.Sprintf("cannot apply spreadsheet value %q to DB: %s", issue.Value, issue.Kind))
+		}
+	case "db":
+		dbValues, err := s.readDBDay(conflict.Date)
+		if err != nil {
+			return err
+		}
+		value = dbValues[conflict.Cell]
+		err = i.sheet.BatchUpdateCells([]gsuite.CellToUpdate{
+			{Range: conflict.Cell, Value: value, SheetId: i.dayCoord.Spreadsheet(conflict.Date)},
+		})
+		if err != nil {
+			return errors.New(fmt.Sprintf("error writing spreadsheet: %v\n", err))
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown conflict resolution: %v", keep))
+	}
+
+	cell := db.SyncCell{Date: conflict.Date, Cell: conflict.Cell, Value: value}
+	cell.New(i.service)
+	if err := cell.Save(); err != nil {
+		return err
+	}
+
+	conflict.Resolution = keep
+	conflict.Manager = manager
+	return conflict.Resolve()
+}
+
+// readSheetDay read (date) day block from spreadsheet
+func (s *Syncer) readSheetDay(date time.Time) (sheetDay, error) {
+	i := &s.importer
+	res := sheetDay{values: map[string]string{}, positions: map[string][2]int{}}
+
+	day, err := i.sheet.ReadDay(i.dayCoord, date)
+	if err != nil {
+		return res, errors.New(fmt.Sprintf("error reading spreadsheet: %v\n", err))
+	}
+	if err = i.loadRoles(date); err != nil {
+		return res, err
+	}
+	for rowIndex, row := range day {
+		for colIndex, cell := range row {
+			coord := i.dayCoord.CellAt(date, rowIndex, colIndex)
+			// Aliases of the same operator compare equal
+			res.values[coord] = i.directory.Canonical(fmt.Sprint(cell))
+			res.positions[coord] = [2]int{rowIndex, colIndex}
+		}
+	}
+	return res, nil
+}
+
+// readDBDay read (date) assignments from DB roster, keyed by mirrored spreadsheet cell
+func (s *Syncer) readDBDay(date time.Time) (map[string]string, error) {
+	i := &s.importer
+
+	var (
+		assignment  db.RosterAssignment
+		assignments []db.RosterAssignment
+	)
+	assignment.New(i.service)
+	if err := assignment.GetAllByDate(date, &assignments); err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+	for _, a := range assignments {
+		if a.Cell != "" {
+			res[a.Cell] = i.directory.Label(a.Operator)
+		}
+	}
+	return res, nil
+}
+
+// applyToDB write spreadsheet (value) found at (coord) to DB roster, an empty value remove the assignment
+func (s *Syncer) applyToDB(date time.Time, coord string, value string, position [2]int) *ImportIssue {
+	i := &s.importer
+
+	if value == "" {
+		assignment := db.RosterAssignment{}
+		assignment.New(i.service)
+		if err := assignment.DeleteByCell(date, coord); err != nil {
+			return &ImportIssue{Kind: IssueSaveError, Date: date, Cell: coord, Value: err.Error()}
+		}
+		return nil
+	}
+
+	assignment, issue := i.assignmentAt(date, position[0], position[1], value)
+	if issue != nil {
+		return issue
+	}
+	assignment.New(i.service)
+	if err := assignment.Save(); err != nil {
+		return &ImportIssue{Kind: IssueSaveError, Date: date, Cell: coord, Value: err.Error()}
+	}
+	return nil
+}
+
+// reconcile decide how to bring (sheet) and (dbValue) back in sync given last synced (base) value
+func reconcile(base string, hasBase bool, sheet string, dbValue string) int {
+	switch {
+	case sheet == dbValue:
+		return syncNone
+	case !hasBase:
+		// Never synced and sides disagree, no way to tell who's right
+		return syncConflict
+	case sheet == base:
+		return syncToSheet
+	case dbValue == base:
+		return syncToDB
+	default:
+		return syncConflict
+	}
+}
+
+// normalizeLabel make operator labels comparable, spreadsheet values are always written uppercase
+func normalizeLabel(v string) string {
+	return strings.ToUpper(strings.TrimSpace(v))
+}
+
+// unionKeys return sorted keys found in any of passed maps
+func unionKeys(maps ...map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
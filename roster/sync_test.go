@@ -0,0 +1,31 @@
+package roster
+
+import "testing"
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		hasBase bool
+		sheet   string
+		dbValue string
+		want    int
+	}{
+		{"Unchanged", "ROSSI", true, "ROSSI", "ROSSI", syncNone},
+		{"Same edit on both sides", "ROSSI", true, "NERI", "NERI", syncNone},
+		{"Edited on DB", "ROSSI", true, "ROSSI", "NERI", syncToSheet},
+		{"Cleared on DB", "ROSSI", true, "ROSSI", "", syncToSheet},
+		{"Edited on sheet", "ROSSI", true, "NERI", "ROSSI", syncToDB},
+		{"Filled on sheet", "", true, "NERI", "", syncToDB},
+		{"Edited on both sides", "ROSSI", true, "NERI", "VERDI", syncConflict},
+		{"Never synced and different", "", false, "NERI", "VERDI", syncConflict},
+		{"Never synced and equal", "", false, "NERI", "NERI", syncNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reconcile(tt.base, tt.hasBase, tt.sheet, tt.dbValue); got != tt.want {
+				t.Errorf("reconcile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
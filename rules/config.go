@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Config tune the swap eligibility rules, loaded from the JSON file in SWAP_RULES, see rules.example.json
+//
+// A rule is disabled by its zero threshold, or by an empty driver_roles for the driver rule. Enabled rules
+// must have the settings they need, see Validate
+type Config struct {
+	MinRestHours         float64              `json:"min_rest_hours"`         // Minimum rest between two shifts, 0 to disable
+	MaxConsecutiveNights int                  `json:"max_consecutive_nights"` // Night shifts allowed in a row, 0 to disable
+	Shifts               map[string]ShiftTime `json:"shifts"`                 // Shift name -> working hours
+	NightShifts          []string             `json:"night_shifts"`           // Shift names counted as nights
+	DriverRoles          []string             `json:"driver_roles"`           // Role names requiring a driving licence
+	DriverCertificate    string               `json:"driver_certificate"`     // Certificate type name of the driving licence
+}
+
+// ShiftTime is the working hours of a shift, a shift ending before it starts end the following day
+type ShiftTime struct {
+	Start string `json:"start"` // 15:04 format
+	End   string `json:"end"`   // 15:04 format
+}
+
+// CurrentConfig is the configuration loaded at startup, when nil DefaultConfig is used
+var CurrentConfig *Config
+
+// ActiveConfig return the configuration in use
+func ActiveConfig() *Config {
+	if CurrentConfig != nil {
+		return CurrentConfig
+	}
+	return DefaultConfig()
+}
+
+// DefaultConfig return the labour limits every roster must respect, with the standard shifts and driver role.
+// Rosters with other shift or role names need a SWAP_RULES file
+func DefaultConfig() *Config {
+	return &Config{
+		MinRestHours:         11,
+		MaxConsecutiveNights: 2,
+		Shifts: map[string]ShiftTime{
+			"Mattino":    {Start: "07:00", End: "14:00"},
+			"Pomeriggio": {Start: "14:00", End: "21:00"},
+			"Notte":      {Start: "21:00", End: "07:00"},
+		},
+		NightShifts:       []string{"Notte"},
+		DriverRoles:       []string{"Autista"},
+		DriverCertificate: "Patente",
+	}
+}
+
+// LoadConfig read and validate a JSON rules configuration from (path)
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error reading rules file: %v\n", err))
+	}
+
+	var c Config
+	if err = json.Unmarshal(content, &c); err != nil {
+		return nil, errors.New(fmt.Sprintf("error parsing rules file: %v\n", err))
+	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate check limits are not negative, shift hours are well formed and every enabled rule has the
+// settings it need, so a rule is never silently skipped
+func (c *Config) Validate() error {
+	if c.MinRestHours < 0 || c.MaxConsecutiveNights < 0 {
+		return errors.New("rules limits can't be negative")
+	}
+	if c.MinRestHours > 0 && len(c.Shifts) == 0 {
+		return errors.New("min_rest_hours set without shifts hours")
+	}
+	if c.MaxConsecutiveNights > 0 && len(c.NightShifts) == 0 {
+		return errors.New("max_consecutive_nights set without night_shifts")
+	}
+	if len(c.DriverRoles) > 0 && strings.TrimSpace(c.DriverCertificate) == "" {
+		return errors.New("driver_roles set without driver_certificate")
+	}
+	for name, shift := range c.Shifts {
+		if _, _, err := shift.span(time.Time{}); err != nil {
+			return errors.New(fmt.Sprintf("malformed hours of shift %q: %v", name, err))
+		}
+	}
+	return nil
+}
+
+// span return shift start and end on (day)
+func (s ShiftTime) span(day time.Time) (time.Time, time.Time, error) {
+	start, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.Parse("15:04", s.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	y, m, d := day.Date()
+	from := time.Date(y, m, d, start.Hour(), start.Minute(), 0, 0, day.Location())
+	to := time.Date(y, m, d, end.Hour(), end.Minute(), 0, 0, day.Location())
+	if !to.After(from) {
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
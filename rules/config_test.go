@@ -0,0 +1,45 @@
+package rules
+
+import "testing"
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig("../rules.example.json")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(NewEngine(c, fakeCertificates{}).Rules) != 3 {
+		t.Errorf("NewEngine() rules = %v, want every rule enabled", NewEngine(c, fakeCertificates{}).Rules)
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	c := DefaultConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(NewEngine(c, fakeCertificates{}).Rules) != 3 {
+		t.Errorf("NewEngine() rules = %v, want rest, nights and driver rules enabled", NewEngine(c, fakeCertificates{}).Rules)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"Empty", Config{}, false},
+		{"Negative rest", Config{MinRestHours: -1}, true},
+		{"Rest without shifts", Config{MinRestHours: 11}, true},
+		{"Nights without night shifts", Config{MaxConsecutiveNights: 2}, true},
+		{"Driver roles without certificate", Config{DriverRoles: []string{"Autista"}}, true},
+		{"Malformed hours", Config{Shifts: map[string]ShiftTime{"Notte": {Start: "21", End: "07:00"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
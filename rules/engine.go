@@ -0,0 +1,203 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"shift-manager/roster"
+	"sort"
+	"time"
+)
+
+// Handover is a roster assignment moving from an operator to another, swaps are made of handovers:
+// a two party swap is two handovers, a chain one per leg and a cover a single one
+type Handover struct {
+	From roster.Operator // Operator giving the assignment away
+	To   roster.Operator // Operator taking it
+	Date time.Time       // Assignment date
+}
+
+// ChainHandovers return the handovers of a swap chain made of (legs): every leg assignment move to the
+// previous leg operator, the first one to the last leg operator
+func ChainHandovers(legs []roster.Leg) []Handover {
+	var res []Handover
+	for i, leg := range legs {
+		previous := legs[(i+len(legs)-1)%len(legs)]
+		res = append(res, Handover{From: leg.Operator, To: previous.Operator, Date: leg.Date})
+	}
+	return res
+}
+
+// Shift is an assignment on an operator schedule
+type Shift struct {
+	Date       time.Time
+	Assignment roster.Assignment
+	Changed    bool // Taken with the swap being evaluated
+}
+
+// Schedule is an operator post-swap roster around swapped dates, sorted by date
+type Schedule struct {
+	Operator string // User UUID
+	Shifts   []Shift
+	Removed  []time.Time // Dates the operator gave away
+}
+
+// Violation is a rule broken by a swap
+type Violation struct {
+	Rule     string      `json:"rule"`
+	Operator string      `json:"operator"` // User UUID
+	Dates    []time.Time `json:"dates"`    // Dates of the shifts breaking the rule
+	Message  string      `json:"message"`
+}
+
+// Rule check an operator schedule, returning the violations found
+type Rule interface {
+	Name() string
+	Check(s Schedule) []Violation
+}
+
+// Engine evaluate swaps against its rules, add rules to Rules to extend it
+type Engine struct {
+	Rules  []Rule
+	Window int // Days around swapped dates read from roster
+}
+
+// NewEngine return an engine with the default rules tuned by (c), driving licences are checked on (certificates)
+func NewEngine(c *Config, certificates Certificates) Engine {
+	e := Engine{Window: 1}
+	if c.MinRestHours > 0 {
+		e.Rules = append(e.Rules, RestRule{MinRest: time.Duration(c.MinRestHours * float64(time.Hour)), Shifts: c.Shifts})
+	}
+	if c.MaxConsecutiveNights > 0 {
+		e.Rules = append(e.Rules, NightRule{Max: c.MaxConsecutiveNights, NightShifts: c.NightShifts})
+		if c.MaxConsecutiveNights+1 > e.Window {
+			e.Window = c.MaxConsecutiveNights + 1
+		}
+	}
+	if len(c.DriverRoles) > 0 {
+		e.Rules = append(e.Rules, DriverRule{DriverRoles: c.DriverRoles, Certificate: c.DriverCertificate, Certificates: certificates})
+	}
+	return e
+}
+
+// Evaluate check the post-swap roster of every operator involved in (handovers), reading it from (source)
+//
+// Only violations involving a swapped shift are returned, a roster already breaking a rule doesn't block swaps
+func (e Engine) Evaluate(source roster.Source, handovers []Handover) ([]Violation, error) {
+	if len(e.Rules) == 0 {
+		return nil, nil
+	}
+
+	// Assignments moving with the swap, by receiving operator
+	taken := map[string][]Shift{}
+	given := map[string][]time.Time{}
+	operators := map[string]roster.Operator{}
+	var ids []string
+	for _, h := range handovers {
+		a, err := source.Assignment(h.From, h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("cannot retrieve %s assignment on %s: %w", h.From.Id, h.Date.Format("2006-01-02"), err)
+		}
+		taken[h.To.Id] = append(taken[h.To.Id], Shift{Date: h.Date, Assignment: a, Changed: true})
+		given[h.From.Id] = append(given[h.From.Id], h.Date)
+		for _, o := range []roster.Operator{h.From, h.To} {
+			if _, ok := operators[o.Id]; !ok {
+				operators[o.Id] = o
+				ids = append(ids, o.Id)
+			}
+		}
+	}
+
+	var violations []Violation
+	for _, id := range ids {
+		schedule, err := e.schedule(source, operators[id], taken[id], given[id])
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range e.Rules {
+			for _, v := range rule.Check(schedule) {
+				if involved(v, schedule) {
+					violations = append(violations, v)
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+// schedule build operator (o) roster around (taken) and (given) dates, as it would be after the swap
+func (e Engine) schedule(source roster.Source, o roster.Operator, taken []Shift, given []time.Time) (Schedule, error) {
+	s := Schedule{Operator: o.Id, Removed: given}
+
+	// Dates read from roster, minus the swapped ones
+	skip := map[string]bool{}
+	days := map[string]time.Time{}
+	for _, d := range given {
+		skip[day(d)] = true
+	}
+	for _, shift := range taken {
+		skip[day(shift.Date)] = true
+		s.Shifts = append(s.Shifts, shift)
+	}
+	for _, d := range append(given, dates(taken)...) {
+		for offset := -e.Window; offset <= e.Window; offset++ {
+			around := d.AddDate(0, 0, offset)
+			if !skip[day(around)] {
+				days[day(around)] = around
+			}
+		}
+	}
+
+	for _, d := range days {
+		a, err := source.Assignment(o, d)
+		if errors.Is(err, roster.ErrNotAssigned) {
+			continue
+		}
+		if err != nil {
+			// A roster that can't be read would hide violations
+			return Schedule{}, fmt.Errorf("cannot retrieve %s assignment on %s: %w", o.Id, day(d), err)
+		}
+		s.Shifts = append(s.Shifts, Shift{Date: d, Assignment: a})
+	}
+
+	sort.Slice(s.Shifts, func(i, j int) bool { return s.Shifts[i].Date.Before(s.Shifts[j].Date) })
+	return s, nil
+}
+
+// involved tell if violation (v) involve a shift changed by the swap
+func involved(v Violation, s Schedule) bool {
+	changed := map[string]bool{}
+	for _, shift := range s.Shifts {
+		if shift.Changed {
+			changed[day(shift.Date)] = true
+		}
+	}
+	for _, d := range s.Removed {
+		changed[day(d)] = true
+	}
+	for _, d := range v.Dates {
+		if changed[day(d)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Justify check a manager override justification, violations can only be overridden with a reason
+func Justify(violations []Violation, justification string) error {
+	if len(violations) > 0 && justification == "" {
+		return errors.New("swap breaks eligibility rules, a justification is required to override them")
+	}
+	return nil
+}
+
+func dates(shifts []Shift) []time.Time {
+	var res []time.Time
+	for _, s := range shifts {
+		res = append(res, s.Date)
+	}
+	return res
+}
+
+func day(t time.Time) string {
+	return t.Format("2006-01-02")
+}
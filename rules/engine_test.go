@@ -0,0 +1,213 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"shift-manager/roster"
+	"testing"
+	"time"
+)
+
+// fakeSource is a roster held in memory, operator UUID -> date -> assignment
+type fakeSource map[string]map[string]roster.Assignment
+
+func (f fakeSource) Assignment(o roster.Operator, date time.Time) (roster.Assignment, error) {
+	a, ok := f[o.Id][day(date)]
+	if !ok {
+		return roster.Assignment{}, roster.ErrNotAssigned
+	}
+	return a, nil
+}
+
+func (f fakeSource) Swap(roster.Operator, time.Time, roster.Operator, time.Time) error { return nil }
+func (f fakeSource) Rotate([]roster.Leg) error                                         { return nil }
+func (f fakeSource) Cover(roster.Operator, time.Time, roster.Operator) error           { return nil }
+
+func date(d int) time.Time {
+	return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func testConfig() *Config {
+	return &Config{
+		MinRestHours:         11,
+		MaxConsecutiveNights: 2,
+		Shifts: map[string]ShiftTime{
+			"Mattino":    {Start: "07:00", End: "14:00"},
+			"Pomeriggio": {Start: "14:00", End: "21:00"},
+			"Notte":      {Start: "21:00", End: "07:00"},
+		},
+		NightShifts:       []string{"Notte"},
+		DriverRoles:       []string{"Autista"},
+		DriverCertificate: "Patente",
+	}
+}
+
+// fakeCertificates is the list of operator UUIDs holding every certificate
+type fakeCertificates []string
+
+func (f fakeCertificates) Holds(operator string, certificate string, date time.Time) (bool, error) {
+	for _, id := range f {
+		if id == operator {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// licensed hold the driving licence in tests
+var licensed = fakeCertificates{"rossi", "bianchi", "verdi"}
+
+func TestEngine_Evaluate(t *testing.T) {
+	mattino := roster.Assignment{Location: "Sede", Shift: "Mattino", Vehicle: "MSB1", Role: "Soccorritore"}
+	notte := roster.Assignment{Location: "Sede", Shift: "Notte", Vehicle: "MSB2", Role: "Soccorritore"}
+	autista := roster.Assignment{Location: "Sede", Shift: "Pomeriggio", Vehicle: "MSB1", Role: "Autista"}
+
+	rossi := roster.Operator{Id: "rossi"}
+	bianchi := roster.Operator{Id: "bianchi"}
+	neri := roster.Operator{Id: "neri"} // Not licensed
+
+	tests := []struct {
+		name      string
+		source    fakeSource
+		handovers []Handover
+		want      []string // Violated rules
+	}{
+		{
+			name: "No violations",
+			source: fakeSource{
+				"rossi":   {"2020-01-06": mattino},
+				"bianchi": {"2020-01-08": mattino},
+			},
+			handovers: []Handover{{rossi, bianchi, date(6)}, {bianchi, rossi, date(8)}},
+		},
+		{
+			name: "Morning after a night",
+			source: fakeSource{
+				"rossi":   {"2020-01-06": notte, "2020-01-09": mattino},
+				"bianchi": {"2020-01-07": mattino},
+			},
+			handovers: []Handover{{rossi, bianchi, date(9)}, {bianchi, rossi, date(7)}},
+			want:      []string{"min_rest"},
+		},
+		{
+			name: "Three nights in a row",
+			source: fakeSource{
+				"rossi":   {"2020-01-06": notte, "2020-01-07": notte, "2020-01-10": mattino},
+				"bianchi": {"2020-01-08": notte},
+			},
+			handovers: []Handover{{rossi, bianchi, date(10)}, {bianchi, rossi, date(8)}},
+			want:      []string{"consecutive_nights"},
+		},
+		{
+			name: "Unlicensed driver",
+			source: fakeSource{
+				"rossi": {"2020-01-06": autista},
+			},
+			handovers: []Handover{{rossi, neri, date(6)}},
+			want:      []string{"driver_licence"},
+		},
+		{
+			name: "Violation not involving swapped shifts",
+			source: fakeSource{
+				"rossi":   {"2020-01-06": notte, "2020-01-07": mattino, "2020-01-12": mattino},
+				"bianchi": {"2020-01-14": mattino},
+			},
+			handovers: []Handover{{rossi, bianchi, date(12)}, {bianchi, rossi, date(14)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := NewEngine(testConfig(), licensed).Evaluate(tt.source, tt.handovers)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			var got []string
+			for _, v := range violations {
+				got = append(got, v.Rule)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Evaluate() violations = %+v, want %v", violations, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Evaluate() violation %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// unreadableSource is a roster whose days around swapped ones can't be read
+type unreadableSource struct {
+	fakeSource
+}
+
+func (u unreadableSource) Assignment(o roster.Operator, date time.Time) (roster.Assignment, error) {
+	if a, ok := u.fakeSource[o.Id][day(date)]; ok {
+		return a, nil
+	}
+	return roster.Assignment{}, errors.New("connection reset")
+}
+
+func TestEngine_EvaluateUnreadableRoster(t *testing.T) {
+	source := unreadableSource{fakeSource{"rossi": {"2020-01-06": {Location: "Sede", Shift: "Notte", Role: "Soccorritore"}}}}
+	_, err := NewEngine(testConfig(), licensed).Evaluate(source, []Handover{{roster.Operator{Id: "rossi"}, roster.Operator{Id: "neri"}, date(6)}})
+	if err == nil {
+		t.Errorf("Evaluate() expected error, roster around handover can't be read")
+	}
+}
+
+func TestChainHandovers(t *testing.T) {
+	rossi := roster.Operator{Id: "rossi"}
+	bianchi := roster.Operator{Id: "bianchi"}
+	neri := roster.Operator{Id: "neri"}
+
+	got := ChainHandovers([]roster.Leg{{Operator: rossi, Date: date(6)}, {Operator: bianchi, Date: date(7)}, {Operator: neri, Date: date(8)}})
+	want := []Handover{{rossi, neri, date(6)}, {bianchi, rossi, date(7)}, {neri, bianchi, date(8)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChainHandovers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEngine_EvaluateMissingAssignment(t *testing.T) {
+	_, err := NewEngine(testConfig(), licensed).Evaluate(fakeSource{}, []Handover{{roster.Operator{Id: "rossi"}, roster.Operator{Id: "neri"}, date(6)}})
+	if err == nil {
+		t.Errorf("Evaluate() expected error, handed over assignment doesn't exist")
+	}
+}
+
+// fakeQualifications map operator UUID -> role -> missing certificates
+type fakeQualifications map[string]map[string][]string
+
+func (f fakeQualifications) Missing(operator string, role string, vehicle string, date time.Time) ([]string, error) {
+	return f[operator][role], nil
+}
+
+func TestQualificationRule(t *testing.T) {
+	autista := roster.Assignment{Location: "Sede", Shift: "Mattino", Vehicle: "MSB1", Role: "Autista"}
+	source := fakeSource{
+		"rossi": {"2020-01-06": autista},
+		"neri":  {"2020-01-07": autista},
+	}
+	engine := Engine{
+		Rules: []Rule{QualificationRule{Qualifications: fakeQualifications{
+			"neri": {"Autista": {"Patente 4"}},
+		}}},
+		Window: 1,
+	}
+
+	// Neri lacks the licence for Rossi seat, Rossi is qualified for Neri seat
+	violations, err := engine.Evaluate(source, []Handover{
+		{roster.Operator{Id: "rossi"}, roster.Operator{Id: "neri"}, date(6)},
+		{roster.Operator{Id: "neri"}, roster.Operator{Id: "rossi"}, date(7)},
+	})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(violations) != 1 || violations[0].Operator != "neri" || !violations[0].Dates[0].Equal(date(6)) {
+		t.Fatalf("Evaluate() violations = %+v, want neri unqualified on 2020-01-06", violations)
+	}
+	if want := "Autista seat on MSB1 2020-01-06 requires Patente 4"; violations[0].Message != want {
+		t.Errorf("Evaluate() message = %v, want %v", violations[0].Message, want)
+	}
+}
@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RestRule require a minimum rest between the end of a shift and the start of the next one
+type RestRule struct {
+	MinRest time.Duration
+	Shifts  map[string]ShiftTime // Shifts without hours are not checked
+}
+
+func (r RestRule) Name() string {
+	return "min_rest"
+}
+
+func (r RestRule) Check(s Schedule) []Violation {
+	var (
+		violations []Violation
+		previous   *Shift
+		prevEnd    time.Time
+	)
+	for i := range s.Shifts {
+		shift := &s.Shifts[i]
+		hours, ok := r.Shifts[shift.Assignment.Shift]
+		if !ok {
+			continue
+		}
+		start, end, err := hours.span(shift.Date)
+		if err != nil {
+			continue
+		}
+		if previous != nil && start.Sub(prevEnd) < r.MinRest {
+			violations = append(violations, Violation{
+				Rule:     r.Name(),
+				Operator: s.Operator,
+				Dates:    []time.Time{previous.Date, shift.Date},
+				Message: fmt.Sprintf("only %v rest between %s %s and %s %s, at least %v required",
+					start.Sub(prevEnd), previous.Assignment.Shift, day(previous.Date), shift.Assignment.Shift, day(shift.Date), r.MinRest),
+			})
+		}
+		previous, prevEnd = shift, end
+	}
+	return violations
+}
+
+// NightRule limit night shifts worked on consecutive days
+type NightRule struct {
+	Max         int
+	NightShifts []string
+}
+
+func (r NightRule) Name() string {
+	return "consecutive_nights"
+}
+
+func (r NightRule) Check(s Schedule) []Violation {
+	night := map[string]bool{}
+	for _, name := range r.NightShifts {
+		night[name] = true
+	}
+
+	var (
+		violations []Violation
+		run        []time.Time
+	)
+	flush := func() {
+		if len(run) > r.Max {
+			violations = append(violations, Violation{
+				Rule:     r.Name(),
+				Operator: s.Operator,
+				Dates:    run,
+				Message:  fmt.Sprintf("%d nights in a row from %s, at most %d allowed", len(run), day(run[0]), r.Max),
+			})
+		}
+		run = nil
+	}
+	for _, shift := range s.Shifts {
+		if !night[shift.Assignment.Shift] {
+			continue
+		}
+		if len(run) > 0 && day(run[len(run)-1].AddDate(0, 0, 1)) != day(shift.Date) {
+			flush()
+		}
+		run = append(run, shift.Date)
+	}
+	flush()
+	return violations
+}
+
+// Certificates tell if an operator hold a certificate valid on a date
+type Certificates interface {
+	Holds(operator string, certificate string, date time.Time) (bool, error)
+}
+
+// DriverRule require operators on driver roles to hold the driving licence certificate
+type DriverRule struct {
+	DriverRoles  []string
+	Certificate  string // Driving licence certificate type name
+	Certificates Certificates
+}
+
+func (r DriverRule) Name() string {
+	return "driver_licence"
+}
+
+func (r DriverRule) Check(s Schedule) []Violation {
+	var violations []Violation
+	for _, shift := range s.Shifts {
+		if !r.driver(shift.Assignment.Role) {
+			continue
+		}
+		ok, err := r.Certificates.Holds(s.Operator, r.Certificate, shift.Date)
+		if err != nil || !ok {
+			message := fmt.Sprintf("%s seat on %s %s without a licensed driver", shift.Assignment.Role, shift.Assignment.Vehicle, day(shift.Date))
+			if err != nil {
+				message = fmt.Sprintf("%s, cannot verify licence: %v", message, err)
+			}
+			violations = append(violations, Violation{
+				Rule:     r.Name(),
+				Operator: s.Operator,
+				Dates:    []time.Time{shift.Date},
+				Message:  message,
+			})
+		}
+	}
+	return violations
+}
+
+func (r DriverRule) driver(role string) bool {
+	for _, name := range r.DriverRoles {
+		if name == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Qualifications tell which certificates an operator lacks to take a role on a vehicle on a date
+type Qualifications interface {
+	Missing(operator string, role string, vehicle string, date time.Time) ([]string, error)
+}
+
+// QualificationRule require operators to hold the certificates their assignment role and vehicle require
+type QualificationRule struct {
+	Qualifications Qualifications
+}
+
+func (r QualificationRule) Name() string {
+	return "qualification"
+}
+
+func (r QualificationRule) Check(s Schedule) []Violation {
+	var violations []Violation
+	for _, shift := range s.Shifts {
+		// Operators are already assigned their other shifts, only swapped ones are checked
+		if !shift.Changed {
+			continue
+		}
+		missing, err := r.Qualifications.Missing(s.Operator, shift.Assignment.Role, shift.Assignment.Vehicle, shift.Date)
+		if err != nil {
+			violations = append(violations, Violation{
+				Rule:     r.Name(),
+				Operator: s.Operator,
+				Dates:    []time.Time{shift.Date},
+				Message:  fmt.Sprintf("%s seat on %s %s, cannot verify qualifications: %v", shift.Assignment.Role, shift.Assignment.Vehicle, day(shift.Date), err),
+			})
+			continue
+		}
+		if len(missing) > 0 {
+			violations = append(violations, Violation{
+				Rule:     r.Name(),
+				Operator: s.Operator,
+				Dates:    []time.Time{shift.Date},
+				Message:  fmt.Sprintf("%s seat on %s %s requires %s", shift.Assignment.Role, shift.Assignment.Vehicle, day(shift.Date), strings.Join(missing, ", ")),
+			})
+		}
+	}
+	return violations
+}
@@ -12,6 +12,11 @@ import (
 	"os"
 	"shift-manager/api"
 	"shift-manager/db"
+	"shift-manager/gsuite"
+	"shift-manager/outbox"
+	"shift-manager/roster"
+	"shift-manager/rules"
+	"time"
 )
 
 // -----------------------
@@ -68,6 +73,53 @@ func main() {
 	// Create a new db service to interact with Heroku's DB
 	dbService := db.Service{Db: dbConn}
 
+	// -----------------------
+	// Roster backend config
+	// -----------------------
+
+	// Local dev mode: serve spreadsheets from a JSON workbook instead of Google Sheets
+	if workbook := os.Getenv("MEMORY_WORKBOOK"); workbook != "" {
+		memoryBackend, err := gsuite.LoadMemoryBackend(workbook)
+		checkErrorAndPanic(err)
+		gsuite.DefaultBackend = memoryBackend
+		fmt.Printf("Using in memory workbook %v\n", workbook)
+	}
+
+	// Spreadsheet layout descriptor, legacy WEEKDAY_RANGE and ROLES_RANGE env variables are used if missing
+	if layoutFile := os.Getenv("ROSTER_LAYOUT"); layoutFile != "" {
+		layout, err := gsuite.LoadLayout(layoutFile)
+		checkErrorAndPanic(err)
+		gsuite.CurrentLayout = layout
+		fmt.Printf("Using roster layout %v\n", layoutFile)
+	}
+	checkErrorAndPanic(gsuite.ActiveLayout().Validate())
+
+	// Swap eligibility rules
+	if rulesFile := os.Getenv("SWAP_RULES"); rulesFile != "" {
+		config, err := rules.LoadConfig(rulesFile)
+		checkErrorAndPanic(err)
+		rules.CurrentConfig = config
+		fmt.Printf("Using swap rules %v\n", rulesFile)
+	} else {
+		fmt.Printf("SWAP_RULES not set, using default swap rules\n")
+	}
+	checkErrorAndPanic(rules.ActiveConfig().Validate())
+
+	// -----------------------
+	// Background jobs
+	// -----------------------
+
+	// Reconcile shift swaps left half-finished by a crash
+	go roster.StartSagaRecovery(&dbService, 5*time.Minute)
+
+	// Close change requests whose dates are past
+	go roster.StartChangeExpiry(&dbService, time.Hour)
+
+	// Deliver spreadsheet submissions stored in the outbox
+	outboxWorker := outbox.Worker{}
+	outboxWorker.New(dbService)
+	go outboxWorker.Start()
+
 	// -----------------------
 	// Echo server definition
 	// -----------------------
@@ -103,12 +155,41 @@ func main() {
 		return context.String(http.StatusNoContent, "Admin route root")
 	})
 	admin.POST("/passwordreset", api.ResetPwd(&dbService))
+	admin.POST("/users", api.CreateUser(&dbService))
+	admin.DELETE("/users/:username", api.DeleteUser(&dbService))
+	admin.GET("/audit", api.GetAuditLog(&dbService))
+	admin.GET("/audit/verify", api.VerifyAuditLog(&dbService))
+	admin.POST("/rosterimport", api.ImportRoster(&dbService))
+	admin.GET("/outbox", api.GetOutboxEntries(&dbService))
+	admin.POST("/outbox/:id/replay", api.ReplayOutboxEntry(&dbService))
+	admin.GET("/aliases", api.GetOperatorAliases(&dbService))
+	admin.GET("/aliases/ambiguous", api.GetAmbiguousAliases(&dbService))
+	admin.POST("/aliases", api.PostOperatorAlias(&dbService))
+	admin.DELETE("/aliases/:id", api.DeleteOperatorAlias(&dbService))
+	admin.GET("/certificates/types", api.GetCertificateTypes(&dbService))
+	admin.POST("/certificates/types", api.PostCertificateType(&dbService))
+	admin.DELETE("/certificates/types/:id", api.DeleteCertificateType(&dbService))
+	admin.GET("/certificates", api.GetOperatorCertificates(&dbService))
+	admin.GET("/certificates/expiring", api.GetExpiringCertificates(&dbService))
+	admin.POST("/certificates", api.PostOperatorCertificate(&dbService))
+	admin.DELETE("/certificates/:id", api.DeleteOperatorCertificate(&dbService))
+	admin.GET("/requirements", api.GetRequirements(&dbService))
+	admin.POST("/requirements", api.PostRequirement(&dbService))
+	admin.DELETE("/requirements/:kind/:target/:type", api.DeleteRequirement(&dbService))
 
 	// Manager group (req auth and manager role)
 	manager := e.Group("/manager", middleware.JWT([]byte(os.Getenv("SECRET"))))
 	manager.Use(checkIfRole("manager"))
-	manager.PUT("/dochange", api.PutChange())
+	manager.PUT("/dochange", api.PutChange(&dbService))
 	manager.POST("/managechange", api.ManageChangeRequest(&dbService))
+	manager.GET("/changes/:id/overrides", api.GetRuleOverrides(&dbService))
+	manager.GET("/overrides/:kind/:id", api.GetRuleOverrides(&dbService))
+	manager.POST("/managechain", api.ManageSwapChain(&dbService))
+	manager.POST("/managecover", api.ManageCover(&dbService))
+	manager.POST("/offers/:id/award", api.AwardOffer(&dbService))
+	manager.POST("/sync", api.SyncRoster(&dbService))
+	manager.GET("/sync/conflicts", api.GetSyncConflicts(&dbService))
+	manager.POST("/sync/conflicts/:id", api.ResolveSyncConflict(&dbService))
 
 	// Users group (req auth)
 	users := e.Group("/users", middleware.JWT([]byte(os.Getenv("SECRET"))))
@@ -117,38 +198,56 @@ func main() {
 	})
 	users.GET("/all", api.GetAllUserNames(&dbService))
 	users.GET("/userdetails", api.GetUserDetailsFromClaims(&dbService))
+	users.GET("/notifications", api.GetNotificationSettings(&dbService))
+	users.PUT("/notifications", api.PutNotificationSetting(&dbService))
 
 	// Shift data (req auth)
 	shiftData := e.Group("/shiftdata", middleware.JWT([]byte(os.Getenv("SECRET"))))
 	shiftData.GET("/all", api.GetAllFormData(&dbService))
-	shiftData.GET("/today", api.GetLoggedInOperatorShift())
-	shiftData.GET("/date/:date", api.GetLoggedInOperatorShiftByDate())
+	shiftData.GET("/today", api.GetLoggedInOperatorShift(&dbService))
+	shiftData.GET("/date/:date", api.GetLoggedInOperatorShiftByDate(&dbService))
 
 	// Change request (req auth)
 	changeRequest := e.Group("/changes", middleware.JWT([]byte(os.Getenv("SECRET"))))
 	changeRequest.POST("/request", api.RequestChange(&dbService))
 	changeRequest.GET("/all", api.GetAllChanges(&dbService), checkIfRole("manager"))
 	changeRequest.GET("/user", api.GetAllChangesForUser(&dbService))
+	changeRequest.GET("/incoming", api.GetIncomingChanges(&dbService))
+	changeRequest.POST("/respond", api.RespondToChange(&dbService))
+	changeRequest.POST("/cancel", api.CancelChange(&dbService))
+	changeRequest.POST("/chain", api.RequestSwapChain(&dbService))
+	changeRequest.GET("/chains", api.GetAllSwapChains(&dbService), checkIfRole("manager"))
+	changeRequest.POST("/cover", api.RequestCover(&dbService))
+	changeRequest.GET("/covers", api.GetAllCovers(&dbService), checkIfRole("manager"))
+	changeRequest.GET("/covers/user", api.GetAllCoversForUser(&dbService))
+
+	// Shift offers board (req auth)
+	offers := e.Group("/offers", middleware.JWT([]byte(os.Getenv("SECRET"))))
+	offers.GET("", api.GetOpenOffers(&dbService))
+	offers.POST("", api.PostOffer(&dbService))
+	offers.DELETE("/:id", api.WithdrawOffer(&dbService))
+	offers.POST("/:id/bids", api.PostBid(&dbService))
+	offers.DELETE("/:id/bids", api.WithdrawBid(&dbService))
 
 	// License request (req auth)
 	licenseRequest := e.Group("/license", middleware.JWT([]byte(os.Getenv("SECRET"))))
-	licenseRequest.POST("/request", api.PostLicense())
+	licenseRequest.POST("/request", api.PostLicense(&dbService))
 
 	// Permission request (req auth)
 	permissionRequest := e.Group("/permission", middleware.JWT([]byte(os.Getenv("SECRET"))))
-	permissionRequest.POST("/request", api.PostPermission())
+	permissionRequest.POST("/request", api.PostPermission(&dbService))
 
 	// Illness request (req auth)
 	illnessRequest := e.Group("/illness", middleware.JWT([]byte(os.Getenv("SECRET"))))
-	illnessRequest.POST("/request", api.PostIllness())
+	illnessRequest.POST("/request", api.PostIllness(&dbService))
 
 	// Gsheet group (req auth)
 	gSheet := e.Group("/sheets", middleware.JWT([]byte(os.Getenv("SECRET"))))
 	gSheet.GET("", func(context echo.Context) error {
 		return context.String(http.StatusNoContent, "Google Sheets route root")
 	})
-	gSheet.POST("/shift", api.PostShift())
-	gSheet.GET("/pastshifts", api.GetPostedShifts())
+	gSheet.POST("/shift", api.PostShift(&dbService))
+	gSheet.GET("/pastshifts", api.GetPostedShifts(&dbService))
 
 	// -----------------------
 	// Server Start